@@ -1,23 +1,48 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"raytraceGo/internal/monitoring"
+	"raytraceGo/internal/output"
 	"raytraceGo/internal/renderer"
 	"raytraceGo/internal/scene"
+	"raytraceGo/internal/shutdown"
+	"raytraceGo/internal/tonemap"
 	"runtime"
 	"strconv"
+	"strings"
 )
 
 func main() {
+	samples := flag.Int("samples", 100, "Samples per pixel")
+	depthOfField := flag.Bool("dof", false, "Enable depth of field (uses the camera aperture, or a default lens)")
+	integrator := flag.String("integrator", renderer.IntegratorArtistic, "Shading integrator: artistic (legacy hand-tuned shading) or path (physically based path tracing)")
+	seed := flag.Uint64("seed", 0, "Seed for the random sampling streams; the same seed gives the same image")
+	samplerName := flag.String("sampler", "", "Sample generator: independent, stratified, halton or sobol (default: the scene's renderer.sampler, else independent)")
+	adaptive := flag.Bool("adaptive", false, "Sample each pixel until its noise is below -noise-threshold instead of a fixed count")
+	minSamples := flag.Int("min-samples", 16, "Samples every pixel gets with -adaptive")
+	maxSamples := flag.Int("max-samples", 256, "Most samples a pixel gets with -adaptive")
+	noiseThreshold := flag.Float64("noise-threshold", 0.01, "Relative noise at which -adaptive stops sampling a pixel")
+	heatmapFile := flag.String("heatmap", "", "With -adaptive, also save a heatmap of the per-pixel sample counts to this PNG file")
+	aovList := flag.String("aov", "", "Comma-separated AOVs to render with the image: depth, normal, albedo, position, materialID, objectID, or all (default: the scene's renderer.aovs)")
+	denoise := flag.Bool("denoise", false, "Denoise the image using its albedo, normal and depth; the unfiltered image is saved alongside with a .raw suffix")
+	toneMapOperator := flag.String("tonemap", "", "Tone mapping operator: linear, exponential, reinhard, aces or hable (default: the scene's renderer.toneMapping, else exponential)")
+	exposure := flag.Float64("exposure", 0, "Exposure in stops, overriding the scene's renderer.toneMapping.exposure")
+	whiteBalance := flag.Float64("white-balance", 0, "Color temperature in kelvin rendered as neutral white, overriding the scene's setting")
+	checkpointFile := flag.String("checkpoint", "", "Save the render's progress to this file periodically, on Ctrl-C and when done")
+	checkpointInterval := flag.Duration("checkpoint-interval", renderer.DefaultCheckpointInterval, "How often -checkpoint saves")
+	resumeFile := flag.String("resume", "", "Continue the render saved in this checkpoint file with its settings; a higher -samples, or -max-samples for adaptive renders, adds samples to a finished render")
 	flag.Parse()
 	args := flag.Args()
 	
 	if len(args) < 4 {
-		fmt.Println("Usage: raytracer <scene_file> <output_file> <width> <height>")
+		fmt.Println("Usage: raytracer [flags] <scene_file> <output_file> <width> <height>")
 		fmt.Println("Example: raytracer scene.json output.png 800 600")
+		fmt.Println("The output format follows the extension: .png, .ppm, or linear .exr, .hdr and .pfm")
 		os.Exit(1)
 	}
 	
@@ -43,12 +68,112 @@ func main() {
 		os.Exit(1)
 	}
 	
+	var progress *monitoring.ProgressReporter
+	renderOptions := renderer.RenderOptions{
+		Progress: func(p renderer.RenderProgress) {
+			progress.UpdateProgress(p.CompletedPixels)
+		},
+	}
+	
 	numWorkers := runtime.NumCPU()
 	renderer := renderer.NewParallelRenderer(numWorkers)
+	renderer.SetSamples(*samples)
+	renderer.SetDepthOfField(*depthOfField)
+	renderer.SetSeed(*seed)
+	
+	sampler := scene.GetRenderSettings().Sampler
+	if *samplerName != "" {
+		sampler = *samplerName
+	}
+	if sampler != "" {
+		if err := renderer.SetSampler(sampler); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	aovs := scene.GetRenderSettings().AOVs
+	if *aovList == "all" {
+		aovs = output.AOVNames
+	} else if *aovList != "" {
+		aovs = strings.Split(*aovList, ",")
+	}
+	if err := renderer.SetAOVs(aovs); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	toneMapping := tonemap.Settings{}
+	if settings := scene.GetRenderSettings().ToneMapping; settings != nil {
+		toneMapping = *settings
+	}
+	if *toneMapOperator != "" {
+		toneMapping.Operator = *toneMapOperator
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "exposure":
+			toneMapping.Exposure = *exposure
+		case "white-balance":
+			toneMapping.WhiteBalance = *whiteBalance
+		}
+	})
+	if err := renderer.SetToneMapping(toneMapping); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	renderer.SetDenoise(*denoise)
+	if err := renderer.SetIntegrator(*integrator); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *adaptive {
+		if err := renderer.SetAdaptiveSampling(*minSamples, *maxSamples, *noiseThreshold); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *resumeFile != "" {
+		addSamples := 0
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "samples":
+				addSamples = max(addSamples, *samples)
+			case "max-samples":
+				addSamples = max(addSamples, *maxSamples)
+			}
+		})
+		fmt.Printf("Resuming from checkpoint: %s\n", *resumeFile)
+		if err := renderer.Resume(*resumeFile, addSamples); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *checkpointFile == "" {
+			*checkpointFile = *resumeFile
+		}
+	}
+	if *checkpointFile != "" {
+		renderer.SetCheckpoint(*checkpointFile, *checkpointInterval)
+	}
+	
+	// Ctrl-C stops the render; the tiles finished so far are still saved.
+	graceful := shutdown.NewGracefulShutdown(context.Background())
+	graceful.Start()
+	ctx := graceful.GetContext()
 	
 	fmt.Printf("Rendering at %dx%d resolution...\n", width, height)
 	
-	img := renderer.Render(scene, width, height)
+	progress = monitoring.NewProgressReporter(ctx, int64(width*height))
+	progress.Start()
+	framebuffer, renderErr := renderer.RenderContext(ctx, scene, width, height, renderOptions)
+	progress.Finish()
+	if framebuffer == nil {
+		fmt.Printf("Error: %v\n", renderErr)
+		os.Exit(1)
+	}
+	if renderErr != nil {
+		fmt.Printf("Rendering stopped: %v\n", renderErr)
+	} else {
+		fmt.Println("Rendering complete!")
+	}
 	
 	outputPath := outputFile
 	if filepath.Ext(outputPath) == "" {
@@ -56,10 +181,28 @@ func main() {
 	}
 	
 	fmt.Printf("Saving to: %s\n", outputPath)
-	if err := renderer.SaveImage(img, outputPath); err != nil {
+	if err := renderer.SaveFramebuffer(framebuffer, outputPath); err != nil {
 		fmt.Printf("Error saving image: %v\n", err)
 		os.Exit(1)
 	}
+	if renderErr != nil {
+		os.Exit(1)
+	}
+	
+	if raw := renderer.GetRawFramebuffer(); raw != nil {
+		rawPath := output.LayerFilename(outputPath, "raw")
+		fmt.Printf("Saving raw image to: %s\n", rawPath)
+		if err := renderer.SaveFramebuffer(raw, rawPath); err != nil {
+			fmt.Printf("Error saving raw image: %v\n", err)
+		}
+	}
+	
+	if heatmap := renderer.GetSampleHeatmap(); heatmap != nil && *heatmapFile != "" {
+		fmt.Printf("Saving sample heatmap to: %s\n", *heatmapFile)
+		if err := renderer.SaveImage(heatmap, *heatmapFile); err != nil {
+			fmt.Printf("Error saving heatmap: %v\n", err)
+		}
+	}
 	
 	benchmarkPath := filepath.Join(filepath.Dir(outputPath), "benchmark_data.json")
 	if err := renderer.SaveBenchmarkData(benchmarkPath); err != nil {
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"raytraceGo/internal/distributed"
+	"raytraceGo/internal/output"
+	"raytraceGo/internal/renderer"
+	"raytraceGo/internal/shutdown"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	defaults := distributed.DefaultCoordinatorConfig()
+	
+	serve := flag.String("serve", "", "Run a render node listening on this port instead of coordinating a render")
+	join := flag.String("join", "", "With -serve, register with the coordinator at this -listen address and send it heartbeats")
+	advertise := flag.String("advertise", "", "With -join, the host:port the coordinator reaches this node at (default: the hostname and -serve port)")
+	listen := flag.String("listen", "", "Accept nodes started with -join on this port, also while rendering")
+	wait := flag.Duration("wait", 10*time.Second, "With -listen, wait this long for a node to join before rendering")
+	workers := flag.Int("workers", runtime.NumCPU(), "Render workers of a node, or of the coordinator for chunks it renders itself")
+	nodeList := flag.String("nodes", "", "Comma-separated host:port addresses of the render nodes")
+	mode := flag.String("mode", "tiles", "Split the render into tiles, or into samples: every node renders the whole frame with some of the samples, and the image improves as nodes report")
+	tileSize := flag.Int("tile", defaults.TileSize, "Width and height of the chunks the frame is split into")
+	samplesPerJob := flag.Int("samples-per-job", defaults.SamplesPerJob, "With -mode samples, samples per pixel each node renders at a time (default: four jobs per node)")
+	preview := flag.Bool("preview", true, "With -mode samples, save the image after every merged job")
+	samples := flag.Int("samples", 100, "Samples per pixel")
+	maxDepth := flag.Int("max-depth", 0, "Maximum ray depth (default: the renderer's)")
+	seed := flag.Uint64("seed", 0, "Seed for the random sampling streams; the same seed gives the same image")
+	integrator := flag.String("integrator", renderer.IntegratorArtistic, "Shading integrator: artistic or path")
+	samplerName := flag.String("sampler", "", "Sample generator: independent, stratified, halton or sobol (default: the scene's renderer.sampler, else independent)")
+	depthOfField := flag.Bool("dof", false, "Enable depth of field")
+	slots := flag.Int("slots", defaults.SlotsPerNode, "Chunks each node renders at once")
+	chunkTimeout := flag.Duration("chunk-timeout", defaults.ChunkTimeout, "Give up on a chunk a node has not finished in this time")
+	retries := flag.Int("retries", defaults.MaxAttempts, "Nodes a chunk may fail on before the coordinator renders it itself")
+	nodeFailures := flag.Int("node-failures", defaults.NodeFailures, "Failures in a row after which a node is dropped")
+	straggler := flag.Float64("straggler", defaults.StragglerFactor, "Also start chunks running this many times longer than the median chunk on another node")
+	tileFormat := flag.String("tile-format", distributed.DefaultTileEncoding.Format.String(), "How nodes send chunks: float16 or float32 with the linear radiance, or uint8 for smaller chunks without it that rule out .exr, .hdr and .pfm output and post-processing, or json for debugging")
+	compress := flag.Bool("compress", true, "Compress chunks sent in a binary -tile-format with DEFLATE")
+	statsFile := flag.String("stats", "", "Save per-node statistics to this JSON file (default: renderfarm_stats.json next to the output)")
+	flag.Parse()
+	args := flag.Args()
+	
+	if *serve != "" {
+		server := distributed.NewRemoteRenderServer(*serve, *workers)
+		if *join != "" {
+			address := *advertise
+			if address == "" {
+				hostname, err := os.Hostname()
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(1)
+				}
+				address = net.JoinHostPort(hostname, *serve)
+			}
+			go server.Announce(context.Background(), *join, address, distributed.DefaultHeartbeatInterval)
+		}
+		
+		fmt.Printf("Render node listening on port %s with %d workers\n", *serve, *workers)
+		if err := server.Start(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	
+	if len(args) < 4 {
+		fmt.Println("Usage: renderfarm -nodes <host:port,...> [flags] <scene_file> <output_file> <width> <height>")
+		fmt.Println("       renderfarm -serve <port> [-join <coordinator:port>]")
+		fmt.Println("Example: renderfarm -nodes node1:8080,node2:8080 scene.json output.png 800 600")
+		os.Exit(1)
+	}
+	
+	sceneFile := args[0]
+	outputFile := args[1]
+	width, err := strconv.Atoi(args[2])
+	if err != nil {
+		fmt.Printf("Invalid width: %s\n", args[2])
+		os.Exit(1)
+	}
+	
+	height, err := strconv.Atoi(args[3])
+	if err != nil {
+		fmt.Printf("Invalid height: %s\n", args[3])
+		os.Exit(1)
+	}
+	
+	var nodes []string
+	for _, node := range strings.Split(*nodeList, ",") {
+		if node = strings.TrimSpace(node); node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	
+	config := defaults
+	config.TileSize = *tileSize
+	config.SamplesPerJob = *samplesPerJob
+	config.SlotsPerNode = *slots
+	config.ChunkTimeout = *chunkTimeout
+	config.MaxAttempts = *retries
+	config.NodeFailures = *nodeFailures
+	config.StragglerFactor = *straggler
+	config.LocalWorkers = *workers
+	settings := distributed.RenderSettings{
+		Samples:      *samples,
+		MaxDepth:     *maxDepth,
+		Seed:         *seed,
+		Integrator:   *integrator,
+		Sampler:      *samplerName,
+		DepthOfField: *depthOfField,
+	}
+	
+	outputPath := outputFile
+	if filepath.Ext(outputPath) == "" {
+		outputPath += ".png"
+	}
+	
+	var encoding *distributed.TileEncoding
+	if *tileFormat != "json" {
+		format, err := distributed.ParseTileFormat(*tileFormat)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		encoding = &distributed.TileEncoding{Format: format, Compress: *compress}
+	}
+	if *mode != "tiles" && *mode != "samples" {
+		fmt.Printf("Error: unknown mode %q (want tiles or samples)\n", *mode)
+		os.Exit(1)
+	}
+	hdr := output.IsHDRFile(outputPath)
+	if hdr && *mode == "tiles" && (encoding == nil || encoding.Format == distributed.TileUint8) {
+		fmt.Println("Error: HDR output in -mode tiles needs -tile-format float16 or float32")
+		os.Exit(1)
+	}
+	
+	// Ctrl-C stops the render; the chunks or samples finished so far are
+	// still saved.
+	graceful := shutdown.NewGracefulShutdown(context.Background())
+	graceful.Start()
+	ctx := graceful.GetContext()
+	
+	client := distributed.NewDistributedRenderer(ctx, nodes)
+	client.SetTileEncoding(encoding)
+	balancer := distributed.NewLoadBalancer(nodes, &distributed.LeastConnectionsStrategy{})
+	if *listen != "" {
+		registry := distributed.NewNodeRegistry(balancer, 3*distributed.DefaultHeartbeatInterval)
+		registry.Start(ctx)
+		go func() {
+			if err := http.ListenAndServe(":"+*listen, registry.Handler()); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}()
+		
+		fmt.Printf("Waiting for render nodes on port %s...\n", *listen)
+		for deadline := time.Now().Add(*wait); len(balancer.GetNodes()) == 0 && time.Now().Before(deadline) && ctx.Err() == nil; {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	if len(balancer.GetNodes()) == 0 {
+		fmt.Println("No render nodes available; rendering locally")
+	}
+	coordinator, err := distributed.NewCoordinatorFromFile(client, balancer, sceneFile, settings, config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if coordinator.LocalOnly() && len(balancer.GetNodes()) > 0 {
+		fmt.Println("The scene has mesh files, which render nodes cannot open; rendering locally")
+	}
+	
+	fmt.Printf("Rendering %s at %dx%d on %d nodes...\n", sceneFile, width, height, len(balancer.GetNodes()))
+	var img *image.RGBA
+	var renderErr error
+	if *mode == "samples" {
+		img, renderErr = coordinator.RenderSamples(ctx, width, height, func(p distributed.SampleProgress) {
+			fmt.Printf("Merged %d/%d jobs, %d samples per pixel\n", p.Jobs, p.TotalJobs, p.Samples)
+			if *preview && !hdr && p.Jobs < p.TotalJobs {
+				if err := output.SaveImage(p.Image, outputPath); err != nil {
+					fmt.Printf("Error saving preview: %v\n", err)
+				}
+			}
+		})
+	} else {
+		img, renderErr = coordinator.Render(ctx, width, height)
+	}
+	if renderErr != nil {
+		fmt.Printf("Rendering stopped: %v\n", renderErr)
+		if img == nil {
+			os.Exit(1)
+		}
+	} else {
+		fmt.Println("Rendering complete!")
+	}
+	
+	stats := coordinator.GetStats()
+	fmt.Printf("%-24s %8s %8s %8s %8s %10s\n", "Node", "Jobs", "Failed", "Spec.", "Cancel", "Time")
+	for _, node := range stats {
+		name := node.Node
+		if node.Removed {
+			name += " (removed)"
+		}
+		fmt.Printf("%-24s %8d %8d %8d %8d %9.1fs\n", name, node.Chunks, node.Failures, node.Speculative, node.Cancelled, node.RenderTime)
+	}
+	
+	fmt.Printf("Saving to: %s\n", outputPath)
+	if hdr {
+		err = output.SaveFramebuffer(coordinator.GetFramebuffer(), outputPath)
+	} else {
+		err = output.SaveImage(img, outputPath)
+	}
+	if err != nil {
+		fmt.Printf("Error saving image: %v\n", err)
+		os.Exit(1)
+	}
+	
+	statsPath := *statsFile
+	if statsPath == "" {
+		statsPath = filepath.Join(filepath.Dir(outputPath), "renderfarm_stats.json")
+	}
+	if err := saveStats(stats, statsPath); err != nil {
+		fmt.Printf("Error saving statistics: %v\n", err)
+	} else {
+		fmt.Printf("Statistics saved to: %s\n", statsPath)
+	}
+	
+	if renderErr != nil {
+		os.Exit(1)
+	}
+}
+
+func saveStats(stats []distributed.NodeStats, filename string) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
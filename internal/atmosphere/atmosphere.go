@@ -1,10 +1,28 @@
 package atmosphere
 
 import (
+	"fmt"
 	stdmath "math"
 	"raytraceGo/internal/math"
 )
 
+var PresetNames = []string{"default", "white", "sunset", "night"}
+
+func NewPreset(name string) (*AtmosphereConfig, error) {
+	switch name {
+	case "default":
+		return NewDefaultAtmosphere(), nil
+	case "white":
+		return NewWhiteAtmosphere(), nil
+	case "sunset":
+		return NewSunsetAtmosphere(), nil
+	case "night":
+		return NewNightAtmosphere(), nil
+	default:
+		return nil, fmt.Errorf("unknown atmosphere preset: %s", name)
+	}
+}
+
 type AtmosphereConfig struct {
 	SkyColorTop    math.Vec3
 	SkyColorBottom math.Vec3
@@ -108,7 +126,7 @@ func (a *AtmosphereConfig) GetSkyColor(rayDirection math.Vec3) math.Vec3 {
 	scatteringColor := math.FastVec3Lerp(a.RayleighScattering, a.MieScattering, atmospheric)
 	skyColor = math.FastVec3Lerp(skyColor, scatteringColor, 0.25) // Balanced atmospheric effect
 	
-	sunDot := math.FastVec3Dot(unitDirection, a.SunDirection)
+	sunDot := math.FastVec3Dot(unitDirection, math.FastVec3Normalize(a.SunDirection))
 	if sunDot > (1.0 - a.SunSize) {
 		sunIntensity := stdmath.Pow((sunDot-(1.0-a.SunSize))/a.SunSize, 1.5)
 		sunIntensity = stdmath.Min(sunIntensity, 1.0)
@@ -140,4 +158,20 @@ func (a *AtmosphereConfig) GetAtmosphericAttenuation(distance float64) float64 {
 	mieAttenuation := stdmath.Exp(-distance * 0.05)
 	
 	return rayleighAttenuation * mieAttenuation
-} 
\ No newline at end of file
+}
+
+// ApplyAerialPerspective fades the colour of a surface seen at the given
+// distance towards the horizon haze and then towards the fog colour.
+func (a *AtmosphereConfig) ApplyAerialPerspective(color math.Vec3, distance float64) math.Vec3 {
+	if a.HazeIntensity > 0 {
+		haze := math.FastClamp(a.HazeIntensity*(1.0-a.GetAtmosphericAttenuation(distance)), 0.0, 1.0)
+		color = math.FastVec3Lerp(color, a.SkyColorBottom, haze)
+	}
+	
+	if a.FogDensity > 0 {
+		fog := 1.0 - stdmath.Exp(-a.FogDensity*distance)
+		color = math.FastVec3Lerp(color, a.FogColor, fog)
+	}
+	
+	return color
+}
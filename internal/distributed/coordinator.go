@@ -0,0 +1,658 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"raytraceGo/internal/effects"
+	"raytraceGo/internal/output"
+	"raytraceGo/internal/renderer"
+	"raytraceGo/internal/scene"
+	"runtime"
+	"slices"
+	"sort"
+	"time"
+)
+
+// LocalNode names the coordinator's own machine in its statistics.
+const LocalNode = "local"
+
+// How often the coordinator looks for stragglers while waiting for results.
+const stragglerCheckInterval = 50 * time.Millisecond
+
+// CoordinatorConfig tunes how a Coordinator schedules chunks, or sample
+// ranges in RenderSamples.
+type CoordinatorConfig struct {
+	// TileSize is the width and height of the chunks a frame is split into.
+	TileSize int
+	// SamplesPerJob is the number of samples per pixel of each sample range;
+	// 0 splits the samples into four ranges per node.
+	SamplesPerJob int
+	// SlotsPerNode is how many chunks a node renders at once.
+	SlotsPerNode int
+	// ChunkTimeout abandons a chunk on a node that takes longer.
+	ChunkTimeout time.Duration
+	// MaxAttempts is how many nodes may fail a chunk before it is rendered
+	// locally.
+	MaxAttempts int
+	// NodeFailures is how many failures in a row remove a node.
+	NodeFailures int
+	// StragglerFactor: once no chunk is waiting for a node, a chunk running
+	// longer than this many times the median chunk time is also started on
+	// another node, and whichever copy finishes first is used.
+	StragglerFactor float64
+	// LocalWorkers is the number of workers for chunks rendered locally.
+	LocalWorkers int
+}
+
+func DefaultCoordinatorConfig() CoordinatorConfig {
+	return CoordinatorConfig{
+		TileSize:        64,
+		SlotsPerNode:    2,
+		ChunkTimeout:    5 * time.Minute,
+		MaxAttempts:     3,
+		NodeFailures:    3,
+		StragglerFactor: 3,
+		LocalWorkers:    runtime.NumCPU(),
+	}
+}
+
+// validate rejects settings a render could not finish with, such as chunks
+// of no pixels or nodes without slots.
+func (config CoordinatorConfig) validate() error {
+	for _, setting := range []struct {
+		name  string
+		value int
+	}{
+		{"tile size", config.TileSize},
+		{"slots per node", config.SlotsPerNode},
+		{"max attempts", config.MaxAttempts},
+		{"node failures", config.NodeFailures},
+		{"local workers", config.LocalWorkers},
+	} {
+		if setting.value < 1 {
+			return fmt.Errorf("invalid %s %d: must be at least 1", setting.name, setting.value)
+		}
+	}
+	if config.ChunkTimeout <= 0 {
+		return fmt.Errorf("invalid chunk timeout %v: must be positive", config.ChunkTimeout)
+	}
+	return nil
+}
+
+// NodeStats summarizes a node's share of a render. In RenderSamples, Chunks
+// counts sample ranges and Samples their samples per pixel.
+type NodeStats struct {
+	Node        string  `json:"node"`
+	Chunks      int     `json:"chunks"`
+	Pixels      int     `json:"pixels"`
+	Samples     int     `json:"samples,omitempty"`
+	Failures    int     `json:"failures"`
+	Speculative int     `json:"speculative"`
+	Cancelled   int     `json:"cancelled"`
+	RenderTime  float64 `json:"render_time_seconds"`
+	Removed     bool    `json:"removed,omitempty"`
+}
+
+// Coordinator renders frames on a farm of RemoteRenderServer nodes. It
+// splits a frame into chunks and hands them to the nodes its LoadBalancer
+// selects, a few at a time per node. Chunks from nodes that fail or time out
+// are queued again, nodes that keep failing are removed, and stragglers are
+// re-executed speculatively on another node. Chunks that fail on several
+// nodes, or find no node left, are rendered locally. Nodes added to the
+// balancer during a render, such as by a NodeRegistry, are used as they join;
+// the scene is uploaded to every node before its first chunk. Nodes cannot
+// open the files of mesh objects, so scenes with them are rendered locally.
+//
+// RenderSamples instead splits the frame's samples per pixel, so that every
+// node renders the whole frame.
+type Coordinator struct {
+	client    *DistributedRenderer
+	balancer  *LoadBalancer
+	config    CoordinatorConfig
+	sceneJSON []byte
+	scene     *scene.Scene
+	settings  RenderSettings
+	stats     map[string]*NodeStats
+	
+	width, height int
+	results       []RemoteResult
+	framebuffer   *output.Framebuffer
+}
+
+// NewCoordinator returns a coordinator for the scene; relative mesh paths are
+// resolved against the working directory.
+func NewCoordinator(client *DistributedRenderer, balancer *LoadBalancer, sceneJSON []byte, settings RenderSettings, config CoordinatorConfig) (*Coordinator, error) {
+	return newCoordinator(client, balancer, sceneJSON, "", settings, config)
+}
+
+// NewCoordinatorFromFile returns a coordinator for the scene in sceneFile,
+// resolving relative mesh paths against its directory like
+// scene.LoadFromFile.
+func NewCoordinatorFromFile(client *DistributedRenderer, balancer *LoadBalancer, sceneFile string, settings RenderSettings, config CoordinatorConfig) (*Coordinator, error) {
+	sceneJSON, err := os.ReadFile(sceneFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading scene: %w", err)
+	}
+	return newCoordinator(client, balancer, sceneJSON, filepath.Dir(sceneFile), settings, config)
+}
+
+func newCoordinator(client *DistributedRenderer, balancer *LoadBalancer, sceneJSON []byte, baseDir string, settings RenderSettings, config CoordinatorConfig) (*Coordinator, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	s, err := scene.ParseWithBaseDir(sceneJSON, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scene: %w", err)
+	}
+	if settings.DepthOfField && !s.Camera.HasLens() {
+		return nil, fmt.Errorf("depth of field needs a lens, which the %s projection does not have", s.Camera.Projection)
+	}
+	
+	return &Coordinator{
+		client:    client,
+		balancer:  balancer,
+		config:    config,
+		sceneJSON: sceneJSON,
+		scene:     s,
+		settings:  settings,
+		stats:     make(map[string]*NodeStats),
+	}, nil
+}
+
+// Render renders a width x height frame and returns it tone mapped. If ctx
+// is cancelled it returns the chunks finished so far with ctx's error. The
+// scene's post-processing works on the whole frame, so scenes that have it
+// need a client that asks for a float TileFormat; the coordinator then applies
+// it to the composed radiance and tone maps the result itself.
+func (c *Coordinator) Render(ctx context.Context, width, height int) (*image.RGBA, error) {
+	c.stats = make(map[string]*NodeStats)
+	
+	postProcess := len(c.scene.GetPostProcess()) > 0
+	if postProcess && !c.client.sendsRadiance() {
+		return nil, fmt.Errorf("the scene's post-processing needs a float tile format or sample-space rendering")
+	}
+	r, err := newRenderer(c.settings, c.scene, c.config.LocalWorkers)
+	if err != nil {
+		return nil, err
+	}
+	
+	hash := SceneHash(c.sceneJSON)
+	chunks := SplitFrame(width, height, c.config.TileSize)
+	for i := range chunks {
+		chunks[i].SceneHash = hash
+		chunks[i].Settings = c.settings
+	}
+	
+	var results []RemoteResult
+	render := func(ctx context.Context, job int, node string) (jobResult, error) {
+		if node == LocalNode {
+			t, err := renderChunk(ctx, chunks[job], c.scene, c.config.LocalWorkers)
+			if err != nil {
+				return jobResult{}, err
+			}
+			return jobResult{chunk: &RemoteResult{Pixels: t.pixels(), Rect: t.rect, Radiance: t.radiance}}, nil
+		}
+		result, err := c.client.RenderChunkContext(ctx, chunks[job], node)
+		return jobResult{chunk: result}, err
+	}
+	collect := func(job int, node string, result jobResult, elapsed float64) error {
+		result.chunk.ChunkID = chunks[job].ID
+		result.chunk.Duration = elapsed
+		result.chunk.NodeID = node
+		results = append(results, *result.chunk)
+		c.nodeStats(node).Pixels += len(result.chunk.Pixels)
+		return nil
+	}
+	
+	err = newSchedule(ctx, c, len(chunks), render, collect).run()
+	c.width, c.height, c.results, c.framebuffer = width, height, results, nil
+	if !postProcess {
+		return ComposeImage(width, height, results), err
+	}
+	c.framebuffer = ComposeFramebuffer(width, height, results)
+	return c.finish(r, c.framebuffer), err
+}
+
+// SampleProgress reports a sample range merged by RenderSamples.
+type SampleProgress struct {
+	// Jobs of TotalJobs sample ranges have been merged.
+	Jobs, TotalJobs int
+	// Samples is the number of samples per pixel merged so far.
+	Samples int
+	// Image is the frame rendered with them.
+	Image *image.RGBA
+}
+
+// RenderSamples renders a width x height frame in sample space: every job
+// renders the whole frame with its own range of the samples per pixel, and
+// the buffers the nodes return are merged as they arrive. Ranges are
+// scheduled like chunks, so failed ranges are rendered again elsewhere, and
+// progress, if not nil, receives the image after every merge. As the
+// coordinator has the whole frame, the scene's post-processing is applied
+// whatever the tile format. If ctx is cancelled it returns the image of the ranges merged so
+// far, which has fewer samples per pixel but no missing pixels, with ctx's
+// error.
+func (c *Coordinator) RenderSamples(ctx context.Context, width, height int, progress func(SampleProgress)) (*image.RGBA, error) {
+	c.stats = make(map[string]*NodeStats)
+	
+	r, err := newRenderer(c.settings, c.scene, c.config.LocalWorkers)
+	if err != nil {
+		return nil, err
+	}
+	perJob := c.config.SamplesPerJob
+	if perJob <= 0 {
+		perJob = max(r.GetSamples()/(4*max(len(c.balancer.GetNodes()), 1)), 1)
+	}
+	
+	hash := SceneHash(c.sceneJSON)
+	jobs := SplitSamples(width, height, r.GetSamples(), perJob)
+	for i := range jobs {
+		jobs[i].SceneHash = hash
+		jobs[i].Settings = c.settings
+	}
+	
+	merged := renderer.NewSampleBuffer(width, height)
+	merges, samples := 0, 0
+	render := func(ctx context.Context, job int, node string) (jobResult, error) {
+		if node == LocalNode {
+			buffer, err := renderSamples(ctx, jobs[job], c.scene, c.config.LocalWorkers)
+			if err != nil {
+				return jobResult{}, err
+			}
+			return jobResult{samples: &SampleResult{Buffer: buffer}}, nil
+		}
+		result, err := c.client.RenderSamplesContext(ctx, jobs[job], node)
+		return jobResult{samples: result}, err
+	}
+	collect := func(job int, node string, result jobResult, elapsed float64) error {
+		if err := merged.Merge(result.samples.Buffer); err != nil {
+			return err
+		}
+		merges++
+		samples += jobs[job].Samples
+		
+		stats := c.nodeStats(node)
+		stats.Pixels += width * height
+		stats.Samples += jobs[job].Samples
+		if progress != nil {
+			img := c.finish(r, merged.Framebuffer())
+			progress(SampleProgress{Jobs: merges, TotalJobs: len(jobs), Samples: samples, Image: img})
+		}
+		return nil
+	}
+	
+	err = newSchedule(ctx, c, len(jobs), render, collect).run()
+	c.width, c.height, c.results, c.framebuffer = width, height, nil, merged.Framebuffer()
+	return c.finish(r, c.framebuffer), err
+}
+
+// finish applies the scene's post-processing to a whole frame and tone maps
+// it.
+func (c *Coordinator) finish(r *renderer.ParallelRenderer, framebuffer *output.Framebuffer) *image.RGBA {
+	effects.ApplyPostProcess(framebuffer, c.scene.GetPostProcess())
+	return r.ToneMap(framebuffer)
+}
+
+// GetFramebuffer returns the linear radiance of the last render, or nil if
+// Render's client did not ask nodes for a float TileFormat.
+func (c *Coordinator) GetFramebuffer() *output.Framebuffer {
+	if c.framebuffer != nil {
+		return c.framebuffer
+	}
+	return ComposeFramebuffer(c.width, c.height, c.results)
+}
+
+// GetStats returns the statistics of the last render, ordered by node.
+func (c *Coordinator) GetStats() []NodeStats {
+	stats := make([]NodeStats, 0, len(c.stats))
+	for _, nodeStats := range c.stats {
+		stats = append(stats, *nodeStats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Node < stats[j].Node })
+	return stats
+}
+
+// LocalOnly reports whether the scene has files nodes cannot open, so that
+// it is rendered locally whatever nodes there are.
+func (c *Coordinator) LocalOnly() bool {
+	return c.scene.UsesFiles()
+}
+
+func (c *Coordinator) nodeStats(node string) *NodeStats {
+	stats, ok := c.stats[node]
+	if !ok {
+		stats = &NodeStats{Node: node}
+		c.stats[node] = stats
+	}
+	return stats
+}
+
+// attempt is one try at rendering a job, a chunk or a sample range, on a
+// node.
+type attempt struct {
+	job         int
+	node        string
+	speculative bool
+	scene       *sceneUpload
+	start       time.Time
+	cancel      context.CancelFunc
+}
+
+// sceneUpload is the upload of the scene to a node, started with the node's
+// first attempt. It runs apart from the attempts, which wait for it, so that
+// cancelling one of them does not fail the others.
+type sceneUpload struct {
+	done chan struct{}
+	err  error
+}
+
+// jobResult is a rendered job: a chunk or a sample range.
+type jobResult struct {
+	chunk   *RemoteResult
+	samples *SampleResult
+}
+
+type attemptResult struct {
+	attempt *attempt
+	result  jobResult
+	err     error
+}
+
+// schedule is the state of one render of jobs numbered from 0. render renders
+// a job on a node, or on LocalNode, and collect takes the result of a job's
+// first successful attempt. Only run's goroutine touches the schedule and
+// calls collect; the attempts report back through events.
+type schedule struct {
+	c         *Coordinator
+	ctx       context.Context
+	render    func(ctx context.Context, job int, node string) (jobResult, error)
+	collect   func(job int, node string, result jobResult, elapsed float64) error
+	pending   []int
+	local     []int
+	running   map[int][]*attempt
+	failures  []int
+	done      []bool
+	remaining int
+	load      map[string]int
+	uploads   map[string]*sceneUpload
+	failed    map[string]int
+	durations []float64
+	events    chan attemptResult
+	stopped   chan struct{}
+}
+
+func newSchedule(ctx context.Context, c *Coordinator, jobs int, render func(ctx context.Context, job int, node string) (jobResult, error), collect func(job int, node string, result jobResult, elapsed float64) error) *schedule {
+	s := &schedule{
+		c:         c,
+		ctx:       ctx,
+		render:    render,
+		collect:   collect,
+		running:   make(map[int][]*attempt),
+		failures:  make([]int, jobs),
+		done:      make([]bool, jobs),
+		remaining: jobs,
+		load:      make(map[string]int),
+		uploads:   make(map[string]*sceneUpload),
+		failed:    make(map[string]int),
+		events:    make(chan attemptResult),
+		stopped:   make(chan struct{}),
+	}
+	for i := 0; i < jobs; i++ {
+		s.pending = append(s.pending, i)
+	}
+	return s
+}
+
+func (s *schedule) run() error {
+	defer close(s.stopped)
+	
+	ticker := time.NewTicker(stragglerCheckInterval)
+	defer ticker.Stop()
+	
+	for s.remaining > 0 {
+		s.dispatch()
+		
+		select {
+		case result := <-s.events:
+			if err := s.finish(result); err != nil {
+				s.cancelAll()
+				return err
+			}
+		case <-ticker.C:
+		case <-s.ctx.Done():
+			s.cancelAll()
+			return s.ctx.Err()
+		}
+	}
+	
+	s.cancelAll()
+	return nil
+}
+
+// dispatch starts waiting jobs on nodes with free slots, then stragglers'
+// copies, and one job at a time locally.
+func (s *schedule) dispatch() {
+	if len(s.c.balancer.GetNodes()) == 0 || s.c.LocalOnly() {
+		s.local = append(s.local, s.pending...)
+		s.pending = nil
+	}
+	
+	for len(s.pending) > 0 {
+		node := s.freeNode()
+		if node == "" {
+			break
+		}
+		job := s.pending[0]
+		s.pending = s.pending[1:]
+		s.start(job, node, false)
+	}
+	
+	if len(s.pending) == 0 {
+		s.speculate()
+	}
+	
+	for len(s.local) > 0 && s.load[LocalNode] == 0 {
+		job := s.local[0]
+		s.local = s.local[1:]
+		if !s.done[job] {
+			s.start(job, LocalNode, false)
+		}
+	}
+}
+
+// freeNode selects a node with a free slot other than the excluded ones.
+func (s *schedule) freeNode(exclude ...string) string {
+	for _, node := range s.c.balancer.GetNodes() {
+		if s.load[node] >= s.c.config.SlotsPerNode {
+			exclude = append(exclude, node)
+		}
+	}
+	return s.c.balancer.GetNodeExcept(exclude...)
+}
+
+// speculate starts a second copy of jobs that have been running on one node
+// for much longer than jobs usually take.
+func (s *schedule) speculate() {
+	if len(s.durations) == 0 {
+		return
+	}
+	sorted := slices.Clone(s.durations)
+	slices.Sort(sorted)
+	threshold := s.c.config.StragglerFactor * sorted[len(sorted)/2]
+	
+	jobs := make([]int, 0, len(s.running))
+	for job := range s.running {
+		jobs = append(jobs, job)
+	}
+	slices.Sort(jobs)
+	
+	for _, job := range jobs {
+		attempts := s.running[job]
+		if len(attempts) != 1 || attempts[0].node == LocalNode || time.Since(attempts[0].start).Seconds() < threshold {
+			continue
+		}
+		node := s.freeNode(attempts[0].node)
+		if node == "" {
+			return
+		}
+		s.start(job, node, true)
+	}
+}
+
+func (s *schedule) start(job int, node string, speculative bool) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	if node != LocalNode {
+		ctx, cancel = context.WithTimeout(s.ctx, s.c.config.ChunkTimeout)
+	}
+	
+	a := &attempt{job: job, node: node, speculative: speculative, start: time.Now(), cancel: cancel}
+	if node != LocalNode {
+		a.scene = s.uploads[node]
+		if a.scene == nil {
+			a.scene = &sceneUpload{done: make(chan struct{})}
+			s.uploads[node] = a.scene
+			go s.upload(node, a.scene)
+		}
+	}
+	s.running[job] = append(s.running[job], a)
+	s.addLoad(node, 1)
+	if speculative {
+		s.c.nodeStats(node).Speculative++
+	}
+	
+	go func() {
+		result := attemptResult{attempt: a}
+		if node != LocalNode {
+			result.err = a.scene.wait(ctx)
+		}
+		if result.err == nil {
+			result.result, result.err = s.render(ctx, job, node)
+		}
+		
+		select {
+		case s.events <- result:
+		case <-s.stopped:
+		}
+	}()
+}
+
+// finish records a finished attempt. Failed jobs go back to the queue, or to
+// the local queue after MaxAttempts failures, unless another copy is still
+// running. It returns an error only if a local render or collect fails.
+func (s *schedule) finish(result attemptResult) error {
+	a := result.attempt
+	a.cancel()
+	s.running[a.job] = slices.DeleteFunc(s.running[a.job], func(other *attempt) bool { return other == a })
+	if len(s.running[a.job]) == 0 {
+		delete(s.running, a.job)
+	}
+	s.addLoad(a.node, -1)
+	
+	stats := s.c.nodeStats(a.node)
+	elapsed := time.Since(a.start).Seconds()
+	
+	if s.done[a.job] {
+		stats.Cancelled++
+		return nil
+	}
+	
+	if result.err != nil {
+		if a.node == LocalNode {
+			return fmt.Errorf("failed to render job %d locally: %w", a.job, result.err)
+		}
+		
+		if s.uploads[a.node] == a.scene && a.scene.failed() {
+			delete(s.uploads, a.node)
+		}
+		if errors.Is(result.err, context.Canceled) {
+			// Stopped with the render rather than failed by the node.
+			stats.Cancelled++
+			if len(s.running[a.job]) == 0 {
+				s.pending = append([]int{a.job}, s.pending...)
+			}
+			return nil
+		}
+		
+		stats.Failures++
+		s.failed[a.node]++
+		if s.failed[a.node] >= s.c.config.NodeFailures && !stats.Removed {
+			s.c.balancer.RemoveNode(a.node)
+			stats.Removed = true
+		}
+		
+		s.failures[a.job]++
+		if len(s.running[a.job]) > 0 {
+			return nil
+		}
+		if s.failures[a.job] >= s.c.config.MaxAttempts {
+			s.local = append(s.local, a.job)
+		} else {
+			s.pending = append([]int{a.job}, s.pending...)
+		}
+		return nil
+	}
+	
+	s.failed[a.node] = 0
+	s.done[a.job] = true
+	s.remaining--
+	
+	stats.Chunks++
+	stats.RenderTime += elapsed
+	if a.node != LocalNode {
+		s.durations = append(s.durations, elapsed)
+	}
+	
+	for _, other := range s.running[a.job] {
+		other.cancel()
+	}
+	return s.collect(a.job, a.node, result.result, elapsed)
+}
+
+// upload sends the scene to a node, limited like a chunk by ChunkTimeout.
+func (s *schedule) upload(node string, upload *sceneUpload) {
+	ctx, cancel := context.WithTimeout(s.ctx, s.c.config.ChunkTimeout)
+	defer cancel()
+	
+	_, upload.err = s.c.client.UploadSceneContext(ctx, node, s.c.sceneJSON)
+	close(upload.done)
+}
+
+// wait waits for the upload to finish, or for the attempt's ctx to end.
+func (u *sceneUpload) wait(ctx context.Context) error {
+	select {
+	case <-u.done:
+		return u.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// failed reports whether the upload has finished with an error, so that the
+// node's next attempt should upload again.
+func (u *sceneUpload) failed() bool {
+	select {
+	case <-u.done:
+		return u.err != nil
+	default:
+		return false
+	}
+}
+
+func (s *schedule) addLoad(node string, delta int) {
+	s.load[node] += delta
+	if node != LocalNode {
+		s.c.balancer.AddLoad(node, delta)
+	}
+}
+
+func (s *schedule) cancelAll() {
+	for _, attempts := range s.running {
+		for _, a := range attempts {
+			a.cancel()
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"raytraceGo/internal/renderer"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startNode serves handler as a render node and returns its address.
+func startNode(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestCoordinator(t *testing.T) {
+	const width, height = 70, 50
+	settings := RenderSettings{Samples: 4, MaxDepth: 8, Seed: 5, Integrator: renderer.IntegratorPath}
+	want := renderLocally(t, settings, width, height)
+	
+	good := startNodes(t, 1)[0]
+	failing := startNode(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/render" {
+			http.Error(w, "out of memory", http.StatusInternalServerError)
+			return
+		}
+		NewRemoteRenderServer("", 1).Handler().ServeHTTP(w, r)
+	}))
+	// The slow node takes scenes but never finishes a chunk. It reads the
+	// request so that the server notices when the client gives up.
+	slowServer := NewRemoteRenderServer("", 1)
+	slow := startNode(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/render" {
+			io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+			return
+		}
+		slowServer.Handler().ServeHTTP(w, r)
+	}))
+	
+	nodes := []string{good, failing, slow}
+	config := DefaultCoordinatorConfig()
+	config.TileSize = 16
+	config.NodeFailures = 2
+	config.StragglerFactor = 2
+	config.ChunkTimeout = time.Minute
+	config.LocalWorkers = 2
+	
+	coordinator, err := NewCoordinator(NewDistributedRenderer(context.Background(), nodes), NewLoadBalancer(nodes, &RoundRobinStrategy{}), []byte(testScene), settings, config)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	got, err := coordinator.Render(context.Background(), width, height)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Errorf("Render failed: image differs from the local render")
+	}
+	
+	stats := make(map[string]NodeStats)
+	chunks := 0
+	for _, nodeStats := range coordinator.GetStats() {
+		stats[nodeStats.Node] = nodeStats
+		chunks += nodeStats.Chunks
+	}
+	if chunks != len(SplitFrame(width, height, config.TileSize)) {
+		t.Errorf("Render failed: got %d chunks, want %d", chunks, len(SplitFrame(width, height, config.TileSize)))
+	}
+	if !stats[failing].Removed || stats[failing].Chunks != 0 {
+		t.Errorf("failing node failed: got %+v, want it removed", stats[failing])
+	}
+	if stats[slow].Chunks != 0 || stats[good].Speculative == 0 {
+		t.Errorf("straggler failed: got slow %+v, good %+v, want the slow node's chunks re-executed", stats[slow], stats[good])
+	}
+}
+
+func TestCoordinatorLocalFallback(t *testing.T) {
+	const width, height = 40, 30
+	settings := RenderSettings{Samples: 2, MaxDepth: 4, Seed: 3, Integrator: renderer.IntegratorPath}
+	want := renderLocally(t, settings, width, height)
+	
+	// A node that is down by the time the render starts.
+	server := httptest.NewServer(NewRemoteRenderServer("", 1).Handler())
+	node := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+	nodes := []string{node}
+	
+	config := DefaultCoordinatorConfig()
+	config.TileSize = 16
+	config.LocalWorkers = 2
+	coordinator, err := NewCoordinator(NewDistributedRenderer(context.Background(), nodes), NewLoadBalancer(nodes, &LeastConnectionsStrategy{}), []byte(testScene), settings, config)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	
+	got, err := coordinator.Render(context.Background(), width, height)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Errorf("Render failed: image differs from the local render")
+	}
+	
+	stats := coordinator.GetStats()
+	if len(stats) != 2 || stats[0].Node != node || !stats[0].Removed || stats[1].Chunks != len(SplitFrame(width, height, config.TileSize)) {
+		t.Errorf("GetStats failed: got %+v, want every chunk rendered locally", stats)
+	}
+}
+
+func TestCoordinatorMeshScene(t *testing.T) {
+	// The mesh path is relative to the scene file, not the working directory.
+	dir := t.TempDir()
+	sceneFile := filepath.Join(dir, "scene.json")
+	sceneJSON := []byte(strings.Replace(testScene, `"objects": [`, `"objects": [
+		{"type": "mesh", "file": "tri.obj", "material": {"type": "lambertian", "color": [0.8, 0.2, 0.2]}},`, 1))
+	files := map[string][]byte{
+		"tri.obj":    []byte("v -1 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n"),
+		"scene.json": sceneJSON,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+		
+	// Nodes must not open paths named by scenes from other machines.
+	nodes := startNodes(t, 1)
+	client := NewDistributedRenderer(context.Background(), nodes)
+	if _, err := client.UploadScene(nodes[0], sceneJSON); err == nil {
+		t.Errorf("UploadScene failed: node accepted a scene with a mesh file")
+	}
+	
+	config := DefaultCoordinatorConfig()
+	config.TileSize = 16
+	config.LocalWorkers = 2
+	balancer := NewLoadBalancer(nodes, &LeastConnectionsStrategy{})
+	if _, err := NewCoordinator(client, balancer, sceneJSON, RenderSettings{}, config); err == nil {
+		t.Errorf("NewCoordinator failed: found the mesh relative to the working directory")
+	}
+	coordinator, err := NewCoordinatorFromFile(client, balancer, sceneFile, RenderSettings{Samples: 1, MaxDepth: 2}, config)
+	if err != nil {
+		t.Fatalf("NewCoordinatorFromFile failed: %v", err)
+	}
+	if !coordinator.LocalOnly() {
+		t.Errorf("LocalOnly failed: got false for a scene with a mesh file")
+	}
+	if _, err := coordinator.Render(context.Background(), 32, 24); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if stats := coordinator.GetStats(); len(stats) != 1 || stats[0].Node != LocalNode {
+		t.Errorf("GetStats failed: got %+v, want every chunk rendered locally", stats)
+	}
+}
+
+func TestCoordinatorSamples(t *testing.T) {
+	const width, height = 50, 40
+	settings := RenderSettings{Samples: 6, MaxDepth: 6, Seed: 9, Integrator: renderer.IntegratorPath}
+	want := renderLocally(t, settings, width, height)
+	
+	good := startNodes(t, 1)[0]
+	failing := startNode(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/samples" {
+			http.Error(w, "out of memory", http.StatusInternalServerError)
+			return
+		}
+		NewRemoteRenderServer("", 1).Handler().ServeHTTP(w, r)
+	}))
+	nodes := []string{good, failing}
+	
+	config := DefaultCoordinatorConfig()
+	config.SamplesPerJob = 1
+	config.NodeFailures = 2
+	config.LocalWorkers = 2
+	
+	// Binary sample buffers, then JSON ones.
+	for _, encoding := range []*TileEncoding{&DefaultTileEncoding, nil} {
+		client := NewDistributedRenderer(context.Background(), nodes)
+		client.SetTileEncoding(encoding)
+		coordinator, err := NewCoordinator(client, NewLoadBalancer(nodes, &RoundRobinStrategy{}), []byte(testScene), settings, config)
+		if err != nil {
+			t.Fatalf("NewCoordinator failed: %v", err)
+		}
+		
+		var progress []SampleProgress
+		got, err := coordinator.RenderSamples(context.Background(), width, height, func(p SampleProgress) {
+			progress = append(progress, p)
+		})
+		if err != nil {
+			t.Fatalf("RenderSamples failed: %v", err)
+		}
+		
+		// Merged sums may round differently from a single render's.
+		for i := range want.Pix {
+			if d := int(got.Pix[i]) - int(want.Pix[i]); d < -1 || d > 1 {
+				t.Fatalf("RenderSamples failed: got %d, want %d at %d", got.Pix[i], want.Pix[i], i)
+			}
+		}
+		
+		if len(progress) != 6 || progress[5].Jobs != 6 || progress[5].TotalJobs != 6 || progress[5].Samples != 6 {
+			t.Fatalf("progress failed: got %d reports, want one per sample range", len(progress))
+		}
+		for i := 1; i < len(progress); i++ {
+			if progress[i].Samples <= progress[i-1].Samples {
+				t.Errorf("progress failed: samples went from %d to %d", progress[i-1].Samples, progress[i].Samples)
+			}
+		}
+		
+		samples := 0
+		for _, stats := range coordinator.GetStats() {
+			samples += stats.Samples
+			if stats.Node == failing && (!stats.Removed || stats.Samples != 0) {
+				t.Errorf("failing node failed: got %+v, want it removed", stats)
+			}
+		}
+		if samples != settings.Samples {
+			t.Errorf("GetStats failed: got %d samples, want %d", samples, settings.Samples)
+		}
+		if coordinator.GetFramebuffer() == nil {
+			t.Errorf("GetFramebuffer failed: got nil after RenderSamples")
+		}
+	}
+}
+
+func TestCoordinatorChunkTimeout(t *testing.T) {
+	const width, height = 32, 16
+	settings := RenderSettings{Samples: 1, MaxDepth: 2}
+	
+	// A healthy node that takes a while for every chunk.
+	server := NewRemoteRenderServer("", 1)
+	node := startNode(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/render" {
+			select {
+			case <-time.After(300 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		server.Handler().ServeHTTP(w, r)
+	}))
+	nodes := []string{node}
+	
+	// Only ChunkTimeout limits how long a chunk may take, not the client.
+	if timeout := NewDistributedRenderer(context.Background(), nodes).client.Timeout; timeout != 0 {
+		t.Errorf("NewDistributedRenderer failed: got a client timeout of %v, want none", timeout)
+	}
+	for _, timeout := range []time.Duration{time.Minute, 100 * time.Millisecond} {
+		config := DefaultCoordinatorConfig()
+		config.TileSize = 16
+		config.ChunkTimeout = timeout
+		config.NodeFailures = 1
+		config.LocalWorkers = 1
+		coordinator, err := NewCoordinator(NewDistributedRenderer(context.Background(), nodes), NewLoadBalancer(nodes, &LeastConnectionsStrategy{}), []byte(testScene), settings, config)
+		if err != nil {
+			t.Fatalf("NewCoordinator failed: %v", err)
+		}
+		if _, err := coordinator.Render(context.Background(), width, height); err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		
+		stats := coordinator.GetStats()[0]
+		if slow := timeout < 300*time.Millisecond; stats.Node != node || stats.Removed != slow || (stats.Chunks == 2) == slow {
+			t.Errorf("ChunkTimeout %v failed: got %+v", timeout, stats)
+		}
+	}
+}
+
+func TestCoordinatorUploadOutlivesAttempt(t *testing.T) {
+	// A node that takes a while to store scenes.
+	server := NewRemoteRenderServer("", 1)
+	node := startNode(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/scenes" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		server.Handler().ServeHTTP(w, r)
+	}))
+	nodes := []string{node}
+	
+	config := DefaultCoordinatorConfig()
+	config.NodeFailures = 1
+	coordinator, err := NewCoordinator(NewDistributedRenderer(context.Background(), nodes), NewLoadBalancer(nodes, &LeastConnectionsStrategy{}), []byte(testScene), RenderSettings{}, config)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	render := func(ctx context.Context, job int, node string) (jobResult, error) {
+		return jobResult{}, nil
+	}
+	collect := func(job int, node string, result jobResult, elapsed float64) error {
+		return nil
+	}
+	
+	// Cancelling the attempt that started the upload, as when it is the
+	// losing copy of a straggler, neither fails the upload for the node's
+	// other attempts nor counts against the node.
+	s := newSchedule(context.Background(), coordinator, 2, render, collect)
+	s.start(0, node, true)
+	s.start(1, node, false)
+	s.running[0][0].cancel()
+	for i := 0; i < 2; i++ {
+		result := <-s.events
+		if result.attempt.job == 1 && result.err != nil {
+			t.Errorf("attempt failed: %v", result.err)
+		}
+		s.finish(result)
+	}
+	if stats := coordinator.GetStats()[0]; stats.Failures != 0 || stats.Removed || stats.Chunks != 1 {
+		t.Errorf("GetStats failed: got %+v, want one chunk and no failures", stats)
+	}
+}
+
+func TestCoordinatorConfigValidation(t *testing.T) {
+	for name, change := range map[string]func(*CoordinatorConfig){
+		"tile size":      func(c *CoordinatorConfig) { c.TileSize = 0 },
+		"slots per node": func(c *CoordinatorConfig) { c.SlotsPerNode = 0 },
+		"max attempts":   func(c *CoordinatorConfig) { c.MaxAttempts = 0 },
+		"node failures":  func(c *CoordinatorConfig) { c.NodeFailures = -1 },
+		"chunk timeout":  func(c *CoordinatorConfig) { c.ChunkTimeout = 0 },
+	} {
+		config := DefaultCoordinatorConfig()
+		change(&config)
+		_, err := NewCoordinator(NewDistributedRenderer(context.Background(), nil), NewLoadBalancer(nil, &LeastConnectionsStrategy{}), []byte(testScene), RenderSettings{}, config)
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("NewCoordinator failed: got %v, want an error about the %s", err, name)
+		}
+	}
+	
+	fisheye := strings.Replace(testScene, `"fov": 50`, `"fov": 180, "projection": "fisheye"`, 1)
+	if _, err := NewCoordinator(NewDistributedRenderer(context.Background(), nil), NewLoadBalancer(nil, &LeastConnectionsStrategy{}), []byte(fisheye), RenderSettings{DepthOfField: true}, DefaultCoordinatorConfig()); err == nil {
+		t.Errorf("NewCoordinator failed: accepted depth of field with a fisheye camera")
+	}
+}
+
+func TestCoordinatorPostProcess(t *testing.T) {
+	const width, height = 48, 32
+	settings := RenderSettings{Samples: 2, MaxDepth: 4, Seed: 4, Integrator: renderer.IntegratorPath}
+	sceneJSON := strings.Replace(testScene, `"lights"`, `"postProcess": [{"type": "bloom", "threshold": 0.5}, {"type": "vignette"}], "lights"`, 1)
+	want := renderSceneLocally(t, sceneJSON, settings, width, height)
+	if bytes.Equal(want.Pix, renderLocally(t, settings, width, height).Pix) {
+		t.Fatalf("post-processing failed: image unchanged")
+	}
+	
+	nodes := startNodes(t, 2)
+	config := DefaultCoordinatorConfig()
+	config.TileSize = 16
+	config.LocalWorkers = 2
+	for _, encoding := range []TileEncoding{{Format: TileFloat32}, DefaultTileEncoding, {Format: TileUint8, Compress: true}} {
+		client := NewDistributedRenderer(context.Background(), nodes)
+		client.SetTileEncoding(&encoding)
+		coordinator, err := NewCoordinator(client, NewLoadBalancer(nodes, &RoundRobinStrategy{}), []byte(sceneJSON), settings, config)
+		if err != nil {
+			t.Fatalf("NewCoordinator failed: %v", err)
+		}
+		
+		got, err := coordinator.Render(context.Background(), width, height)
+		if encoding.Format == TileUint8 {
+			if err == nil {
+				t.Errorf("Render failed: post-processed a scene from 8-bit tiles")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		// Half-precision radiance can round a channel to the next level.
+		tolerance := 0
+		if encoding.Format == TileFloat16 {
+			tolerance = 2
+		}
+		for i := range got.Pix {
+			if d := int(got.Pix[i]) - int(want.Pix[i]); d > tolerance || -d > tolerance {
+				t.Errorf("Render %s failed: image differs from the local render by %d at byte %d", encoding.MediaType(), d, i)
+				break
+			}
+		}
+	}
+}
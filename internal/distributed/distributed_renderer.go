@@ -3,9 +3,20 @@ package distributed
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"io"
+	"mime"
 	"net/http"
+	"raytraceGo/internal/output"
+	"raytraceGo/internal/renderer"
+	"raytraceGo/internal/scene"
+	"runtime"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,66 +31,194 @@ type DistributedRenderer struct {
 	nodeLoads    map[string]int
 	loadMutex    sync.RWMutex
 	
+	tileEncoding *TileEncoding
+	
 	remoteJobs   int64
 	localJobs    int64
 	failedJobs   int64
 	startTime    time.Time
 }
 
+// RenderChunk asks a node to render the rectangle from (StartX, StartY) to
+// (EndX, EndY), exclusive, of a Width x Height image. The scene is sent
+// inline as JSON in Scene, or named by SceneHash once uploaded to the node.
 type RenderChunk struct {
-	ID       int    `json:"id"`
-	StartX   int    `json:"start_x"`
-	EndX     int    `json:"end_x"`
-	StartY   int    `json:"start_y"`
-	EndY     int    `json:"end_y"`
-	Width    int    `json:"width"`
-	Height   int    `json:"height"`
-	Scene    string `json:"scene"`
-	Priority int    `json:"priority"`
+	ID        int            `json:"id"`
+	StartX    int            `json:"start_x"`
+	EndX      int            `json:"end_x"`
+	StartY    int            `json:"start_y"`
+	EndY      int            `json:"end_y"`
+	Width     int            `json:"width"`
+	Height    int            `json:"height"`
+	Scene     string         `json:"scene,omitempty"`
+	SceneHash string         `json:"scene_hash,omitempty"`
+	Settings  RenderSettings `json:"settings"`
+	Priority  int            `json:"priority"`
+}
+
+// RenderSettings are the renderer settings a chunk is rendered with. Zero
+// values keep the renderer's defaults, or for the sampler and tone mapping
+// the scene's renderer settings, like the raytracer command.
+type RenderSettings struct {
+	Samples      int    `json:"samples,omitempty"`
+	MaxDepth     int    `json:"max_depth,omitempty"`
+	Seed         uint64 `json:"seed"`
+	Integrator   string `json:"integrator,omitempty"`
+	Sampler      string `json:"sampler,omitempty"`
+	DepthOfField bool   `json:"depth_of_field,omitempty"`
+}
+
+// Rect returns the chunk's rectangle in image coordinates.
+func (c RenderChunk) Rect() image.Rectangle {
+	return image.Rect(c.StartX, c.StartY, c.EndX, c.EndY)
+}
+
+// SplitFrame divides a width x height image into chunks of at most tileSize
+// pixels square, numbered from 0.
+func SplitFrame(width, height, tileSize int) []RenderChunk {
+	var chunks []RenderChunk
+	for y := 0; y < height; y += tileSize {
+		for x := 0; x < width; x += tileSize {
+			chunks = append(chunks, RenderChunk{
+				ID:     len(chunks),
+				StartX: x,
+				EndX:   min(x+tileSize, width),
+				StartY: y,
+				EndY:   min(y+tileSize, height),
+				Width:  width,
+				Height: height,
+			})
+		}
+	}
+	return chunks
 }
 
+// SceneHash is the content hash that names an uploaded scene.
+func SceneHash(sceneJSON []byte) string {
+	sum := sha256.Sum256(sceneJSON)
+	return hex.EncodeToString(sum[:])
+}
+
+// RemoteResult is a rendered chunk. Rect is the chunk's rectangle of the
+// image, and Radiance, for results received in a float TileFormat or
+// rendered locally, its linear radiance and alpha in a chunk-sized
+// framebuffer; neither is part of the JSON format.
 type RemoteResult struct {
-	ChunkID  int       `json:"chunk_id"`
-	Pixels   []Pixel   `json:"pixels"`
-	Duration float64   `json:"duration"`
-	Error    string    `json:"error,omitempty"`
-	NodeID   string    `json:"node_id"`
+	ChunkID  int                 `json:"chunk_id"`
+	Pixels   []Pixel             `json:"pixels"`
+	Duration float64             `json:"duration"`
+	Error    string              `json:"error,omitempty"`
+	NodeID   string              `json:"node_id"`
+	Rect     image.Rectangle     `json:"-"`
+	Radiance *output.Framebuffer `json:"-"`
 }
 
+// Pixel is a tone-mapped pixel of a chunk, at image coordinates.
 type Pixel struct {
-	X, Y int    `json:"x, y"`
-	R, G, B, A uint8 `json:"r, g, b, a"`
+	X int   `json:"x"`
+	Y int   `json:"y"`
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+	A uint8 `json:"a"`
+}
+
+// ComposeFramebuffer places the radiance of the results in a width x height
+// framebuffer, or returns nil if a result has no radiance.
+func ComposeFramebuffer(width, height int, results []RemoteResult) *output.Framebuffer {
+	framebuffer := output.NewFramebuffer(width, height)
+	for _, result := range results {
+		if result.Radiance == nil {
+			return nil
+		}
+		for y := 0; y < result.Radiance.Height; y++ {
+			for x := 0; x < result.Radiance.Width; x++ {
+				px, py := result.Rect.Min.X+x, result.Rect.Min.Y+y
+				if px < width && py < height {
+					framebuffer.Set(px, py, result.Radiance.At(x, y), result.Radiance.Alpha(x, y))
+				}
+			}
+		}
+	}
+	return framebuffer
+}
+
+// ComposeImage draws the pixels of the results into a width x height image.
+func ComposeImage(width, height int, results []RemoteResult) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for _, result := range results {
+		for _, p := range result.Pixels {
+			if !(image.Point{X: p.X, Y: p.Y}).In(img.Rect) {
+				continue
+			}
+			i := img.PixOffset(p.X, p.Y)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = p.R, p.G, p.B, p.A
+		}
+	}
+	return img
 }
 
+// NodeInfo is the status of a node, served at /status and sent with its
+// heartbeats. CPUUsage is the share of the node's CPUs its active jobs' workers
+// occupy, MaxJobs the number of jobs that fill them all and LoadAverage the
+// active jobs relative to MaxJobs.
 type NodeInfo struct {
-	ID           string  `json:"id"`
-	CPUUsage     float64 `json:"cpu_usage"`
-	MemoryUsage  int64   `json:"memory_usage"`
-	ActiveJobs   int     `json:"active_jobs"`
-	MaxJobs      int     `json:"max_jobs"`
-	LoadAverage  float64 `json:"load_average"`
+	ID              string  `json:"id"`
+	Address         string  `json:"address,omitempty"`
+	CPUUsage        float64 `json:"cpu_usage"`
+	MemoryUsage     int64   `json:"memory_usage"`
+	ActiveJobs      int     `json:"active_jobs"`
+	MaxJobs         int     `json:"max_jobs"`
+	LoadAverage     float64 `json:"load_average"`
+	CompletedJobs   int64   `json:"completed_jobs"`
+	PixelsPerSecond float64 `json:"pixels_per_second"`
 }
 
+// statusTimeout limits GetNodeInfo, as status requests have no context of
+// their caller.
+const statusTimeout = 30 * time.Second
+
+// NewDistributedRenderer returns a client for the nodes. Render requests take
+// as long as the node needs unless their context or SetTimeout limits them,
+// as whole-frame sample ranges can take minutes.
 func NewDistributedRenderer(ctx context.Context, nodes []string) *DistributedRenderer {
 	ctx, cancel := context.WithCancel(ctx)
 	
 	return &DistributedRenderer{
 		nodes:      nodes,
-		client:     &http.Client{Timeout: 30 * time.Second},
+		client:     &http.Client{},
 		ctx:        ctx,
 		cancel:     cancel,
 		nodeLoads:  make(map[string]int),
 		startTime:  time.Now(),
+		tileEncoding: &DefaultTileEncoding,
 	}
 }
 
+// SetTileEncoding selects the binary tile encoding nodes send results in; nil
+// asks for JSON, for debugging.
+func (dr *DistributedRenderer) SetTileEncoding(encoding *TileEncoding) {
+	dr.tileEncoding = encoding
+}
+
+// sendsRadiance reports whether nodes send chunks with their radiance.
+func (dr *DistributedRenderer) sendsRadiance() bool {
+	return dr.tileEncoding != nil && dr.tileEncoding.Format != TileUint8
+}
+
 func (dr *DistributedRenderer) RenderChunkRemotely(chunk RenderChunk, nodeAddr string) (*RemoteResult, error) {
+	return dr.RenderChunkContext(dr.ctx, chunk, nodeAddr)
+}
+
+// RenderChunkContext is RenderChunkRemotely with a context that can cancel
+// or time out the request.
+func (dr *DistributedRenderer) RenderChunkContext(ctx context.Context, chunk RenderChunk, nodeAddr string) (*RemoteResult, error) {
 	chunkData, err := json.Marshal(chunk)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal chunk: %w", err)
 	}
 	
-	req, err := http.NewRequestWithContext(dr.ctx, "POST", 
+	req, err := http.NewRequestWithContext(ctx, "POST", 
 		fmt.Sprintf("http://%s/render", nodeAddr), 
 		bytes.NewReader(chunkData))
 	if err != nil {
@@ -87,6 +226,9 @@ func (dr *DistributedRenderer) RenderChunkRemotely(chunk RenderChunk, nodeAddr s
 	}
 	
 	req.Header.Set("Content-Type", "application/json")
+	if dr.tileEncoding != nil {
+		req.Header.Set("Accept", dr.tileEncoding.MediaType()+", application/json;q=0.5")
+	}
 	
 	resp, err := dr.client.Do(req)
 	if err != nil {
@@ -95,16 +237,100 @@ func (dr *DistributedRenderer) RenderChunkRemotely(chunk RenderChunk, nodeAddr s
 	}
 	defer resp.Body.Close()
 	
-	var result RemoteResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	result, err := decodeResult(resp)
+	if err != nil {
 		atomic.AddInt64(&dr.failedJobs, 1)
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	if resp.StatusCode != http.StatusOK || result.Error != "" {
+		atomic.AddInt64(&dr.failedJobs, 1)
+		return nil, fmt.Errorf("node %s failed to render chunk %d: %s", nodeAddr, chunk.ID, result.Error)
+	}
+	if err := checkChunkResult(result, chunk); err != nil {
+		atomic.AddInt64(&dr.failedJobs, 1)
+		return nil, fmt.Errorf("node %s sent a wrong result for chunk %d: %w", nodeAddr, chunk.ID, err)
+	}
 	
 	atomic.AddInt64(&dr.remoteJobs, 1)
+	return result, nil
+}
+
+// checkChunkResult verifies that a result is the chunk's: that a binary
+// tile's rectangle is the chunk's, and that the pixels lie within it. JSON
+// results carry no rectangle and are given the chunk's.
+func checkChunkResult(result *RemoteResult, chunk RenderChunk) error {
+	rect := chunk.Rect()
+	if result.Rect.Empty() {
+		result.Rect = rect
+	}
+	if result.Rect != rect {
+		return fmt.Errorf("got rectangle %v, want %v", result.Rect, rect)
+	}
+	for _, p := range result.Pixels {
+		if !(image.Point{X: p.X, Y: p.Y}).In(rect) {
+			return fmt.Errorf("pixel (%d, %d) is outside %v", p.X, p.Y, rect)
+		}
+	}
+	return nil
+}
+
+// decodeResult decodes a render response as a tile or as JSON, following its
+// content type.
+func decodeResult(resp *http.Response) (*RemoteResult, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode == http.StatusOK && mediaType == TileContentType {
+		return readTile(resp.Body)
+	}
+	
+	var result RemoteResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
 	return &result, nil
 }
 
+// UploadScene stores a scene on a node, so that chunks can name it by its
+// SceneHash instead of carrying it. It returns the hash.
+func (dr *DistributedRenderer) UploadScene(nodeAddr string, sceneJSON []byte) (string, error) {
+	return dr.UploadSceneContext(dr.ctx, nodeAddr, sceneJSON)
+}
+
+// UploadSceneContext is UploadScene with a context that can cancel or time
+// out the request.
+func (dr *DistributedRenderer) UploadSceneContext(ctx context.Context, nodeAddr string, sceneJSON []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		fmt.Sprintf("http://%s/scenes", nodeAddr),
+		bytes.NewReader(sceneJSON))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	
+	resp, err := dr.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload scene: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("node %s rejected scene: %s", nodeAddr, bytes.TrimSpace(message))
+	}
+	
+	var uploaded struct {
+		Hash string `json:"hash"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&uploaded); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	return uploaded.Hash, nil
+}
+
+// SetTimeout limits how long a request to a node may take.
+func (dr *DistributedRenderer) SetTimeout(timeout time.Duration) {
+	dr.client.Timeout = timeout
+}
+
 func (dr *DistributedRenderer) GetOptimalNode() string {
 	dr.loadMutex.RLock()
 	defer dr.loadMutex.RUnlock()
@@ -130,7 +356,10 @@ func (dr *DistributedRenderer) UpdateNodeLoad(nodeID string, load int) {
 }
 
 func (dr *DistributedRenderer) GetNodeInfo(nodeAddr string) (*NodeInfo, error) {
-	req, err := http.NewRequestWithContext(dr.ctx, "GET", 
+	ctx, cancel := context.WithTimeout(dr.ctx, statusTimeout)
+	defer cancel()
+	
+	req, err := http.NewRequestWithContext(ctx, "GET", 
 		fmt.Sprintf("http://%s/status", nodeAddr), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create status request: %w", err)
@@ -216,38 +445,70 @@ func calculateSuccessRate(remote, failed int64) float64 {
 	return float64(remote) / float64(total) * 100
 }
 
+const (
+	// maxRequestSize bounds the bodies of chunks, sample jobs and uploaded
+	// scenes a node reads.
+	maxRequestSize = 64 << 20
+	// maxCachedScenes is how many scenes a node keeps; the oldest is dropped
+	// to make room for another.
+	maxCachedScenes = 32
+)
+
+// RemoteRenderServer renders chunks for a DistributedRenderer with a local
+// ParallelRenderer of the given number of workers. Scenes come from other
+// machines, so those with mesh objects, whose files would be paths there, are
+// rejected rather than opened.
 type RemoteRenderServer struct {
 	port     string
-	renderer interface{} // Local renderer instance
+	workers  int
 	server   *http.Server
 	ctx      context.Context
 	cancel   context.CancelFunc
+	
+	scenes     map[string]*scene.Scene
+	sceneOrder []string
+	scenesMu   sync.RWMutex
+	
+	activeJobs     int64
+	completedJobs  int64
+	renderedPixels int64
+	renderTime     int64
 }
 
-func NewRemoteRenderServer(port string, renderer interface{}) *RemoteRenderServer {
+func NewRemoteRenderServer(port string, workers int) *RemoteRenderServer {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	return &RemoteRenderServer{
 		port:     port,
-		renderer: renderer,
+		workers:  workers,
 		ctx:      ctx,
 		cancel:   cancel,
+		scenes:   make(map[string]*scene.Scene),
 	}
 }
 
 func (rrs *RemoteRenderServer) Start() error {
+	rrs.server = &http.Server{
+		Addr:    ":" + rrs.port,
+		Handler: rrs.Handler(),
+	}
+	
+	return rrs.server.ListenAndServe()
+}
+
+// Handler returns the server's HTTP API, for serving it other than by Start.
+func (rrs *RemoteRenderServer) Handler() http.Handler {
 	mux := http.NewServeMux()
 	
 	mux.HandleFunc("/render", rrs.handleRender)
 	
-	mux.HandleFunc("/status", rrs.handleStatus)
+	mux.HandleFunc("/samples", rrs.handleSamples)
 	
-	rrs.server = &http.Server{
-		Addr:    ":" + rrs.port,
-		Handler: mux,
-	}
+	mux.HandleFunc("/scenes", rrs.handleUploadScene)
 	
-	return rrs.server.ListenAndServe()
+	mux.HandleFunc("/status", rrs.handleStatus)
+	
+	return mux
 }
 
 func (rrs *RemoteRenderServer) Stop() error {
@@ -262,47 +523,238 @@ func (rrs *RemoteRenderServer) handleRender(w http.ResponseWriter, r *http.Reque
 	}
 	
 	var chunk RenderChunk
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 	if err := json.NewDecoder(r.Body).Decode(&chunk); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		rrs.writeResult(w, bodyStatus(err), RemoteResult{Error: "Invalid request body"})
 		return
 	}
 	
 	start := time.Now()
 	
-	time.Sleep(100 * time.Millisecond)
+	s, status, err := rrs.requestScene(chunk.Scene, chunk.SceneHash)
+	if err != nil {
+		rrs.writeResult(w, status, RemoteResult{ChunkID: chunk.ID, Error: err.Error()})
+		return
+	}
+	
+	encoding, binaryTile, err := acceptedTileEncoding(r.Header.Get("Accept"))
+	if err != nil {
+		rrs.writeResult(w, http.StatusNotAcceptable, RemoteResult{ChunkID: chunk.ID, Error: err.Error()})
+		return
+	}
+	
+	atomic.AddInt64(&rrs.activeJobs, 1)
+	t, err := renderChunk(r.Context(), chunk, s, rrs.workers)
+	atomic.AddInt64(&rrs.activeJobs, -1)
+	if err != nil {
+		rrs.writeResult(w, http.StatusInternalServerError, RemoteResult{ChunkID: chunk.ID, Error: err.Error()})
+		return
+	}
+	
+	atomic.AddInt64(&rrs.completedJobs, 1)
+	atomic.AddInt64(&rrs.renderedPixels, int64(t.rect.Dx()*t.rect.Dy()))
+	atomic.AddInt64(&rrs.renderTime, int64(time.Since(start)))
 	
 	result := RemoteResult{
 		ChunkID:  chunk.ID,
 		Duration: time.Since(start).Seconds(),
 		NodeID:   "node-" + rrs.port,
-		Pixels:   []Pixel{}, // In real implementation, this would contain actual pixels
 	}
-	
+	if binaryTile {
+		var body bytes.Buffer
+		if err := writeTile(&body, t, result, encoding); err != nil {
+			rrs.writeResult(w, http.StatusInternalServerError, RemoteResult{ChunkID: chunk.ID, Error: err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", encoding.MediaType())
+		w.Write(body.Bytes())
+		return
+	}
+	result.Pixels = t.pixels()
+	rrs.writeResult(w, http.StatusOK, result)
+}
+
+func (rrs *RemoteRenderServer) writeResult(w http.ResponseWriter, status int, result RemoteResult) {
+	result.NodeID = "node-" + rrs.port
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(result)
 }
 
-func (rrs *RemoteRenderServer) handleStatus(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
+// requestScene returns a request's inline scene, caching it like an upload,
+// or the uploaded scene its hash names, with the HTTP status of any error.
+func (rrs *RemoteRenderServer) requestScene(inline, hash string) (*scene.Scene, int, error) {
+	if inline != "" {
+		s, _, err := rrs.addScene([]byte(inline))
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return s, http.StatusOK, nil
+	}
+	
+	rrs.scenesMu.RLock()
+	s, ok := rrs.scenes[hash]
+	rrs.scenesMu.RUnlock()
+	if !ok {
+		return nil, http.StatusNotFound, fmt.Errorf("unknown scene %q", hash)
+	}
+	return s, http.StatusOK, nil
+}
+
+func (rrs *RemoteRenderServer) addScene(sceneJSON []byte) (*scene.Scene, string, error) {
+	hash := SceneHash(sceneJSON)
+	
+	rrs.scenesMu.RLock()
+	s, ok := rrs.scenes[hash]
+	rrs.scenesMu.RUnlock()
+	if ok {
+		return s, hash, nil
+	}
+	
+	s, err := scene.ParseWithoutFiles(sceneJSON)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid scene: %w", err)
+	}
+	
+	rrs.scenesMu.Lock()
+	defer rrs.scenesMu.Unlock()
+	if _, ok := rrs.scenes[hash]; !ok {
+		if len(rrs.sceneOrder) == maxCachedScenes {
+			delete(rrs.scenes, rrs.sceneOrder[0])
+			rrs.sceneOrder = rrs.sceneOrder[1:]
+		}
+		rrs.scenes[hash] = s
+		rrs.sceneOrder = append(rrs.sceneOrder, hash)
+	}
+	return s, hash, nil
+}
+
+// bodyStatus is the HTTP status for an error reading a request body.
+func bodyStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// renderChunk renders the chunk's rectangle and returns its pixels tone
+// mapped, in image coordinates.
+func renderChunk(ctx context.Context, chunk RenderChunk, s *scene.Scene, workers int) (*tile, error) {
+	rect := chunk.Rect()
+	if rect.Empty() || rect.Dx() > maxTilePixels/rect.Dy() {
+		return nil, fmt.Errorf("invalid chunk %v", rect)
+	}
+	r, err := newRenderer(chunk.Settings, s, workers)
+	if err != nil {
+		return nil, err
+	}
+	
+	framebuffer, err := r.RenderRegion(ctx, s, chunk.Width, chunk.Height, rect)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &tile{rect: rect, img: r.ToneMap(framebuffer), radiance: framebuffer}, nil
+}
+
+// newRenderer returns a renderer for the scene with the settings.
+func newRenderer(settings RenderSettings, s *scene.Scene, workers int) (*renderer.ParallelRenderer, error) {
+	r := renderer.NewParallelRenderer(workers)
+	if settings.Samples > 0 {
+		r.SetSamples(settings.Samples)
+	}
+	if settings.MaxDepth > 0 {
+		r.SetMaxDepth(settings.MaxDepth)
+	}
+	r.SetSeed(settings.Seed)
+	r.SetDepthOfField(settings.DepthOfField)
+	if settings.Integrator != "" {
+		if err := r.SetIntegrator(settings.Integrator); err != nil {
+			return nil, err
+		}
+	}
+	sampler := settings.Sampler
+	if sampler == "" {
+		sampler = s.GetRenderSettings().Sampler
+	}
+	if sampler != "" {
+		if err := r.SetSampler(sampler); err != nil {
+			return nil, err
+		}
+	}
+	if toneMapping := s.GetRenderSettings().ToneMapping; toneMapping != nil {
+		if err := r.SetToneMapping(*toneMapping); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// handleUploadScene stores the scene in the request body and returns its
+// hash for RenderChunk.SceneHash.
+func (rrs *RemoteRenderServer) handleUploadScene(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	
-	nodeInfo := NodeInfo{
-		ID:          "node-" + rrs.port,
-		CPUUsage:    50.0, // In real implementation, get actual CPU usage
-		MemoryUsage: 1024 * 1024 * 100, // 100MB
-		ActiveJobs:  5,
-		MaxJobs:     10,
-		LoadAverage: 0.5,
+	sceneJSON, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, "Invalid request body", bodyStatus(err))
+		return
+	}
+	
+	_, hash, err := rrs.addScene(sceneJSON)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"hash": hash})
+}
+
+func (rrs *RemoteRenderServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(nodeInfo)
+	json.NewEncoder(w).Encode(rrs.nodeInfo(""))
+}
+
+// nodeInfo reports the server's status as the node at address.
+func (rrs *RemoteRenderServer) nodeInfo(address string) NodeInfo {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	
+	cpus := runtime.NumCPU()
+	workers := max(rrs.workers, 1)
+	active := int(atomic.LoadInt64(&rrs.activeJobs))
+	maxJobs := max(cpus/workers, 1)
+	
+	info := NodeInfo{
+		ID:            "node-" + rrs.port,
+		Address:       address,
+		CPUUsage:      min(float64(active*workers)/float64(cpus)*100, 100),
+		MemoryUsage:   int64(m.HeapAlloc),
+		ActiveJobs:    active,
+		MaxJobs:       maxJobs,
+		LoadAverage:   float64(active) / float64(maxJobs),
+		CompletedJobs: atomic.LoadInt64(&rrs.completedJobs),
+	}
+	if renderTime := time.Duration(atomic.LoadInt64(&rrs.renderTime)); renderTime > 0 {
+		info.PixelsPerSecond = float64(atomic.LoadInt64(&rrs.renderedPixels)) / renderTime.Seconds()
+	}
+	return info
 }
 
 type LoadBalancer struct {
 	nodes    []string
+	loads    map[string]int // as reported by the nodes
+	assigned map[string]int // as counted by the coordinator
 	strategy LoadBalancingStrategy
 	mu       sync.RWMutex
 }
@@ -324,6 +776,8 @@ func (rr *RoundRobinStrategy) SelectNode(nodes []string, loads map[string]int) s
 		return ""
 	}
 	
+	// The node list can shrink between calls.
+	rr.current %= len(nodes)
 	node := nodes[rr.current]
 	rr.current = (rr.current + 1) % len(nodes)
 	return node
@@ -351,19 +805,81 @@ func (lc *LeastConnectionsStrategy) SelectNode(nodes []string, loads map[string]
 
 func NewLoadBalancer(nodes []string, strategy LoadBalancingStrategy) *LoadBalancer {
 	return &LoadBalancer{
-		nodes:    nodes,
+		nodes:    append([]string(nil), nodes...),
+		loads:    make(map[string]int),
+		assigned: make(map[string]int),
 		strategy: strategy,
 	}
 }
 
 func (lb *LoadBalancer) GetNode() string {
+	return lb.GetNodeExcept()
+}
+
+// GetNodeExcept selects a node other than the excluded ones, or returns ""
+// if there is none.
+func (lb *LoadBalancer) GetNodeExcept(exclude ...string) string {
 	lb.mu.RLock()
 	defer lb.mu.RUnlock()
 	
+	nodes := make([]string, 0, len(lb.nodes))
 	loads := make(map[string]int)
 	for _, node := range lb.nodes {
-		loads[node] = 0 // Placeholder
+		if slices.Contains(exclude, node) {
+			continue
+		}
+		nodes = append(nodes, node)
+		// Jobs sent since a node's last report are not in it yet, and a
+		// node can be busy with jobs from other coordinators.
+		loads[node] = max(lb.loads[node], lb.assigned[node])
 	}
 	
-	return lb.strategy.SelectNode(lb.nodes, loads)
+	return lb.strategy.SelectNode(nodes, loads)
+}
+
+// UpdateLoad records the number of jobs a node reports it is working on.
+func (lb *LoadBalancer) UpdateLoad(node string, load int) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	
+	lb.loads[node] = load
+}
+
+// AddLoad changes the number of jobs sent to a node and not yet finished by
+// delta. A node's load is the larger of this and the load it reports with
+// UpdateLoad, which does not replace it.
+func (lb *LoadBalancer) AddLoad(node string, delta int) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	
+	lb.assigned[node] = max(lb.assigned[node]+delta, 0)
+}
+
+// AddNode makes a node selectable, if it is not already.
+func (lb *LoadBalancer) AddNode(node string) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	
+	if !slices.Contains(lb.nodes, node) {
+		lb.nodes = append(lb.nodes, node)
+	}
+}
+
+// RemoveNode stops a node from being selected.
+func (lb *LoadBalancer) RemoveNode(node string) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	
+	lb.nodes = slices.DeleteFunc(lb.nodes, func(n string) bool { return n == node })
+	// The jobs assigned to it stay counted until they finish, in case it
+	// comes back before then.
+	delete(lb.loads, node)
+}
+
+// GetNodes returns the nodes that can be selected.
+func (lb *LoadBalancer) GetNodes() []string {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	
+	return append([]string(nil), lb.nodes...)
 } 
\ No newline at end of file
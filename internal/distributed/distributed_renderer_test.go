@@ -0,0 +1,206 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"raytraceGo/internal/renderer"
+	"raytraceGo/internal/scene"
+	"strings"
+	"testing"
+)
+
+const testScene = `{
+	"camera": {"position": [0, 1, -4], "lookAt": [0, 0.5, 0], "fov": 50},
+	"objects": [
+		{"type": "plane", "position": [0, 0, 0], "normal": [0, 1, 0], "material": {"type": "lambertian", "color": [0.7, 0.7, 0.7]}},
+		{"type": "sphere", "position": [-0.6, 0.5, 0], "radius": 0.5, "material": {"type": "metal", "color": [0.9, 0.8, 0.6], "roughness": 0.3}},
+		{"type": "sphere", "position": [0.6, 0.5, 0], "radius": 0.5, "material": {"type": "lambertian", "color": [0.2, 0.4, 0.8]}}
+	],
+	"lights": [
+		{"type": "point", "position": [2, 4, -2], "color": [1, 1, 1], "intensity": 20}
+	]
+}`
+
+// startNodes serves render nodes with httptest and returns their addresses.
+func startNodes(t *testing.T, count int) []string {
+	t.Helper()
+	
+	var nodes []string
+	for i := 0; i < count; i++ {
+		server := httptest.NewServer(NewRemoteRenderServer("", 2).Handler())
+		t.Cleanup(server.Close)
+		nodes = append(nodes, strings.TrimPrefix(server.URL, "http://"))
+	}
+	return nodes
+}
+
+// renderLocally renders testScene in one piece for comparison.
+func renderLocally(t *testing.T, settings RenderSettings, width, height int) *image.RGBA {
+	t.Helper()
+	
+	return renderSceneLocally(t, testScene, settings, width, height)
+}
+
+func renderSceneLocally(t *testing.T, sceneJSON string, settings RenderSettings, width, height int) *image.RGBA {
+	t.Helper()
+	
+	s, err := scene.Parse([]byte(sceneJSON))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	local := renderer.NewParallelRenderer(3)
+	local.SetSamples(settings.Samples)
+	local.SetMaxDepth(settings.MaxDepth)
+	local.SetSeed(settings.Seed)
+	if err := local.SetIntegrator(settings.Integrator); err != nil {
+		t.Fatal(err)
+	}
+	img, err := local.Render(s, width, height)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	return img
+}
+
+func TestRemoteRenderMatchesLocal(t *testing.T) {
+	const width, height = 70, 50
+	settings := RenderSettings{Samples: 4, MaxDepth: 8, Seed: 11, Integrator: renderer.IntegratorPath}
+	want := renderLocally(t, settings, width, height)
+	
+	nodes := startNodes(t, 2)
+	dr := NewDistributedRenderer(context.Background(), nodes)
+	
+	// The first node gets the scene uploaded, the second inline.
+	hash, err := dr.UploadScene(nodes[0], []byte(testScene))
+	if err != nil {
+		t.Fatalf("UploadScene failed: %v", err)
+	}
+	if hash != SceneHash([]byte(testScene)) {
+		t.Errorf("UploadScene failed: got hash %s, want %s", hash, SceneHash([]byte(testScene)))
+	}
+	
+	var results []RemoteResult
+	for i, chunk := range SplitFrame(width, height, 32) {
+		chunk.Settings = settings
+		node := nodes[i%len(nodes)]
+		if i%len(nodes) == 0 {
+			chunk.SceneHash = hash
+		} else {
+			chunk.Scene = testScene
+		}
+		
+		result, err := dr.RenderChunkRemotely(chunk, node)
+		if err != nil {
+			t.Fatalf("RenderChunkRemotely failed: %v", err)
+		}
+		if len(result.Pixels) != chunk.Rect().Dx()*chunk.Rect().Dy() {
+			t.Fatalf("chunk %d failed: got %d pixels, want %d", chunk.ID, len(result.Pixels), chunk.Rect().Dx()*chunk.Rect().Dy())
+		}
+		results = append(results, *result)
+	}
+	
+	if got := ComposeImage(width, height, results); !bytes.Equal(got.Pix, want.Pix) {
+		t.Errorf("image rendered remotely differs from the local render")
+	}
+}
+
+func TestRemoteRenderErrors(t *testing.T) {
+	nodes := startNodes(t, 1)
+	dr := NewDistributedRenderer(context.Background(), nodes)
+	
+	chunk := SplitFrame(40, 30, 32)[0]
+	chunk.SceneHash = SceneHash([]byte("not uploaded"))
+	if _, err := dr.RenderChunkRemotely(chunk, nodes[0]); err == nil {
+		t.Errorf("RenderChunkRemotely failed: rendered an unknown scene hash")
+	}
+	
+	chunk.SceneHash = ""
+	chunk.Scene = testScene
+	chunk.EndX = 50
+	if _, err := dr.RenderChunkRemotely(chunk, nodes[0]); err == nil {
+		t.Errorf("RenderChunkRemotely failed: rendered a chunk outside the image")
+	}
+	
+	chunk.StartX, chunk.StartY, chunk.EndX, chunk.EndY = 0, 0, 1<<14, 1<<13
+	chunk.Width, chunk.Height = 1<<14, 1<<13
+	if _, err := dr.RenderChunkRemotely(chunk, nodes[0]); err == nil {
+		t.Errorf("RenderChunkRemotely failed: rendered a chunk larger than a tile can be")
+	}
+	
+	if _, err := dr.UploadScene(nodes[0], []byte(`{"objects": 1}`)); err == nil {
+		t.Errorf("UploadScene failed: accepted an invalid scene")
+	}
+}
+
+func TestRemoteRenderServerLimits(t *testing.T) {
+	rrs := NewRemoteRenderServer("", 1)
+	handler := rrs.Handler()
+	
+	body := strings.NewReader(strings.Repeat(" ", maxRequestSize+1))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/scenes", body))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("upload of an oversized scene failed: got status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	
+	// The scene cache keeps the latest maxCachedScenes scenes.
+	var hashes []string
+	for i := 0; i <= maxCachedScenes; i++ {
+		sceneJSON := strings.Replace(testScene, `"fov": 50`, fmt.Sprintf(`"fov": %d`, 30+i), 1)
+		_, hash, err := rrs.addScene([]byte(sceneJSON))
+		if err != nil {
+			t.Fatalf("addScene failed: %v", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	if len(rrs.scenes) != maxCachedScenes {
+		t.Errorf("scene cache failed: got %d scenes, want %d", len(rrs.scenes), maxCachedScenes)
+	}
+	if _, _, err := rrs.requestScene("", hashes[0]); err == nil {
+		t.Errorf("scene cache failed: kept the oldest scene")
+	}
+	if _, _, err := rrs.requestScene("", hashes[maxCachedScenes]); err != nil {
+		t.Errorf("scene cache failed: %v", err)
+	}
+}
+
+func TestRemoteRenderRejectsWrongResults(t *testing.T) {
+	// The node answers every chunk with the same tile, and every sample job
+	// with a range one sample later than asked for.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/samples" {
+			var job SampleJob
+			json.NewDecoder(r.Body).Decode(&job)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(SampleResult{
+				JobID:       job.ID,
+				FirstSample: job.FirstSample + 1,
+				Samples:     job.Samples,
+				Buffer:      renderer.NewSampleBuffer(job.Width, job.Height),
+			})
+			return
+		}
+		w.Header().Set("Content-Type", DefaultTileEncoding.MediaType())
+		writeTile(w, testTile(), RemoteResult{}, DefaultTileEncoding)
+	}))
+	t.Cleanup(server.Close)
+	node := strings.TrimPrefix(server.URL, "http://")
+	dr := NewDistributedRenderer(context.Background(), []string{node})
+	
+	chunk := SplitFrame(40, 30, 32)[0]
+	chunk.Scene = testScene
+	if _, err := dr.RenderChunkRemotely(chunk, node); err == nil {
+		t.Errorf("RenderChunkRemotely failed: accepted a tile of another rectangle")
+	}
+	
+	job := SplitSamples(40, 30, 8, 4)[0]
+	job.Scene = testScene
+	if _, err := dr.RenderSamplesContext(context.Background(), job, node); err == nil {
+		t.Errorf("RenderSamplesContext failed: accepted another sample range")
+	}
+}
@@ -0,0 +1,239 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHeartbeatInterval is how often nodes report to a NodeRegistry.
+const DefaultHeartbeatInterval = 2 * time.Second
+
+// maxNodeInfoSize bounds the bodies of registrations and heartbeats.
+const maxNodeInfoSize = 64 << 10
+
+// RegisteredNode is a node known to a NodeRegistry with its latest status.
+type RegisteredNode struct {
+	NodeInfo
+	Registered time.Time `json:"registered"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// NodeRegistry lets render nodes join a farm while it runs. Nodes register at
+// /register with the address they are reached at, then post their status to
+// /heartbeat; /nodes lists them. Registered nodes are added to the registry's
+// LoadBalancer with their active jobs as load, and removed from it when their
+// heartbeats stop for longer than the timeout.
+type NodeRegistry struct {
+	balancer *LoadBalancer
+	timeout  time.Duration
+	nodes    map[string]*RegisteredNode
+	mu       sync.RWMutex
+}
+
+func NewNodeRegistry(balancer *LoadBalancer, timeout time.Duration) *NodeRegistry {
+	return &NodeRegistry{
+		balancer: balancer,
+		timeout:  timeout,
+		nodes:    make(map[string]*RegisteredNode),
+	}
+}
+
+// Handler returns the registry's HTTP API.
+func (nr *NodeRegistry) Handler() http.Handler {
+	mux := http.NewServeMux()
+	
+	mux.HandleFunc("/register", nr.handleRegister)
+	
+	mux.HandleFunc("/heartbeat", nr.handleHeartbeat)
+	
+	mux.HandleFunc("/nodes", nr.handleNodes)
+	
+	return mux
+}
+
+// Start evicts dead nodes until ctx is done.
+func (nr *NodeRegistry) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(nr.timeout / 2)
+		defer ticker.Stop()
+		
+		for {
+			select {
+			case <-ticker.C:
+				nr.evict(time.Now())
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Register adds a node, or refreshes it if it registers again.
+func (nr *NodeRegistry) Register(info NodeInfo) {
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+	
+	now := time.Now()
+	node, ok := nr.nodes[info.Address]
+	if !ok {
+		node = &RegisteredNode{Registered: now}
+		nr.nodes[info.Address] = node
+	}
+	node.NodeInfo = info
+	node.LastSeen = now
+	
+	nr.balancer.AddNode(info.Address)
+	nr.balancer.UpdateLoad(info.Address, info.ActiveJobs)
+}
+
+// Heartbeat records a registered node's status. It returns false for nodes
+// that are not registered, such as evicted ones, which must register again.
+func (nr *NodeRegistry) Heartbeat(info NodeInfo) bool {
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+	
+	node, ok := nr.nodes[info.Address]
+	if !ok {
+		return false
+	}
+	node.NodeInfo = info
+	node.LastSeen = time.Now()
+	
+	nr.balancer.UpdateLoad(info.Address, info.ActiveJobs)
+	return true
+}
+
+// GetNodes returns the registered nodes, ordered by address.
+func (nr *NodeRegistry) GetNodes() []RegisteredNode {
+	nr.mu.RLock()
+	defer nr.mu.RUnlock()
+	
+	nodes := make([]RegisteredNode, 0, len(nr.nodes))
+	for _, node := range nr.nodes {
+		nodes = append(nodes, *node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Address < nodes[j].Address })
+	return nodes
+}
+
+// evict removes the nodes not heard from within the timeout before now.
+func (nr *NodeRegistry) evict(now time.Time) {
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+	
+	for address, node := range nr.nodes {
+		if now.Sub(node.LastSeen) > nr.timeout {
+			delete(nr.nodes, address)
+			nr.balancer.RemoveNode(address)
+		}
+	}
+}
+
+func (nr *NodeRegistry) handleRegister(w http.ResponseWriter, r *http.Request) {
+	info, ok := decodeNodeInfo(w, r)
+	if !ok {
+		return
+	}
+	
+	nr.Register(info)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (nr *NodeRegistry) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	info, ok := decodeNodeInfo(w, r)
+	if !ok {
+		return
+	}
+	
+	if !nr.Heartbeat(info) {
+		http.Error(w, "node is not registered", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (nr *NodeRegistry) handleNodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(nr.GetNodes())
+}
+
+func decodeNodeInfo(w http.ResponseWriter, r *http.Request) (NodeInfo, bool) {
+	var info NodeInfo
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return info, false
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxNodeInfoSize)).Decode(&info)
+	if err != nil || info.Address == "" {
+		http.Error(w, "Invalid request body", bodyStatus(err))
+		return info, false
+	}
+	return info, true
+}
+
+// Announce registers the server with the NodeRegistry at registryAddr as the
+// node at address, which the registry's coordinator must be able to reach,
+// then sends a heartbeat every interval until ctx is done. It registers again
+// if the registry has evicted it and keeps trying while the registry is down.
+func (rrs *RemoteRenderServer) Announce(ctx context.Context, registryAddr, address string, interval time.Duration) {
+	client := &http.Client{Timeout: interval}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	
+	registered := false
+	for {
+		path := "/heartbeat"
+		if !registered {
+			path = "/register"
+		}
+		status, err := postNodeInfo(ctx, client, fmt.Sprintf("http://%s%s", registryAddr, path), rrs.nodeInfo(address))
+		if err == nil && status == http.StatusNoContent {
+			if !registered {
+				fmt.Printf("Registered with %s as %s\n", registryAddr, address)
+			}
+			registered = true
+		} else if registered {
+			registered = false
+			if status == http.StatusNotFound {
+				// Evicted; register again right away.
+				continue
+			}
+		}
+		
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func postNodeInfo(ctx context.Context, client *http.Client, url string, info NodeInfo) (int, error) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
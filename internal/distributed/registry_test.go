@@ -0,0 +1,125 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNodeRegistry(t *testing.T) {
+	balancer := NewLoadBalancer(nil, &LeastConnectionsStrategy{})
+	registry := NewNodeRegistry(balancer, time.Minute)
+	registryServer := httptest.NewServer(registry.Handler())
+	t.Cleanup(registryServer.Close)
+	
+	server := NewRemoteRenderServer("", 1)
+	nodeServer := httptest.NewServer(server.Handler())
+	t.Cleanup(nodeServer.Close)
+	node := strings.TrimPrefix(nodeServer.URL, "http://")
+	
+	// Render a chunk so that the node has something to report.
+	dr := NewDistributedRenderer(context.Background(), []string{node})
+	chunk := SplitFrame(20, 10, 32)[0]
+	chunk.Scene = testScene
+	chunk.Settings = RenderSettings{Samples: 1, MaxDepth: 2}
+	if _, err := dr.RenderChunkRemotely(chunk, node); err != nil {
+		t.Fatalf("RenderChunkRemotely failed: %v", err)
+	}
+	
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Announce(ctx, strings.TrimPrefix(registryServer.URL, "http://"), node, 10*time.Millisecond)
+	
+	waitFor(t, "registration", func() bool { return slices.Contains(balancer.GetNodes(), node) })
+	
+	resp, err := http.Get(registryServer.URL + "/nodes")
+	if err != nil {
+		t.Fatalf("GET /nodes failed: %v", err)
+	}
+	var nodes []RegisteredNode
+	err = json.NewDecoder(resp.Body).Decode(&nodes)
+	resp.Body.Close()
+	if err != nil || len(nodes) != 1 {
+		t.Fatalf("GET /nodes failed: got %+v, %v, want one node", nodes, err)
+	}
+	if nodes[0].Address != node || nodes[0].CompletedJobs != 1 || nodes[0].PixelsPerSecond <= 0 || nodes[0].ActiveJobs != 0 {
+		t.Errorf("GET /nodes failed: got %+v, want the node's real status", nodes[0])
+	}
+	
+	// An evicted node leaves the balancer and registers again with its next
+	// heartbeat.
+	registry.evict(time.Now().Add(time.Hour))
+	if len(registry.GetNodes()) != 0 || slices.Contains(balancer.GetNodes(), node) {
+		t.Errorf("evict failed: node still registered")
+	}
+	waitFor(t, "registration after eviction", func() bool { return len(registry.GetNodes()) == 1 })
+	if !slices.Contains(balancer.GetNodes(), node) {
+		t.Errorf("Register failed: node not added to the balancer again")
+	}
+	
+	if registry.Heartbeat(NodeInfo{Address: "unknown:1"}) {
+		t.Errorf("Heartbeat failed: accepted an unregistered node")
+	}
+}
+
+func TestLeastConnectionsUsesReportedLoad(t *testing.T) {
+	balancer := NewLoadBalancer(nil, &LeastConnectionsStrategy{})
+	registry := NewNodeRegistry(balancer, time.Minute)
+	
+	registry.Register(NodeInfo{Address: "a:1", ActiveJobs: 3})
+	registry.Register(NodeInfo{Address: "b:1", ActiveJobs: 1})
+	if got := balancer.GetNode(); got != "b:1" {
+		t.Errorf("GetNode failed: got %s, want b:1", got)
+	}
+	
+	registry.Heartbeat(NodeInfo{Address: "b:1", ActiveJobs: 5})
+	if got := balancer.GetNode(); got != "a:1" {
+		t.Errorf("GetNode failed: got %s, want a:1", got)
+	}
+	
+	// A heartbeat sent before the coordinator's jobs reached the node must not
+	// hide them.
+	balancer.AddLoad("a:1", 4)
+	registry.Heartbeat(NodeInfo{Address: "a:1", ActiveJobs: 0})
+	registry.Heartbeat(NodeInfo{Address: "b:1", ActiveJobs: 2})
+	if got := balancer.GetNode(); got != "b:1" {
+		t.Errorf("GetNode failed: got %s, want b:1", got)
+	}
+	balancer.AddLoad("a:1", -4)
+	if got := balancer.GetNode(); got != "a:1" {
+		t.Errorf("GetNode failed: got %s, want a:1", got)
+	}
+}
+
+func TestNodeRegistryRejectsLargeBodies(t *testing.T) {
+	registry := NewNodeRegistry(NewLoadBalancer(nil, &LeastConnectionsStrategy{}), time.Minute)
+	
+	body := `{"address": "a:1", "id": "` + strings.Repeat("x", maxNodeInfoSize) + `"}`
+	for _, path := range []string{"/register", "/heartbeat"} {
+		w := httptest.NewRecorder()
+		registry.Handler().ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("POST %s failed: got status %d, want %d", path, w.Code, http.StatusRequestEntityTooLarge)
+		}
+	}
+	if len(registry.GetNodes()) != 0 {
+		t.Errorf("POST /register failed: registered a node from an oversized body")
+	}
+}
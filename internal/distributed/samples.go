@@ -0,0 +1,344 @@
+package distributed
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	stdmath "math"
+	"mime"
+	"net/http"
+	"raytraceGo/internal/renderer"
+	"raytraceGo/internal/scene"
+	"sync/atomic"
+	"time"
+)
+
+// SampleContentType is the media type of binary sample buffers, asked for
+// like tiles with a compression parameter.
+const SampleContentType = "application/x-raytrace-samples"
+
+// A sample buffer starts with a fixed header followed by the node ID and the
+// payload:
+//
+//	magic "RTSB", version, flags, two reserved bytes
+//	job ID, first sample, samples, width, height  uint32 each
+//	duration                                      float64
+//	payload length, CRC-32                        uint32 each
+//	node ID length                                uint16
+//
+// all little-endian, with the flags and checksum of tiles. The payload holds
+// the buffer's sums as float64 followed by its sample and covered counts as
+// uint32.
+const (
+	sampleMagic      = "RTSB"
+	sampleVersion    = 1
+	sampleHeaderSize = 46
+)
+
+// SampleJob asks a node to render samples FirstSample to
+// FirstSample+Samples-1 of every pixel of a Width x Height image, out of the
+// Settings.Samples samples per pixel of the whole render. The scene is sent
+// as in a RenderChunk.
+type SampleJob struct {
+	ID          int            `json:"id"`
+	FirstSample int            `json:"first_sample"`
+	Samples     int            `json:"samples"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	Scene       string         `json:"scene,omitempty"`
+	SceneHash   string         `json:"scene_hash,omitempty"`
+	Settings    RenderSettings `json:"settings"`
+}
+
+// SampleResult is a rendered sample range.
+type SampleResult struct {
+	JobID       int                    `json:"job_id"`
+	FirstSample int                    `json:"first_sample"`
+	Samples     int                    `json:"samples"`
+	Buffer      *renderer.SampleBuffer `json:"buffer,omitempty"`
+	Duration    float64                `json:"duration"`
+	Error       string                 `json:"error,omitempty"`
+	NodeID      string                 `json:"node_id"`
+}
+
+// SplitSamples divides the samples per pixel of a width x height render into
+// jobs of at most perJob samples, numbered from 0.
+func SplitSamples(width, height, samples, perJob int) []SampleJob {
+	var jobs []SampleJob
+	for first := 0; first < samples; first += perJob {
+		jobs = append(jobs, SampleJob{
+			ID:          len(jobs),
+			FirstSample: first,
+			Samples:     min(perJob, samples-first),
+			Width:       width,
+			Height:      height,
+		})
+	}
+	return jobs
+}
+
+// RenderSamplesContext asks a node to render a sample range. Nodes send the
+// buffer in binary unless the client asks for JSON with a nil TileEncoding,
+// compressed if the tile encoding is.
+func (dr *DistributedRenderer) RenderSamplesContext(ctx context.Context, job SampleJob, nodeAddr string) (*SampleResult, error) {
+	jobData, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sample job: %w", err)
+	}
+	
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		fmt.Sprintf("http://%s/samples", nodeAddr),
+		bytes.NewReader(jobData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	
+	req.Header.Set("Content-Type", "application/json")
+	if dr.tileEncoding != nil {
+		mediaType := mime.FormatMediaType(SampleContentType, map[string]string{"compression": compressionParam(dr.tileEncoding.Compress)})
+		req.Header.Set("Accept", mediaType+", application/json;q=0.5")
+	}
+	
+	resp, err := dr.client.Do(req)
+	if err != nil {
+		atomic.AddInt64(&dr.failedJobs, 1)
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	result, err := decodeSampleResult(resp)
+	if err != nil {
+		atomic.AddInt64(&dr.failedJobs, 1)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || result.Error != "" {
+		atomic.AddInt64(&dr.failedJobs, 1)
+		return nil, fmt.Errorf("node %s failed to render samples %d to %d: %s", nodeAddr, job.FirstSample, job.FirstSample+job.Samples-1, result.Error)
+	}
+	if result.FirstSample != job.FirstSample || result.Samples != job.Samples {
+		atomic.AddInt64(&dr.failedJobs, 1)
+		return nil, fmt.Errorf("node %s sent samples %d to %d, want %d to %d", nodeAddr, result.FirstSample, result.FirstSample+result.Samples-1, job.FirstSample, job.FirstSample+job.Samples-1)
+	}
+	if !validSampleBuffer(result.Buffer, job.Width, job.Height, job.Samples) {
+		atomic.AddInt64(&dr.failedJobs, 1)
+		return nil, fmt.Errorf("node %s sent an invalid sample buffer", nodeAddr)
+	}
+	
+	atomic.AddInt64(&dr.remoteJobs, 1)
+	return result, nil
+}
+
+func decodeSampleResult(resp *http.Response) (*SampleResult, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode == http.StatusOK && mediaType == SampleContentType {
+		return readSampleBuffer(resp.Body)
+	}
+	
+	var result SampleResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// validSampleBuffer reports whether a received buffer is a width x height
+// one, so that merging it cannot go out of range, with at most samples
+// samples in each pixel.
+func validSampleBuffer(b *renderer.SampleBuffer, width, height, samples int) bool {
+	if b == nil || b.Width != width || b.Height != height {
+		return false
+	}
+	n := width * height
+	if len(b.Sum) != n*3 || len(b.Samples) != n || len(b.Covered) != n {
+		return false
+	}
+	for i := range b.Samples {
+		if int(b.Samples[i]) > samples || b.Covered[i] > b.Samples[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (rrs *RemoteRenderServer) handleSamples(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	var job SampleJob
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		rrs.writeSampleResult(w, bodyStatus(err), SampleResult{Error: "Invalid request body"})
+		return
+	}
+	
+	start := time.Now()
+	
+	s, status, err := rrs.requestScene(job.Scene, job.SceneHash)
+	if err != nil {
+		rrs.writeSampleResult(w, status, SampleResult{JobID: job.ID, Error: err.Error()})
+		return
+	}
+	
+	params, binaryBuffer := acceptedParams(r.Header.Get("Accept"), SampleContentType)
+	compress, err := acceptedCompression(params)
+	if err != nil {
+		rrs.writeSampleResult(w, http.StatusNotAcceptable, SampleResult{JobID: job.ID, Error: err.Error()})
+		return
+	}
+	
+	atomic.AddInt64(&rrs.activeJobs, 1)
+	buffer, err := renderSamples(r.Context(), job, s, rrs.workers)
+	atomic.AddInt64(&rrs.activeJobs, -1)
+	if err != nil {
+		rrs.writeSampleResult(w, http.StatusInternalServerError, SampleResult{JobID: job.ID, Error: err.Error()})
+		return
+	}
+	
+	atomic.AddInt64(&rrs.completedJobs, 1)
+	atomic.AddInt64(&rrs.renderedPixels, int64(job.Width*job.Height))
+	atomic.AddInt64(&rrs.renderTime, int64(time.Since(start)))
+	
+	result := SampleResult{
+		JobID:       job.ID,
+		FirstSample: job.FirstSample,
+		Samples:     job.Samples,
+		Duration:    time.Since(start).Seconds(),
+		NodeID:      "node-" + rrs.port,
+	}
+	if binaryBuffer {
+		var body bytes.Buffer
+		if err := writeSampleBuffer(&body, buffer, result, compress); err != nil {
+			rrs.writeSampleResult(w, http.StatusInternalServerError, SampleResult{JobID: job.ID, Error: err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", mime.FormatMediaType(SampleContentType, map[string]string{"compression": compressionParam(compress)}))
+		w.Write(body.Bytes())
+		return
+	}
+	result.Buffer = buffer
+	rrs.writeSampleResult(w, http.StatusOK, result)
+}
+
+func (rrs *RemoteRenderServer) writeSampleResult(w http.ResponseWriter, status int, result SampleResult) {
+	result.NodeID = "node-" + rrs.port
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// renderSamples renders the job's sample range of the whole image.
+func renderSamples(ctx context.Context, job SampleJob, s *scene.Scene, workers int) (*renderer.SampleBuffer, error) {
+	if job.Width <= 0 || job.Height <= 0 || job.Width > maxTilePixels/job.Height {
+		return nil, fmt.Errorf("invalid image size %dx%d", job.Width, job.Height)
+	}
+	r, err := newRenderer(job.Settings, s, workers)
+	if err != nil {
+		return nil, err
+	}
+	return r.RenderSamples(ctx, s, job.Width, job.Height, job.FirstSample, job.Samples)
+}
+
+// writeSampleBuffer encodes the buffer with the job ID, sample range,
+// duration and node ID of result.
+func writeSampleBuffer(w io.Writer, b *renderer.SampleBuffer, result SampleResult, compress bool) error {
+	payload := make([]byte, 0, len(b.Sum)*8+len(b.Samples)*8)
+	for _, v := range b.Sum {
+		payload = binary.LittleEndian.AppendUint64(payload, stdmath.Float64bits(v))
+	}
+	for _, counts := range [][]uint32{b.Samples, b.Covered} {
+		for _, n := range counts {
+			payload = binary.LittleEndian.AppendUint32(payload, n)
+		}
+	}
+	checksum := crc32.ChecksumIEEE(payload)
+	
+	var flags byte
+	if compress {
+		var err error
+		if payload, err = deflate(payload); err != nil {
+			return fmt.Errorf("failed to compress sample buffer: %w", err)
+		}
+		flags |= tileDeflate
+	}
+	
+	nodeID := truncateNodeID(result.NodeID)
+	
+	header := make([]byte, 0, sampleHeaderSize+len(nodeID))
+	header = append(header, sampleMagic...)
+	header = append(header, sampleVersion, flags, 0, 0)
+	for _, v := range []int{result.JobID, result.FirstSample, result.Samples, b.Width, b.Height} {
+		header = binary.LittleEndian.AppendUint32(header, uint32(v))
+	}
+	header = binary.LittleEndian.AppendUint64(header, stdmath.Float64bits(result.Duration))
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(payload)))
+	header = binary.LittleEndian.AppendUint32(header, checksum)
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(nodeID)))
+	header = append(header, nodeID...)
+	
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readSampleBuffer decodes a sample buffer into a result.
+func readSampleBuffer(r io.Reader) (*SampleResult, error) {
+	reader := bufio.NewReader(r)
+	header := make([]byte, sampleHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("failed to read sample buffer header: %w", err)
+	}
+	if string(header[:4]) != sampleMagic {
+		return nil, fmt.Errorf("not a sample buffer")
+	}
+	if header[4] != sampleVersion {
+		return nil, fmt.Errorf("unsupported sample buffer version %d", header[4])
+	}
+	flags := header[5]
+	
+	field := func(i int) int { return int(binary.LittleEndian.Uint32(header[8+i*4:])) }
+	width, height := field(3), field(4)
+	if width <= 0 || height <= 0 || width > maxTilePixels || height > maxTilePixels || width*height > maxTilePixels {
+		return nil, fmt.Errorf("invalid sample buffer size %dx%d", width, height)
+	}
+	result := &SampleResult{
+		JobID:       int(int32(field(0))),
+		FirstSample: field(1),
+		Samples:     field(2),
+		Duration:    stdmath.Float64frombits(binary.LittleEndian.Uint64(header[28:])),
+	}
+	payloadSize := int64(binary.LittleEndian.Uint32(header[36:]))
+	checksum := binary.LittleEndian.Uint32(header[40:])
+	
+	nodeID := make([]byte, binary.LittleEndian.Uint16(header[44:]))
+	if _, err := io.ReadFull(reader, nodeID); err != nil {
+		return nil, fmt.Errorf("failed to read sample buffer header: %w", err)
+	}
+	result.NodeID = string(nodeID)
+	
+	n := width * height
+	payload, err := readPayload(reader, payloadSize, n*(3*8+2*4), flags, checksum)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sample buffer payload: %w", err)
+	}
+	
+	b := renderer.NewSampleBuffer(width, height)
+	for i := range b.Sum {
+		b.Sum[i] = stdmath.Float64frombits(binary.LittleEndian.Uint64(payload[i*8:]))
+	}
+	counts := payload[n*3*8:]
+	for i := 0; i < n; i++ {
+		b.Samples[i] = binary.LittleEndian.Uint32(counts[i*4:])
+		b.Covered[i] = binary.LittleEndian.Uint32(counts[(n+i)*4:])
+	}
+	result.Buffer = b
+	return result, nil
+}
@@ -0,0 +1,58 @@
+package distributed
+
+import (
+	"bytes"
+	"raytraceGo/internal/renderer"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSampleBufferRoundTrip(t *testing.T) {
+	want := renderer.NewSampleBuffer(7, 3)
+	for i := range want.Sum {
+		want.Sum[i] = float64(i) * 0.1
+	}
+	for i := range want.Samples {
+		want.Samples[i] = uint32(i % 4)
+		want.Covered[i] = uint32(i % 3)
+	}
+	result := SampleResult{JobID: 4, FirstSample: 16, Samples: 8, Duration: 2.5, NodeID: "node-8080"}
+	
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := writeSampleBuffer(&buf, want, result, compress); err != nil {
+			t.Fatalf("writeSampleBuffer failed: %v", err)
+		}
+		got, err := readSampleBuffer(&buf)
+		if err != nil {
+			t.Fatalf("readSampleBuffer failed: %v", err)
+		}
+		if !reflect.DeepEqual(got.Buffer, want) {
+			t.Errorf("readSampleBuffer failed: buffer differs")
+		}
+		got.Buffer = nil
+		if *got != result {
+			t.Errorf("readSampleBuffer failed: got %+v, want %+v", *got, result)
+		}
+	}
+	
+	var buf bytes.Buffer
+	writeSampleBuffer(&buf, want, result, false)
+	corrupt := buf.Bytes()
+	corrupt[len(corrupt)-5] ^= 0x01
+	if _, err := readSampleBuffer(bytes.NewReader(corrupt)); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("readSampleBuffer failed: got %v, want a checksum error", err)
+	}
+}
+
+func TestSplitSamples(t *testing.T) {
+	jobs := SplitSamples(8, 4, 10, 4)
+	var ranges [][2]int
+	for _, job := range jobs {
+		ranges = append(ranges, [2]int{job.FirstSample, job.Samples})
+	}
+	if want := [][2]int{{0, 4}, {4, 4}, {8, 2}}; !reflect.DeepEqual(ranges, want) {
+		t.Errorf("SplitSamples failed: got %v, want %v", ranges, want)
+	}
+}
@@ -0,0 +1,394 @@
+package distributed
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io"
+	stdmath "math"
+	"mime"
+	"raytraceGo/internal/output"
+	"strings"
+)
+
+// TileContentType is the media type of binary tiles. Clients ask for them in
+// the Accept header of a render request, with format and compression
+// parameters; nodes answer other requests with JSON.
+const TileContentType = "application/x-raytrace-tile"
+
+// A tile starts with a fixed header followed by the node ID and the payload:
+//
+//	magic "RTTL", version, format, flags, reserved byte
+//	chunk ID, x, y, width, height  uint32 each
+//	duration                       float64
+//	payload length, CRC-32         uint32 each; the CRC is of the payload
+//	                               before compression
+//	node ID length                 uint16
+//
+// all little-endian. The payload holds the tone-mapped R, G, B and A planes
+// of width*height bytes each, followed in the float formats by the planes of
+// linear radiance and alpha.
+const (
+	tileMagic      = "RTTL"
+	tileVersion    = 1
+	tileHeaderSize = 46
+	tileDeflate    = 1 << 0
+	// Tiles larger than this are rejected rather than allocated.
+	maxTilePixels = 1 << 26
+)
+
+// TileFormat is the precision of a tile's pixels.
+type TileFormat uint8
+
+const (
+	// TileUint8 carries only the tone-mapped 8-bit pixels.
+	TileUint8 TileFormat = iota
+	// TileFloat16 adds linear radiance as half floats.
+	TileFloat16
+	// TileFloat32 adds linear radiance as full floats.
+	TileFloat32
+)
+
+var tileFormatNames = []string{"uint8", "float16", "float32"}
+
+func (f TileFormat) String() string {
+	if int(f) < len(tileFormatNames) {
+		return tileFormatNames[f]
+	}
+	return fmt.Sprintf("TileFormat(%d)", uint8(f))
+}
+
+func ParseTileFormat(name string) (TileFormat, error) {
+	for i, formatName := range tileFormatNames {
+		if name == formatName {
+			return TileFormat(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tile format %q (want %s)", name, strings.Join(tileFormatNames, ", "))
+}
+
+// floatSize is the size of a radiance value, or 0 without radiance.
+func (f TileFormat) floatSize() int {
+	switch f {
+	case TileFloat16:
+		return 2
+	case TileFloat32:
+		return 4
+	}
+	return 0
+}
+
+// TileEncoding is how a node sends rendered chunks.
+type TileEncoding struct {
+	Format   TileFormat
+	Compress bool
+}
+
+// DefaultTileEncoding carries half-precision radiance compressed with
+// DEFLATE, which HDR output and post-processing need. TileUint8 tiles are
+// smaller but hold only the tone-mapped pixels.
+var DefaultTileEncoding = TileEncoding{Format: TileFloat16, Compress: true}
+
+// MediaType returns the encoding as a TileContentType media type.
+func (e TileEncoding) MediaType() string {
+	return mime.FormatMediaType(TileContentType, map[string]string{"format": e.Format.String(), "compression": compressionParam(e.Compress)})
+}
+
+// acceptedTileEncoding returns the tile encoding an Accept header asks for;
+// ok is false if it does not ask for tiles.
+func acceptedTileEncoding(accept string) (encoding TileEncoding, ok bool, err error) {
+	params, ok := acceptedParams(accept, TileContentType)
+	if !ok {
+		return encoding, false, nil
+	}
+	
+	encoding = DefaultTileEncoding
+	if format, ok := params["format"]; ok {
+		if encoding.Format, err = ParseTileFormat(format); err != nil {
+			return encoding, false, err
+		}
+	}
+	if encoding.Compress, err = acceptedCompression(params); err != nil {
+		return encoding, false, err
+	}
+	return encoding, true, nil
+}
+
+// acceptedParams returns the parameters of the media type in an Accept
+// header; ok is false if the header does not list it.
+func acceptedParams(accept, contentType string) (params map[string]string, ok bool) {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err == nil && mediaType == contentType {
+			return params, true
+		}
+	}
+	return nil, false
+}
+
+// acceptedCompression reports whether accepted media type parameters ask for
+// DEFLATE, the default.
+func acceptedCompression(params map[string]string) (bool, error) {
+	switch params["compression"] {
+	case "", "deflate":
+		return true, nil
+	case "none":
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown compression %q (want deflate or none)", params["compression"])
+}
+
+// compressionParam is the compression parameter of a binary media type.
+func compressionParam(compress bool) string {
+	if compress {
+		return "deflate"
+	}
+	return "none"
+}
+
+// tile is a rendered chunk: its rectangle in the image, and its tone-mapped
+// pixels and their linear radiance and alpha, both chunk-sized.
+type tile struct {
+	rect     image.Rectangle
+	img      *image.RGBA
+	radiance *output.Framebuffer
+}
+
+func (t *tile) pixels() []Pixel {
+	pixels := make([]Pixel, 0, t.rect.Dx()*t.rect.Dy())
+	for y := 0; y < t.rect.Dy(); y++ {
+		for x := 0; x < t.rect.Dx(); x++ {
+			c := t.img.RGBAAt(x, y)
+			pixels = append(pixels, Pixel{X: t.rect.Min.X + x, Y: t.rect.Min.Y + y, R: c.R, G: c.G, B: c.B, A: c.A})
+		}
+	}
+	return pixels
+}
+
+// writeTile encodes the tile with the chunk ID, duration and node ID of
+// result.
+func writeTile(w io.Writer, t *tile, result RemoteResult, encoding TileEncoding) error {
+	payload := tilePayload(t, encoding.Format)
+	checksum := crc32.ChecksumIEEE(payload)
+	
+	var flags byte
+	if encoding.Compress {
+		var err error
+		if payload, err = deflate(payload); err != nil {
+			return fmt.Errorf("failed to compress tile: %w", err)
+		}
+		flags |= tileDeflate
+	}
+	
+	nodeID := truncateNodeID(result.NodeID)
+	
+	header := make([]byte, 0, tileHeaderSize+len(nodeID))
+	header = append(header, tileMagic...)
+	header = append(header, tileVersion, byte(encoding.Format), flags, 0)
+	for _, v := range []int{result.ChunkID, t.rect.Min.X, t.rect.Min.Y, t.rect.Dx(), t.rect.Dy()} {
+		header = binary.LittleEndian.AppendUint32(header, uint32(v))
+	}
+	header = binary.LittleEndian.AppendUint64(header, stdmath.Float64bits(result.Duration))
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(payload)))
+	header = binary.LittleEndian.AppendUint32(header, checksum)
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(nodeID)))
+	header = append(header, nodeID...)
+	
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+func deflate(payload []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	writer, _ := flate.NewWriter(&compressed, flate.DefaultCompression)
+	writer.Write(payload)
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// truncateNodeID cuts a node ID to the length its uint16 field can hold.
+func truncateNodeID(id string) []byte {
+	nodeID := []byte(id)
+	if len(nodeID) > stdmath.MaxUint16 {
+		nodeID = nodeID[:stdmath.MaxUint16]
+	}
+	return nodeID
+}
+
+func tilePayload(t *tile, format TileFormat) []byte {
+	n := t.rect.Dx() * t.rect.Dy()
+	payload := make([]byte, 0, n*(4+4*format.floatSize()))
+	for c := 0; c < 4; c++ {
+		for i := 0; i < n; i++ {
+			payload = append(payload, t.img.Pix[i*4+c])
+		}
+	}
+	for c := 0; c < 4 && format != TileUint8; c++ {
+		for i := 0; i < n; i++ {
+			v := t.radiance.Pix[i*4+c]
+			if format == TileFloat16 {
+				payload = binary.LittleEndian.AppendUint16(payload, float32ToHalf(v))
+			} else {
+				payload = binary.LittleEndian.AppendUint32(payload, stdmath.Float32bits(v))
+			}
+		}
+	}
+	return payload
+}
+
+// readTile decodes a tile into a result with its pixels, rectangle and, for
+// the float formats, its radiance.
+func readTile(r io.Reader) (*RemoteResult, error) {
+	reader := bufio.NewReader(r)
+	header := make([]byte, tileHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("failed to read tile header: %w", err)
+	}
+	if string(header[:4]) != tileMagic {
+		return nil, fmt.Errorf("not a tile")
+	}
+	if header[4] != tileVersion {
+		return nil, fmt.Errorf("unsupported tile version %d", header[4])
+	}
+	format := TileFormat(header[5])
+	if int(format) >= len(tileFormatNames) {
+		return nil, fmt.Errorf("unknown tile format %d", header[5])
+	}
+	flags := header[6]
+	
+	field := func(i int) int { return int(binary.LittleEndian.Uint32(header[8+i*4:])) }
+	width, height := field(3), field(4)
+	if width <= 0 || height <= 0 || width > maxTilePixels || height > maxTilePixels || width*height > maxTilePixels {
+		return nil, fmt.Errorf("invalid tile size %dx%d", width, height)
+	}
+	result := &RemoteResult{
+		ChunkID:  int(int32(field(0))),
+		Duration: stdmath.Float64frombits(binary.LittleEndian.Uint64(header[28:])),
+		Rect:     image.Rect(field(1), field(2), field(1)+width, field(2)+height),
+	}
+	payloadSize := int64(binary.LittleEndian.Uint32(header[36:]))
+	checksum := binary.LittleEndian.Uint32(header[40:])
+	
+	nodeID := make([]byte, binary.LittleEndian.Uint16(header[44:]))
+	if _, err := io.ReadFull(reader, nodeID); err != nil {
+		return nil, fmt.Errorf("failed to read tile header: %w", err)
+	}
+	result.NodeID = string(nodeID)
+	
+	n := width * height
+	payload, err := readPayload(reader, payloadSize, n*(4+4*format.floatSize()), flags, checksum)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tile payload: %w", err)
+	}
+	
+	t := &tile{rect: result.Rect, img: image.NewRGBA(image.Rect(0, 0, width, height))}
+	for c := 0; c < 4; c++ {
+		for i := 0; i < n; i++ {
+			t.img.Pix[i*4+c] = payload[c*n+i]
+		}
+	}
+	result.Pixels = t.pixels()
+	
+	if size := format.floatSize(); size > 0 {
+		result.Radiance = output.NewFramebuffer(width, height)
+		planes := payload[4*n:]
+		for c := 0; c < 4; c++ {
+			for i := 0; i < n; i++ {
+				offset := (c*n + i) * size
+				if format == TileFloat16 {
+					result.Radiance.Pix[i*4+c] = halfToFloat32(binary.LittleEndian.Uint16(planes[offset:]))
+				} else {
+					result.Radiance.Pix[i*4+c] = stdmath.Float32frombits(binary.LittleEndian.Uint32(planes[offset:]))
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// readPayload reads a payload of size bytes, stored in stored bytes and
+// compressed if flags say so, and verifies its checksum.
+func readPayload(r io.Reader, stored int64, size int, flags byte, checksum uint32) ([]byte, error) {
+	var payloadReader io.Reader = io.LimitReader(r, stored)
+	if flags&tileDeflate != 0 {
+		payloadReader = flate.NewReader(payloadReader)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(payloadReader, payload); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+	return payload, nil
+}
+
+// float32ToHalf converts to an IEEE 754 half float, rounding to nearest even;
+// values beyond its range become infinite.
+func float32ToHalf(f float32) uint16 {
+	bits := stdmath.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exponent := int(bits>>23&0xff) - 127 + 15
+	mantissa := bits & 0x7fffff
+	
+	switch {
+	case bits&0x7fffffff == 0:
+		return sign
+	case bits>>23&0xff == 0xff:
+		if mantissa != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exponent >= 0x1f:
+		return sign | 0x7c00
+	case exponent <= 0:
+		// Subnormal, or zero if too small.
+		if exponent < -10 {
+			return sign
+		}
+		mantissa |= 0x800000
+		shift := uint(14 - exponent)
+		half := uint16(mantissa >> shift)
+		remainder := mantissa & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if remainder > halfway || (remainder == halfway && half&1 == 1) {
+			half++
+		}
+		return sign | half
+	}
+	
+	// A carry out of the mantissa correctly rounds up into the exponent.
+	half := sign | uint16(exponent)<<10 | uint16(mantissa>>13)
+	remainder := mantissa & 0x1fff
+	if remainder > 0x1000 || (remainder == 0x1000 && half&1 == 1) {
+		half++
+	}
+	return half
+}
+
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exponent := uint32(h>>10) & 0x1f
+	mantissa := uint32(h & 0x3ff)
+	
+	switch exponent {
+	case 0x1f:
+		return stdmath.Float32frombits(sign | 0x7f800000 | mantissa<<13)
+	case 0:
+		v := float32(mantissa) / (1 << 24)
+		if sign != 0 {
+			v = -v
+		}
+		return v
+	}
+	return stdmath.Float32frombits(sign | (exponent+127-15)<<23 | mantissa<<13)
+}
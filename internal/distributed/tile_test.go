@@ -0,0 +1,182 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"image"
+	stdmath "math"
+	"net/http"
+	"net/http/httptest"
+	"raytraceGo/internal/output"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func testTile() *tile {
+	rect := image.Rect(32, 16, 37, 19)
+	t := &tile{
+		rect:     rect,
+		img:      image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy())),
+		radiance: output.NewFramebuffer(rect.Dx(), rect.Dy()),
+	}
+	for i := range t.img.Pix {
+		t.img.Pix[i] = uint8(i * 7)
+		t.radiance.Pix[i] = float32(i) * 0.37
+	}
+	t.radiance.Pix[5] = 1e6
+	return t
+}
+
+func TestTileRoundTrip(t *testing.T) {
+	want := testTile()
+	result := RemoteResult{ChunkID: 12, Duration: 1.5, NodeID: "node-8080"}
+	
+	for _, format := range []TileFormat{TileUint8, TileFloat16, TileFloat32} {
+		for _, compress := range []bool{false, true} {
+			var buf bytes.Buffer
+			if err := writeTile(&buf, want, result, TileEncoding{Format: format, Compress: compress}); err != nil {
+				t.Fatalf("writeTile failed: %v", err)
+			}
+			got, err := readTile(&buf)
+			if err != nil {
+				t.Fatalf("readTile %v failed: %v", format, err)
+			}
+			
+			if got.ChunkID != 12 || got.Duration != 1.5 || got.NodeID != "node-8080" || got.Rect != want.rect {
+				t.Errorf("readTile %v failed: got header %+v", format, got)
+			}
+			if !reflect.DeepEqual(got.Pixels, want.pixels()) {
+				t.Errorf("readTile %v failed: pixels differ", format)
+			}
+			if (got.Radiance != nil) != (format != TileUint8) {
+				t.Fatalf("readTile %v failed: got radiance %v", format, got.Radiance != nil)
+			}
+			if got.Radiance == nil {
+				continue
+			}
+			for i, v := range got.Radiance.Pix {
+				w := want.radiance.Pix[i]
+				if format == TileFloat32 && v != w {
+					t.Errorf("readTile %v failed: got %v, want %v", format, v, w)
+				}
+				if format == TileFloat16 && w < 65504 && stdmath.Abs(float64(v-w)) > float64(w)/1024 {
+					t.Errorf("readTile %v failed: got %v, want %v", format, v, w)
+				}
+			}
+			if format == TileFloat16 && !stdmath.IsInf(float64(got.Radiance.Pix[5]), 1) {
+				t.Errorf("readTile %v failed: got %v, want +Inf beyond the half range", format, got.Radiance.Pix[5])
+			}
+		}
+	}
+}
+
+func TestTileCorruption(t *testing.T) {
+	var buf bytes.Buffer
+	writeTile(&buf, testTile(), RemoteResult{}, TileEncoding{Format: TileFloat32})
+	data := buf.Bytes()
+	
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-3] ^= 0x40
+	if _, err := readTile(bytes.NewReader(corrupt)); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("readTile failed: got %v, want a checksum error", err)
+	}
+	if _, err := readTile(bytes.NewReader(data[:len(data)-10])); err == nil {
+		t.Errorf("readTile failed: accepted a truncated tile")
+	}
+	
+	future := append([]byte(nil), data...)
+	future[4] = tileVersion + 1
+	if _, err := readTile(bytes.NewReader(future)); err == nil {
+		t.Errorf("readTile failed: accepted an unknown version")
+	}
+}
+
+func TestHalfFloat(t *testing.T) {
+	cases := []struct {
+		f float32
+		h uint16
+	}{
+		{0, 0x0000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{65504, 0x7bff},
+		{1e6, 0x7c00},
+		{float32(stdmath.Ldexp(1, -24)), 0x0001},
+		{float32(stdmath.Ldexp(1, -26)), 0x0000},
+		{1 + 1.0/2048, 0x3c00},
+		{1 + 3.0/2048, 0x3c02},
+	}
+	for _, c := range cases {
+		if got := float32ToHalf(c.f); got != c.h {
+			t.Errorf("float32ToHalf(%v) failed: got %#04x, want %#04x", c.f, got, c.h)
+		}
+	}
+	
+	for h := 0; h <= 0xffff; h++ {
+		if h&0x7c00 == 0x7c00 && h&0x3ff != 0 {
+			continue
+		}
+		if got := float32ToHalf(halfToFloat32(uint16(h))); got != uint16(h) {
+			t.Fatalf("half round trip failed: got %#04x, want %#04x", got, h)
+		}
+	}
+}
+
+func TestTileTransfer(t *testing.T) {
+	var sent int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter := &countingWriter{ResponseWriter: w}
+		NewRemoteRenderServer("", 2).Handler().ServeHTTP(counter, r)
+		atomic.StoreInt64(&sent, counter.n)
+	}))
+	t.Cleanup(server.Close)
+	node := strings.TrimPrefix(server.URL, "http://")
+	
+	chunk := SplitFrame(64, 48, 64)[0]
+	chunk.Scene = testScene
+	chunk.Settings = RenderSettings{Samples: 2, MaxDepth: 4, Seed: 2}
+	
+	dr := NewDistributedRenderer(context.Background(), []string{node})
+	dr.SetTileEncoding(nil)
+	want, err := dr.RenderChunkRemotely(chunk, node)
+	if err != nil {
+		t.Fatalf("RenderChunkRemotely failed: %v", err)
+	}
+	jsonSize := atomic.LoadInt64(&sent)
+	
+	for _, encoding := range []TileEncoding{{Format: TileUint8, Compress: true}, DefaultTileEncoding, {Format: TileFloat32}} {
+		dr.SetTileEncoding(&encoding)
+		got, err := dr.RenderChunkRemotely(chunk, node)
+		if err != nil {
+			t.Fatalf("RenderChunkRemotely %s failed: %v", encoding.MediaType(), err)
+		}
+		if !reflect.DeepEqual(got.Pixels, want.Pixels) {
+			t.Errorf("RenderChunkRemotely %s failed: pixels differ from JSON", encoding.MediaType())
+		}
+		if (got.Radiance != nil) != (encoding.Format != TileUint8) {
+			t.Errorf("RenderChunkRemotely %s failed: got radiance %v", encoding.MediaType(), got.Radiance != nil)
+		}
+		if size := atomic.LoadInt64(&sent); size*2 > jsonSize {
+			t.Errorf("RenderChunkRemotely %s failed: sent %d bytes, JSON %d", encoding.MediaType(), size, jsonSize)
+		}
+	}
+	
+	encoding := TileEncoding{Format: TileFormat(9)}
+	dr.SetTileEncoding(&encoding)
+	if _, err := dr.RenderChunkRemotely(chunk, node); err == nil {
+		t.Errorf("RenderChunkRemotely failed: node accepted an unknown tile format")
+	}
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -202,37 +202,18 @@ type DepthOfField struct {
 	Enabled     bool
 	FocusDistance float64
 	Aperture     float64
-	FocalLength  float64
 }
 
-func NewDepthOfField(focusDistance, aperture, focalLength float64) *DepthOfField {
+func NewDepthOfField(focusDistance, aperture float64) *DepthOfField {
 	return &DepthOfField{
 		Enabled:       true,
 		FocusDistance: focusDistance,
 		Aperture:     aperture,
-		FocalLength:  focalLength,
 	}
 }
 
-func (dof *DepthOfField) CalculateCircleOfConfusion(distance float64) float64 {
-	if !dof.Enabled {
-		return 0.0
-	}
-	
-	focusDistance := dof.FocusDistance
-	focalLength := dof.FocalLength
-	aperture := dof.Aperture
-	
-	if stdmath.Abs(distance - focusDistance) < 0.001 {
-		return 0.0
-	}
-	
-	coc := (stdmath.Abs(distance - focusDistance) / distance) * (focalLength * focalLength) / (focusDistance * aperture)
-	return coc
-}
-
-func (dof *DepthOfField) GetDefocusDiskRadius(distance float64) float64 {
-	return dof.CalculateCircleOfConfusion(distance)
+func (dof *DepthOfField) GetLensRadius() float64 {
+	return dof.Aperture / 2.0
 }
 
 type LensFlare struct {
@@ -240,6 +221,8 @@ type LensFlare struct {
 	Intensity   float64
 	Color       math.Vec3
 	Size        float64
+	// Threshold is the luminance above which pixels cast image-space ghosts.
+	Threshold   float64
 	Elements    []LensFlareElement
 }
 
@@ -256,6 +239,7 @@ func NewLensFlare(intensity float64, color math.Vec3, size float64) *LensFlare {
 		Intensity: intensity,
 		Color:     color,
 		Size:      size,
+		Threshold: 1.0,
 		Elements:  []LensFlareElement{
 			{Position: 0.0, Size: 0.1, Color: color, Intensity: 1.0},
 			{Position: 0.3, Size: 0.05, Color: color, Intensity: 0.7},
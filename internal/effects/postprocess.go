@@ -0,0 +1,235 @@
+package effects
+
+import (
+	stdmath "math"
+	"raytraceGo/internal/math"
+	"raytraceGo/internal/output"
+)
+
+// PostEffect is one stage of the post-process chain. Stages work on the
+// linear HDR framebuffer before tone mapping and may read any pixel.
+type PostEffect interface {
+	ApplyImage(f *output.Framebuffer)
+}
+
+// ApplyPostProcess runs the effects over the framebuffer in order.
+func ApplyPostProcess(f *output.Framebuffer, chain []PostEffect) {
+	for _, effect := range chain {
+		effect.ApplyImage(f)
+	}
+}
+
+// Number of blur scales bloom sums, each twice the radius of the previous.
+const bloomScales = 5
+
+// ApplyImage adds a glow around pixels brighter than the threshold. The
+// bright parts are blurred at several scales up to Radius, a fraction of the
+// image height, and the blurs are averaged, which gives the sharp core and
+// wide falloff of light scattering in a lens.
+func (b *Bloom) ApplyImage(f *output.Framebuffer) {
+	if !b.Enabled || b.Intensity <= 0 {
+		return
+	}
+	
+	img := newImageBuffer(f)
+	bright := img.brightPass(b.Threshold)
+	
+	glow := make([]math.Vec3, len(img.pix))
+	for scale := 0; scale < bloomScales; scale++ {
+		radius := b.Radius * float64(f.Height) / float64(int(1)<<(bloomScales-1-scale))
+		blurred := bright.gaussianBlur(radius)
+		for i, c := range blurred.pix {
+			glow[i] = glow[i].Add(c)
+		}
+	}
+	
+	weight := b.Intensity / bloomScales
+	for i := range img.pix {
+		img.pix[i] = img.pix[i].Add(glow[i].MulScalar(weight))
+	}
+	img.store(f)
+}
+
+// ApplyImage darkens the image towards its corners.
+func (v *Vignette) ApplyImage(f *output.Framebuffer) {
+	if !v.Enabled {
+		return
+	}
+	
+	img := newImageBuffer(f)
+	for y := 0; y < img.height; y++ {
+		for x := 0; x < img.width; x++ {
+			i := y*img.width + x
+			img.pix[i] = v.ApplyVignette(img.pix[i], img.uv(x, y))
+		}
+	}
+	img.store(f)
+}
+
+// ApplyImage scales each channel's image about the center by its offset, so
+// that red and blue fringes appear towards the edges as in a lens with
+// lateral chromatic aberration.
+func (ca *ChromaticAberration) ApplyImage(f *output.Framebuffer) {
+	if !ca.Enabled {
+		return
+	}
+	
+	img := newImageBuffer(f)
+	result := make([]math.Vec3, len(img.pix))
+	for y := 0; y < img.height; y++ {
+		for x := 0; x < img.width; x++ {
+			uv := img.uv(x, y)
+			result[y*img.width+x] = math.Vec3{
+				X: img.sample(scaleAboutCenter(uv, 1+ca.RedOffset)).X,
+				Y: img.sample(scaleAboutCenter(uv, 1+ca.GreenOffset)).Y,
+				Z: img.sample(scaleAboutCenter(uv, 1+ca.BlueOffset)).Z,
+			}
+		}
+	}
+	img.pix = result
+	img.store(f)
+}
+
+// ApplyImage adds ghosts of the bright parts of the image, mirrored through
+// the center at the distance and size of each flare element, the way
+// internal reflections between lens elements repeat a bright light.
+func (lf *LensFlare) ApplyImage(f *output.Framebuffer) {
+	if !lf.Enabled || lf.Intensity <= 0 {
+		return
+	}
+	
+	img := newImageBuffer(f)
+	bright := img.brightPass(lf.Threshold)
+	
+	flare := make([]math.Vec3, len(img.pix))
+	for _, element := range lf.Elements {
+		ghost := bright.gaussianBlur(element.Size * lf.Size * float64(f.Height) * 0.25)
+		scale := -(0.5 + 2*element.Position)
+		tint := element.Color.MulScalar(element.Intensity * lf.Intensity)
+		
+		for y := 0; y < img.height; y++ {
+			for x := 0; x < img.width; x++ {
+				uv := img.uv(x, y)
+				source := scaleAboutCenter(uv, scale)
+				if source.X < 0 || source.X > 1 || source.Y < 0 || source.Y > 1 {
+					continue
+				}
+				// Fade ghosts out towards the edge of the frame.
+				fade := 1 - math.FastClamp(source.Sub(math.Vec3{X: 0.5, Y: 0.5}).Length()/0.7071, 0, 1)
+				i := y*img.width + x
+				flare[i] = flare[i].Add(ghost.sample(source).Mul(tint).MulScalar(fade))
+			}
+		}
+	}
+	
+	for i := range img.pix {
+		img.pix[i] = img.pix[i].Add(flare[i])
+	}
+	img.store(f)
+}
+
+func scaleAboutCenter(uv math.Vec3, scale float64) math.Vec3 {
+	center := math.Vec3{X: 0.5, Y: 0.5}
+	return center.Add(uv.Sub(center).MulScalar(scale))
+}
+
+// imageBuffer is the RGB of a framebuffer as Vec3, for effects that filter
+// over neighbourhoods.
+type imageBuffer struct {
+	width, height int
+	pix           []math.Vec3
+}
+
+func newImageBuffer(f *output.Framebuffer) *imageBuffer {
+	img := &imageBuffer{width: f.Width, height: f.Height, pix: make([]math.Vec3, f.Width*f.Height)}
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			img.pix[y*f.Width+x] = f.At(x, y)
+		}
+	}
+	return img
+}
+
+// store writes the RGB back, keeping the framebuffer's alpha.
+func (img *imageBuffer) store(f *output.Framebuffer) {
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			f.Set(x, y, img.pix[y*f.Width+x], f.Alpha(x, y))
+		}
+	}
+}
+
+// uv is the center of the pixel in [0, 1] coordinates.
+func (img *imageBuffer) uv(x, y int) math.Vec3 {
+	return math.Vec3{X: (float64(x) + 0.5) / float64(img.width), Y: (float64(y) + 0.5) / float64(img.height)}
+}
+
+// sample interpolates bilinearly, clamping to the edge.
+func (img *imageBuffer) sample(uv math.Vec3) math.Vec3 {
+	fx := math.FastClamp(uv.X*float64(img.width)-0.5, 0, float64(img.width-1))
+	fy := math.FastClamp(uv.Y*float64(img.height)-0.5, 0, float64(img.height-1))
+	x0, y0 := int(fx), int(fy)
+	x1, y1 := min(x0+1, img.width-1), min(y0+1, img.height-1)
+	tx, ty := fx-float64(x0), fy-float64(y0)
+	
+	top := img.pix[y0*img.width+x0].Lerp(img.pix[y0*img.width+x1], tx)
+	bottom := img.pix[y1*img.width+x0].Lerp(img.pix[y1*img.width+x1], tx)
+	return top.Lerp(bottom, ty)
+}
+
+// brightPass keeps the part of each pixel's luminance above the threshold,
+// with its color.
+func (img *imageBuffer) brightPass(threshold float64) *imageBuffer {
+	bright := &imageBuffer{width: img.width, height: img.height, pix: make([]math.Vec3, len(img.pix))}
+	for i, c := range img.pix {
+		luminance := c.Luminance()
+		if luminance > threshold && luminance > 0 {
+			bright.pix[i] = c.MulScalar((luminance - threshold) / luminance)
+		}
+	}
+	return bright
+}
+
+// gaussianBlur approximates a Gaussian of standard deviation sigma pixels by
+// three box blurs, whose cost does not depend on the radius.
+func (img *imageBuffer) gaussianBlur(sigma float64) *imageBuffer {
+	blurred := &imageBuffer{width: img.width, height: img.height, pix: append([]math.Vec3(nil), img.pix...)}
+	if sigma < 0.5 {
+		return blurred
+	}
+	
+	// Box width giving the same variance over three passes.
+	radius := int(stdmath.Round((stdmath.Sqrt(4*sigma*sigma+1) - 1) / 2))
+	if radius < 1 {
+		radius = 1
+	}
+	
+	scratch := make([]math.Vec3, len(img.pix))
+	for pass := 0; pass < 3; pass++ {
+		boxBlur(blurred.pix, scratch, img.width, img.height, 1, img.width, radius)
+		boxBlur(scratch, blurred.pix, img.height, img.width, img.width, 1, radius)
+	}
+	return blurred
+}
+
+// boxBlur averages each pixel over 2*radius+1 neighbours along one axis,
+// clamping at the edges. Lines of length n start every lineStride pixels and
+// step by step along the line.
+func boxBlur(src, dst []math.Vec3, n, lines, step, lineStride, radius int) {
+	scale := 1 / float64(2*radius+1)
+	for line := 0; line < lines; line++ {
+		base := line * lineStride
+		at := func(i int) math.Vec3 {
+			return src[base+min(max(i, 0), n-1)*step]
+		}
+		
+		sum := math.Vec3{}
+		for i := -radius; i <= radius; i++ {
+			sum = sum.Add(at(i))
+		}
+		for i := 0; i < n; i++ {
+			dst[base+i*step] = sum.MulScalar(scale)
+			sum = sum.Add(at(i + radius + 1)).Sub(at(i - radius))
+		}
+	}
+}
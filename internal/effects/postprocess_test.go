@@ -0,0 +1,94 @@
+package effects
+
+import (
+	stdmath "math"
+	"raytraceGo/internal/math"
+	"raytraceGo/internal/output"
+	"testing"
+)
+
+func uniformFramebuffer(width, height int, value float64) *output.Framebuffer {
+	f := output.NewFramebuffer(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			f.Set(x, y, math.Vec3{X: value, Y: value, Z: value}, 1)
+		}
+	}
+	return f
+}
+
+func TestBloomSpreadsOnlyBrightPixels(t *testing.T) {
+	f := uniformFramebuffer(64, 64, 0.5)
+	bloom := NewBloom(1.0, 1.0, 0.1)
+	
+	bloom.ApplyImage(f)
+	if got := f.At(5, 5).X; got != float64(float32(0.5)) {
+		t.Errorf("Bloom below threshold failed: got %v, want 0.5", got)
+	}
+	
+	f.Set(32, 32, math.Vec3{X: 101, Y: 101, Z: 101}, 1)
+	bloom.ApplyImage(f)
+	
+	neighbour := f.At(34, 32).X
+	far := f.At(5, 5).X
+	if neighbour <= 0.5 || neighbour <= far {
+		t.Errorf("Bloom glow failed: got %v next to the bright pixel and %v far away", neighbour, far)
+	}
+	
+	glow := 0.0
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if x != 32 || y != 32 {
+				glow += f.At(x, y).X - 0.5
+			}
+		}
+	}
+	// The glow carries the intensity-weighted energy above the threshold,
+	// less what the center pixel keeps.
+	if glow <= 0 || glow > 100 {
+		t.Errorf("Bloom energy failed: got %v, want within (0, 100]", glow)
+	}
+}
+
+func TestGaussianBlurPreservesEnergy(t *testing.T) {
+	img := &imageBuffer{width: 41, height: 41, pix: make([]math.Vec3, 41*41)}
+	img.pix[20*41+20] = math.Vec3{X: 1, Y: 2, Z: 3}
+	
+	blurred := img.gaussianBlur(3)
+	sum := math.Vec3{}
+	for _, c := range blurred.pix {
+		sum = sum.Add(c)
+	}
+	if sum.Sub(math.Vec3{X: 1, Y: 2, Z: 3}).Length() > 1e-9 {
+		t.Errorf("gaussianBlur energy failed: got %v, want (1, 2, 3)", sum)
+	}
+	if blurred.pix[20*41+20].X >= 1 || blurred.pix[20*41+23].X <= 0 {
+		t.Errorf("gaussianBlur spread failed: got center %v, 3 pixels away %v", blurred.pix[20*41+20].X, blurred.pix[20*41+23].X)
+	}
+}
+
+func TestVignetteDarkensCorners(t *testing.T) {
+	f := uniformFramebuffer(33, 33, 1)
+	NewVignette(0.5, 0.75, 1.0).ApplyImage(f)
+	
+	center := f.At(16, 16).X
+	corner := f.At(0, 0).X
+	if stdmath.Abs(center-1) > 1e-6 || corner >= center || corner < 0.5 {
+		t.Errorf("Vignette failed: got center %v and corner %v", center, corner)
+	}
+}
+
+func TestChromaticAberrationSeparatesChannels(t *testing.T) {
+	f := output.NewFramebuffer(32, 32)
+	f.Set(28, 16, math.Vec3{X: 1, Y: 1, Z: 1}, 1)
+	
+	NewChromaticAberration(0, 0, 0).ApplyImage(f)
+	if c := f.At(28, 16); c.X != 1 || c.Z != 1 {
+		t.Fatalf("ChromaticAberration without offsets failed: got %v, want (1, 1, 1)", c)
+	}
+	
+	NewChromaticAberration(0.1, 0, -0.1).ApplyImage(f)
+	if c := f.At(28, 16); c.Y != 1 || c.X >= 1 || c.Z >= 1 {
+		t.Errorf("ChromaticAberration failed: got %v, want green kept and red and blue displaced", c)
+	}
+}
@@ -0,0 +1,77 @@
+package geometry
+
+import (
+	stdmath "math"
+	"raytraceGo/internal/math"
+)
+
+func NewAABB(min, max math.Vec3) AABB {
+	return AABB{Min: min, Max: max}
+}
+
+func InfiniteAABB() AABB {
+	inf := stdmath.Inf(1)
+	return AABB{
+		Min: math.Vec3{X: -inf, Y: -inf, Z: -inf},
+		Max: math.Vec3{X: inf, Y: inf, Z: inf},
+	}
+}
+
+func (b AABB) IsBounded() bool {
+	return !stdmath.IsInf(b.Min.X, 0) && !stdmath.IsInf(b.Min.Y, 0) && !stdmath.IsInf(b.Min.Z, 0) &&
+		!stdmath.IsInf(b.Max.X, 0) && !stdmath.IsInf(b.Max.Y, 0) && !stdmath.IsInf(b.Max.Z, 0)
+}
+
+func (b AABB) SurfaceArea() float64 {
+	extent := b.Max.Sub(b.Min)
+	return 2.0 * (extent.X*extent.Y + extent.Y*extent.Z + extent.Z*extent.X)
+}
+
+// Hit uses the slab test. Division by a zero direction component yields
+// infinities, which the comparisons handle correctly.
+func (b AABB) Hit(ray Ray, tMin, tMax float64) bool {
+	origin := [3]float64{ray.Origin.X, ray.Origin.Y, ray.Origin.Z}
+	direction := [3]float64{ray.Direction.X, ray.Direction.Y, ray.Direction.Z}
+	min := [3]float64{b.Min.X, b.Min.Y, b.Min.Z}
+	max := [3]float64{b.Max.X, b.Max.Y, b.Max.Z}
+	
+	for axis := 0; axis < 3; axis++ {
+		invD := 1.0 / direction[axis]
+		t0 := (min[axis] - origin[axis]) * invD
+		t1 := (max[axis] - origin[axis]) * invD
+		if invD < 0 {
+			t0, t1 = t1, t0
+		}
+		
+		if t0 > tMin {
+			tMin = t0
+		}
+		if t1 < tMax {
+			tMax = t1
+		}
+		if tMax < tMin {
+			return false
+		}
+	}
+	
+	return true
+}
+
+func (b AABB) Union(other AABB) AABB {
+	return AABB{
+		Min: math.Vec3{
+			X: math.FastMin(b.Min.X, other.Min.X),
+			Y: math.FastMin(b.Min.Y, other.Min.Y),
+			Z: math.FastMin(b.Min.Z, other.Min.Z),
+		},
+		Max: math.Vec3{
+			X: math.FastMax(b.Max.X, other.Max.X),
+			Y: math.FastMax(b.Max.Y, other.Max.Y),
+			Z: math.FastMax(b.Max.Z, other.Max.Z),
+		},
+	}
+}
+
+func (b AABB) Centroid() math.Vec3 {
+	return b.Min.Add(b.Max).MulScalar(0.5)
+}
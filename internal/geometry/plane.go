@@ -45,6 +45,10 @@ func (p *Plane) Hit(ray Ray, tMin, tMax float64) (*HitRecord, bool) {
 	}, true
 }
 
+func (p *Plane) BoundingBox() AABB {
+	return InfiniteAABB()
+}
+
 func (p *Plane) GetPoint() math.Vec3 {
 	return p.Point
 }
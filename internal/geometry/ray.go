@@ -10,10 +10,16 @@ type HitRecord struct {
 	Normal    math.Vec3
 	FrontFace bool
 	Material  interface{}
+	// ObjectID identifies the scene object hit when the renderer tags
+	// objects for its object ID pass; it is 0 otherwise.
+	ObjectID  int
 }
 
+// Hittable is anything a ray can intersect. BoundingBox must enclose every
+// point Hit can return; unbounded shapes return InfiniteAABB().
 type Hittable interface {
 	Hit(ray Ray, tMin, tMax float64) (*HitRecord, bool)
+	BoundingBox() AABB
 }
 
 type AABB struct {
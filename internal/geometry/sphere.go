@@ -58,6 +58,10 @@ func (s *Sphere) Hit(ray Ray, tMin, tMax float64) (*HitRecord, bool) {
 	}, true
 }
 
+func (s *Sphere) BoundingBox() AABB {
+	return NewAABB(s.GetBoundingBox())
+}
+
 func (s *Sphere) GetCenter() math.Vec3 {
 	return s.Center
 }
@@ -81,6 +81,10 @@ func (t *Triangle) Hit(ray Ray, tMin, tMax float64) (*HitRecord, bool) {
 	}, true
 }
 
+func (t *Triangle) BoundingBox() AABB {
+	return NewAABB(t.GetBoundingBox())
+}
+
 func (t *Triangle) calculateInterpolatedNormal(u, v float64) math.Vec3 {
 	w := 1.0 - u - v
 	normal := t.Normals[0].MulScalar(w).Add(t.Normals[1].MulScalar(u)).Add(t.Normals[2].MulScalar(v))
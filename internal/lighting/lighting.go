@@ -108,28 +108,54 @@ func (dl *DirectionalLight) GetShadowRay(point math.Vec3) (math.Vec3, float64) {
 	return dl.GetDirection(point), stdmath.Inf(1)
 }
 
+// AreaLight is a rectangle centred on Position and spanned by the edge
+// vectors U and V. It is shaded like a point light at its centre, while
+// SampleShadowRay aims at points across the rectangle so that averaging
+// Samples shadow rays gives soft shadows. It emits from both faces.
 type AreaLight struct {
 	Position  math.Vec3
+	U, V      math.Vec3
 	Color     math.Vec3
 	Intensity float64
 	Size      float64
 	Samples   int
+	Attenuation Attenuation
 }
 
 func NewAreaLight(position math.Vec3, color math.Vec3, intensity, size float64) *AreaLight {
+	return NewRectAreaLight(position, math.Vec3{X: size, Y: 0, Z: 0}, math.Vec3{X: 0, Y: 0, Z: size}, color, intensity)
+}
+
+func NewRectAreaLight(position, u, v math.Vec3, color math.Vec3, intensity float64) *AreaLight {
 	return &AreaLight{
 		Position:  position,
+		U:         u,
+		V:         v,
 		Color:     color,
 		Intensity: intensity,
-		Size:      size,
+		Size:      stdmath.Sqrt(u.Cross(v).Length()),
 		Samples:   16,
+		Attenuation: Attenuation{
+			Constant:  1.0,
+			Linear:    0.09,
+			Quadratic: 0.032,
+		},
 	}
 }
 
 func (al *AreaLight) GetIntensity(point math.Vec3) float64 {
 	distance := point.Sub(al.Position).Length()
-	attenuation := 1.0 + 0.09*distance + 0.032*distance*distance
-	return al.Intensity / attenuation
+	attenuation := al.Attenuation.Constant + 
+		al.Attenuation.Linear*distance + 
+		al.Attenuation.Quadratic*distance*distance
+		
+	intensity := al.Intensity / attenuation
+	
+	normal := al.U.Cross(al.V)
+	if !normal.NearZero() {
+		intensity *= math.FastAbs(normal.Normalize().Dot(al.GetDirection(point)))
+	}
+	return intensity
 }
 
 func (al *AreaLight) GetDirection(point math.Vec3) math.Vec3 {
@@ -148,12 +174,55 @@ func (al *AreaLight) GetType() string {
 	return "area"
 }
 
+// GetShadowRay aims at the centre of the rectangle, giving a hard shadow.
 func (al *AreaLight) GetShadowRay(point math.Vec3) (math.Vec3, float64) {
-	direction := al.GetDirection(point)
-	distance := point.Sub(al.Position).Length()
-	return direction, distance
+	return al.SampleShadowRay(point, 0.5, 0.5)
+}
+
+// SampleShadowRay aims at SamplePoint(s, t).
+func (al *AreaLight) SampleShadowRay(point math.Vec3, s, t float64) (math.Vec3, float64) {
+	toLight := al.SamplePoint(s, t).Sub(point)
+	return toLight.Normalize(), toLight.Length()
+}
+
+// SamplePoint maps s and t in [0, 1) to a point on the rectangle.
+func (al *AreaLight) SamplePoint(s, t float64) math.Vec3 {
+	return al.Position.Add(al.U.MulScalar(s - 0.5)).Add(al.V.MulScalar(t - 0.5))
+}
+
+func (al *AreaLight) Area() float64 {
+	return al.U.Cross(al.V).Length()
+}
+
+func (al *AreaLight) GetNormal() math.Vec3 {
+	return al.U.Cross(al.V).Normalize()
+}
+
+// Intersect returns the distance along direction at which a ray from origin
+// crosses the rectangle, if that happens before tMax.
+func (al *AreaLight) Intersect(origin, direction math.Vec3, tMax float64) (float64, bool) {
+	normal := al.U.Cross(al.V)
+	denominator := normal.Dot(direction)
+	if math.FastAbs(denominator) < 1e-12 {
+		return 0, false
+	}
+	
+	t := normal.Dot(al.Position.Sub(origin)) / denominator
+	if t <= 0.001 || t >= tMax {
+		return 0, false
+	}
+	
+	offset := origin.Add(direction.MulScalar(t)).Sub(al.Position)
+	s := offset.Dot(al.U) / al.U.LengthSquared()
+	r := offset.Dot(al.V) / al.V.LengthSquared()
+	if s < -0.5 || s > 0.5 || r < -0.5 || r > 0.5 {
+		return 0, false
+	}
+	return t, true
 }
 
+// SpotLight is fully lit inside the CutOff cone and fades out towards the
+// OuterCutOff cone. Both cut-offs are cosines of the half-angles.
 type SpotLight struct {
 	Position  math.Vec3
 	Direction math.Vec3
@@ -161,6 +230,7 @@ type SpotLight struct {
 	Intensity float64
 	CutOff    float64
 	OuterCutOff float64
+	Attenuation Attenuation
 }
 
 func NewSpotLight(position, direction math.Vec3, color math.Vec3, intensity, cutOff, outerCutOff float64) *SpotLight {
@@ -171,24 +241,29 @@ func NewSpotLight(position, direction math.Vec3, color math.Vec3, intensity, cut
 		Intensity:    intensity,
 		CutOff:       cutOff,
 		OuterCutOff:  outerCutOff,
+		Attenuation:  Attenuation{Constant: 1.0},
 	}
 }
 
 func (sl *SpotLight) GetIntensity(point math.Vec3) float64 {
-	direction := sl.GetDirection(point)
-	cosTheta := direction.Dot(sl.Direction)
+	distance := point.Sub(sl.Position).Length()
+	attenuation := sl.Attenuation.Constant + 
+		sl.Attenuation.Linear*distance + 
+		sl.Attenuation.Quadratic*distance*distance
+	intensity := sl.Intensity / attenuation
+	
+	cosTheta := sl.GetDirection(point).MulScalar(-1).Dot(sl.Direction)
 	
 	if cosTheta < sl.OuterCutOff {
 		return 0.0
 	}
 	
-	if cosTheta > sl.CutOff {
-		return sl.Intensity
+	if cosTheta >= sl.CutOff {
+		return intensity
 	}
 	
 	epsilon := sl.CutOff - sl.OuterCutOff
-	intensity := (cosTheta - sl.OuterCutOff) / epsilon
-	return sl.Intensity * intensity
+	return intensity * (cosTheta - sl.OuterCutOff) / epsilon
 }
 
 func (sl *SpotLight) GetDirection(point math.Vec3) math.Vec3 {
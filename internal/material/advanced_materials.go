@@ -18,7 +18,7 @@ func NewGlass(refractionIndex float64, color math.Vec3) *Glass {
 	}
 }
 
-func (g *Glass) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
+func (g *Glass) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
 	attenuation := g.Color
 	
 	var refractionRatio float64
@@ -35,7 +35,7 @@ func (g *Glass) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray
 	cannotRefract := refractionRatio*sinTheta > 1.0
 	
 	var direction math.Vec3
-	if cannotRefract || reflectance(cosTheta, refractionRatio) > math.RandomFloat() {
+	if cannotRefract || reflectance(cosTheta, refractionRatio) > rng.Float64() {
 		direction = unitDirection.Reflect(hit.Normal)
 	} else {
 		direction = unitDirection.Refract(hit.Normal, refractionRatio)
@@ -77,11 +77,11 @@ func NewMirror(color math.Vec3, roughness float64) *Mirror {
 	}
 }
 
-func (m *Mirror) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
+func (m *Mirror) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
 	reflected := ray.Direction.Reflect(hit.Normal)
 	
 	if m.Roughness > 0 {
-		reflected = reflected.Add(math.RandomVec3InUnitSphere().MulScalar(m.Roughness))
+		reflected = reflected.Add(rng.Vec3InUnitSphere().MulScalar(m.Roughness))
 	}
 	
 	scattered := geometry.NewRay(hit.Point, reflected)
@@ -122,11 +122,11 @@ func NewPerfectMirror(color math.Vec3, roughness float64) *PerfectMirror {
 	}
 }
 
-func (pm *PerfectMirror) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
+func (pm *PerfectMirror) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
 	reflected := ray.Direction.Reflect(hit.Normal)
 	
 	if pm.Roughness > 0.001 {
-		perturbation := math.RandomVec3InUnitSphere().MulScalar(pm.Roughness)
+		perturbation := rng.Vec3InUnitSphere().MulScalar(pm.Roughness)
 		reflected = reflected.Add(perturbation).Normalize()
 	}
 	
@@ -188,8 +188,8 @@ func NewProceduralTexture(base Material, scale, persistence, lacunarity float64,
 	}
 }
 
-func (pt *ProceduralTexture) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
-	return pt.BaseMaterial.Scatter(ray, hit)
+func (pt *ProceduralTexture) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
+	return pt.BaseMaterial.Scatter(ray, hit, rng)
 }
 
 func (pt *ProceduralTexture) Emitted() math.Vec3 {
@@ -225,8 +225,8 @@ func NewSubsurfaceScattering(baseColor math.Vec3, scatteringRadius, phaseFunctio
 	}
 }
 
-func (sss *SubsurfaceScattering) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
-	scatterDirection := math.RandomVec3InUnitSphere()
+func (sss *SubsurfaceScattering) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
+	scatterDirection := rng.Vec3InUnitSphere()
 	
 	scatterDirection = scatterDirection.MulScalar(sss.PhaseFunction)
 	
@@ -259,13 +259,13 @@ func NewAnisotropic(baseColor math.Vec3, roughness, anisotropy float64, directio
 	}
 }
 
-func (a *Anisotropic) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
+func (a *Anisotropic) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
 	reflected := ray.Direction.Reflect(hit.Normal)
 	
 	anisotropicRoughness := a.Roughness * (1.0 + a.Anisotropy*a.Direction.Dot(hit.Normal))
 	
 	if anisotropicRoughness > 0 {
-		reflected = reflected.Add(math.RandomVec3InUnitSphere().MulScalar(anisotropicRoughness))
+		reflected = reflected.Add(rng.Vec3InUnitSphere().MulScalar(anisotropicRoughness))
 		reflected = reflected.Normalize()
 	}
 	
@@ -293,10 +293,10 @@ func NewClearcoat(baseMaterial Material, strength, roughness, ior float64) *Clea
 	}
 }
 
-func (cc *Clearcoat) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
-	baseScattered, baseAttenuation, baseHit := cc.BaseMaterial.Scatter(ray, hit)
+func (cc *Clearcoat) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
+	baseScattered, baseAttenuation, baseHit := cc.BaseMaterial.Scatter(ray, hit, rng)
 	
-	_, clearcoatAttenuation, clearcoatHit := cc.scatterClearcoat(ray, hit)
+	_, clearcoatAttenuation, clearcoatHit := cc.scatterClearcoat(ray, hit, rng)
 	
 	if baseHit && clearcoatHit {
 		blend := cc.ClearcoatStrength
@@ -311,11 +311,11 @@ func (cc *Clearcoat) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometr
 	return baseScattered, baseAttenuation, baseHit
 }
 
-func (cc *Clearcoat) scatterClearcoat(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
+func (cc *Clearcoat) scatterClearcoat(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
 	reflected := ray.Direction.Reflect(hit.Normal)
 	
 	if cc.ClearcoatRoughness > 0 {
-		reflected = reflected.Add(math.RandomVec3InUnitSphere().MulScalar(cc.ClearcoatRoughness))
+		reflected = reflected.Add(rng.Vec3InUnitSphere().MulScalar(cc.ClearcoatRoughness))
 		reflected = reflected.Normalize()
 	}
 	
@@ -345,7 +345,7 @@ func NewSheen(baseColor, sheenColor math.Vec3, sheenRoughness, sheenTint float64
 	}
 }
 
-func (s *Sheen) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
+func (s *Sheen) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
 	sheenColor := math.Vec3{
 		X: s.SheenColor.X * (1.0 - s.SheenTint) + s.BaseColor.X * s.SheenTint,
 		Y: s.SheenColor.Y * (1.0 - s.SheenTint) + s.BaseColor.Y * s.SheenTint,
@@ -355,7 +355,7 @@ func (s *Sheen) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray
 	reflected := ray.Direction.Reflect(hit.Normal)
 	
 	if s.SheenRoughness > 0 {
-		reflected = reflected.Add(math.RandomVec3InUnitSphere().MulScalar(s.SheenRoughness))
+		reflected = reflected.Add(rng.Vec3InUnitSphere().MulScalar(s.SheenRoughness))
 		reflected = reflected.Normalize()
 	}
 	
@@ -0,0 +1,327 @@
+package material
+
+import (
+	stdmath "math"
+	"raytraceGo/internal/geometry"
+	"raytraceGo/internal/math"
+)
+
+// BSDF is implemented by materials the path tracer can sample and evaluate.
+// Directions are unit vectors pointing away from the hit point: wo towards
+// the viewer and wi towards the incoming light. Eval returns f(wo, wi)
+// already multiplied by |cos| of wi against the normal, and Pdf is the
+// solid-angle density Sample uses for wi. Delta lobes such as perfect mirrors
+// and glass contribute nothing to Eval and Pdf and are only reachable
+// through Sample.
+type BSDF interface {
+	Eval(hit *geometry.HitRecord, wo, wi math.Vec3) math.Vec3
+	Pdf(hit *geometry.HitRecord, wo, wi math.Vec3) float64
+	Sample(hit *geometry.HitRecord, wo math.Vec3, u, v float64) (BSDFSample, bool)
+}
+
+// BSDFSample is a sampled incoming direction. Weight is Eval/Pdf, the factor
+// the path throughput is multiplied by. Specular marks a delta lobe, whose
+// Pdf is not a density and must not be used for MIS.
+type BSDFSample struct {
+	Direction math.Vec3
+	Weight    math.Vec3
+	Pdf       float64
+	Specular  bool
+}
+
+// Below this roughness the GGX lobe is treated as a perfect mirror.
+const minRoughness = 0.03
+
+// surfaceBSDF is the metallic-roughness model behind the opaque materials:
+// a Lambertian base under a GGX specular lobe. Metallic moves the colour
+// from the diffuse base into the specular reflectance, and specular scales
+// the dielectric reflectance at normal incidence (0.5 gives the usual 4%).
+type surfaceBSDF struct {
+	color     math.Vec3
+	roughness float64
+	metallic  float64
+	specular  float64
+}
+
+func newSurfaceBSDF(m Material) surfaceBSDF {
+	return surfaceBSDF{
+		color:     m.GetAlbedo(),
+		roughness: math.FastClamp(m.GetRoughness(), 0.0, 1.0),
+		metallic:  math.FastClamp(m.GetMetallic(), 0.0, 1.0),
+		specular:  math.FastClamp(m.GetSpecular(), 0.0, 1.0),
+	}
+}
+
+func (s surfaceBSDF) f0() math.Vec3 {
+	dielectric := 0.08 * s.specular
+	return math.Vec3{X: dielectric, Y: dielectric, Z: dielectric}.Lerp(s.color, s.metallic)
+}
+
+func (s surfaceBSDF) diffuse() math.Vec3 {
+	return s.color.MulScalar((1.0 - s.metallic) * (1.0 - 0.08*s.specular))
+}
+
+func (s surfaceBSDF) alpha() float64 {
+	return s.roughness * s.roughness
+}
+
+func (s surfaceBSDF) isDelta() bool {
+	return s.roughness < minRoughness
+}
+
+// specularProbability is the chance of sampling the specular lobe rather
+// than the diffuse one, proportional to their reflectance.
+func (s surfaceBSDF) specularProbability() float64 {
+	specularWeight := luminance(s.f0())
+	diffuseWeight := luminance(s.diffuse())
+	if specularWeight <= 0 {
+		return 0.0
+	}
+	if diffuseWeight <= 0 {
+		return 1.0
+	}
+	return stdmath.Max(specularWeight/(specularWeight+diffuseWeight), 0.25)
+}
+
+func (s surfaceBSDF) Eval(hit *geometry.HitRecord, wo, wi math.Vec3) math.Vec3 {
+	cosI := wi.Dot(hit.Normal)
+	if cosI <= 0 {
+		return math.Vec3{}
+	}
+	
+	result := s.diffuse().MulScalar(cosI / stdmath.Pi)
+	
+	cosO := wo.Dot(hit.Normal)
+	if !s.isDelta() && cosO > 0 {
+		h := wo.Add(wi).Normalize()
+		alpha := s.alpha()
+		d := ggxD(h.Dot(hit.Normal), alpha)
+		g := smithG1(cosO, alpha) * smithG1(cosI, alpha)
+		f := schlick(s.f0(), wo.Dot(h))
+		result = result.Add(f.MulScalar(d * g / (4.0 * cosO)))
+	}
+	
+	return result
+}
+
+func (s surfaceBSDF) Pdf(hit *geometry.HitRecord, wo, wi math.Vec3) float64 {
+	cosI := wi.Dot(hit.Normal)
+	if cosI <= 0 {
+		return 0.0
+	}
+	
+	pSpecular := s.specularProbability()
+	pdf := (1.0 - pSpecular) * cosI / stdmath.Pi
+	
+	if !s.isDelta() && pSpecular > 0 {
+		h := wo.Add(wi).Normalize()
+		woDotH := wo.Dot(h)
+		if woDotH > 0 {
+			cosH := h.Dot(hit.Normal)
+			pdf += pSpecular * ggxD(cosH, s.alpha()) * cosH / (4.0 * woDotH)
+		}
+	}
+	
+	return pdf
+}
+
+func (s surfaceBSDF) Sample(hit *geometry.HitRecord, wo math.Vec3, u, v float64) (BSDFSample, bool) {
+	pSpecular := s.specularProbability()
+	
+	var wi math.Vec3
+	if u < pSpecular {
+		u /= pSpecular
+		
+		if s.isDelta() {
+			wi = wo.MulScalar(-1).Reflect(hit.Normal)
+			cosO := math.FastClamp(wo.Dot(hit.Normal), 0.0, 1.0)
+			weight := schlick(s.f0(), cosO).DivScalar(pSpecular)
+			return BSDFSample{Direction: wi, Weight: weight, Pdf: pSpecular, Specular: true}, wi.Dot(hit.Normal) > 0
+		}
+		
+		h := sampleGGX(hit.Normal, s.alpha(), u, v)
+		wi = wo.MulScalar(-1).Reflect(h)
+	} else {
+		u = (u - pSpecular) / (1.0 - pSpecular)
+		wi = sampleCosineHemisphere(hit.Normal, u, v)
+	}
+	
+	pdf := s.Pdf(hit, wo, wi)
+	if pdf <= 0 {
+		return BSDFSample{}, false
+	}
+	
+	return BSDFSample{Direction: wi, Weight: s.Eval(hit, wo, wi).DivScalar(pdf), Pdf: pdf}, true
+}
+
+// refractiveBSDF is a smooth dielectric interface that reflects or refracts
+// in proportion to the Fresnel reflectance, tinting transmitted and
+// reflected light alike.
+type refractiveBSDF struct {
+	refractionIndex float64
+	tint            math.Vec3
+}
+
+func (d refractiveBSDF) Eval(hit *geometry.HitRecord, wo, wi math.Vec3) math.Vec3 {
+	return math.Vec3{}
+}
+
+func (d refractiveBSDF) Pdf(hit *geometry.HitRecord, wo, wi math.Vec3) float64 {
+	return 0.0
+}
+
+func (d refractiveBSDF) Sample(hit *geometry.HitRecord, wo math.Vec3, u, v float64) (BSDFSample, bool) {
+	refractionRatio := d.refractionIndex
+	if hit.FrontFace {
+		refractionRatio = 1.0 / d.refractionIndex
+	}
+	
+	incoming := wo.MulScalar(-1)
+	cosTheta := stdmath.Min(wo.Dot(hit.Normal), 1.0)
+	sinTheta := stdmath.Sqrt(stdmath.Max(0.0, 1.0-cosTheta*cosTheta))
+	
+	var direction math.Vec3
+	if refractionRatio*sinTheta > 1.0 || u < reflectance(cosTheta, refractionRatio) {
+		direction = incoming.Reflect(hit.Normal)
+	} else {
+		direction = incoming.Refract(hit.Normal, refractionRatio)
+	}
+	
+	return BSDFSample{Direction: direction.Normalize(), Weight: d.tint, Pdf: 1.0, Specular: true}, true
+}
+
+func (l *Lambertian) Eval(hit *geometry.HitRecord, wo, wi math.Vec3) math.Vec3 {
+	return newSurfaceBSDF(l).Eval(hit, wo, wi)
+}
+
+func (l *Lambertian) Pdf(hit *geometry.HitRecord, wo, wi math.Vec3) float64 {
+	return newSurfaceBSDF(l).Pdf(hit, wo, wi)
+}
+
+func (l *Lambertian) Sample(hit *geometry.HitRecord, wo math.Vec3, u, v float64) (BSDFSample, bool) {
+	return newSurfaceBSDF(l).Sample(hit, wo, u, v)
+}
+
+func (m *Metal) Eval(hit *geometry.HitRecord, wo, wi math.Vec3) math.Vec3 {
+	return newSurfaceBSDF(m).Eval(hit, wo, wi)
+}
+
+func (m *Metal) Pdf(hit *geometry.HitRecord, wo, wi math.Vec3) float64 {
+	return newSurfaceBSDF(m).Pdf(hit, wo, wi)
+}
+
+func (m *Metal) Sample(hit *geometry.HitRecord, wo math.Vec3, u, v float64) (BSDFSample, bool) {
+	return newSurfaceBSDF(m).Sample(hit, wo, u, v)
+}
+
+func (s *ShinyMaterial) Eval(hit *geometry.HitRecord, wo, wi math.Vec3) math.Vec3 {
+	return newSurfaceBSDF(s).Eval(hit, wo, wi)
+}
+
+func (s *ShinyMaterial) Pdf(hit *geometry.HitRecord, wo, wi math.Vec3) float64 {
+	return newSurfaceBSDF(s).Pdf(hit, wo, wi)
+}
+
+func (s *ShinyMaterial) Sample(hit *geometry.HitRecord, wo math.Vec3, u, v float64) (BSDFSample, bool) {
+	return newSurfaceBSDF(s).Sample(hit, wo, u, v)
+}
+
+func (m *Mirror) Eval(hit *geometry.HitRecord, wo, wi math.Vec3) math.Vec3 {
+	return newSurfaceBSDF(m).Eval(hit, wo, wi)
+}
+
+func (m *Mirror) Pdf(hit *geometry.HitRecord, wo, wi math.Vec3) float64 {
+	return newSurfaceBSDF(m).Pdf(hit, wo, wi)
+}
+
+func (m *Mirror) Sample(hit *geometry.HitRecord, wo math.Vec3, u, v float64) (BSDFSample, bool) {
+	return newSurfaceBSDF(m).Sample(hit, wo, u, v)
+}
+
+func (pm *PerfectMirror) Eval(hit *geometry.HitRecord, wo, wi math.Vec3) math.Vec3 {
+	return newSurfaceBSDF(pm).Eval(hit, wo, wi)
+}
+
+func (pm *PerfectMirror) Pdf(hit *geometry.HitRecord, wo, wi math.Vec3) float64 {
+	return newSurfaceBSDF(pm).Pdf(hit, wo, wi)
+}
+
+func (pm *PerfectMirror) Sample(hit *geometry.HitRecord, wo math.Vec3, u, v float64) (BSDFSample, bool) {
+	return newSurfaceBSDF(pm).Sample(hit, wo, u, v)
+}
+
+func (d *Dielectric) Eval(hit *geometry.HitRecord, wo, wi math.Vec3) math.Vec3 {
+	return math.Vec3{}
+}
+
+func (d *Dielectric) Pdf(hit *geometry.HitRecord, wo, wi math.Vec3) float64 {
+	return 0.0
+}
+
+func (d *Dielectric) Sample(hit *geometry.HitRecord, wo math.Vec3, u, v float64) (BSDFSample, bool) {
+	return refractiveBSDF{refractionIndex: d.RefractionIndex, tint: math.Vec3{X: 1, Y: 1, Z: 1}}.Sample(hit, wo, u, v)
+}
+
+func (g *Glass) Eval(hit *geometry.HitRecord, wo, wi math.Vec3) math.Vec3 {
+	return math.Vec3{}
+}
+
+func (g *Glass) Pdf(hit *geometry.HitRecord, wo, wi math.Vec3) float64 {
+	return 0.0
+}
+
+func (g *Glass) Sample(hit *geometry.HitRecord, wo math.Vec3, u, v float64) (BSDFSample, bool) {
+	return refractiveBSDF{refractionIndex: g.RefractionIndex, tint: g.Color}.Sample(hit, wo, u, v)
+}
+
+// ggxD is the GGX normal distribution for a half vector at cosH from the
+// normal.
+func ggxD(cosH, alpha float64) float64 {
+	if cosH <= 0 {
+		return 0.0
+	}
+	alpha2 := alpha * alpha
+	denominator := cosH*cosH*(alpha2-1.0) + 1.0
+	return alpha2 / (stdmath.Pi * denominator * denominator)
+}
+
+// smithG1 is the Smith masking term for GGX.
+func smithG1(cosTheta, alpha float64) float64 {
+	alpha2 := alpha * alpha
+	return 2.0 * cosTheta / (cosTheta + stdmath.Sqrt(alpha2+(1.0-alpha2)*cosTheta*cosTheta))
+}
+
+// sampleGGX draws a half vector around normal with density D(h)·cos.
+func sampleGGX(normal math.Vec3, alpha, u, v float64) math.Vec3 {
+	alpha2 := alpha * alpha
+	cosTheta := stdmath.Sqrt((1.0 - u) / (1.0 + (alpha2-1.0)*u))
+	sinTheta := stdmath.Sqrt(stdmath.Max(0.0, 1.0-cosTheta*cosTheta))
+	phi := 2.0 * stdmath.Pi * v
+	return toWorld(normal, math.Vec3{X: sinTheta * stdmath.Cos(phi), Y: sinTheta * stdmath.Sin(phi), Z: cosTheta})
+}
+
+func sampleCosineHemisphere(normal math.Vec3, u, v float64) math.Vec3 {
+	r := stdmath.Sqrt(u)
+	phi := 2.0 * stdmath.Pi * v
+	return toWorld(normal, math.Vec3{X: r * stdmath.Cos(phi), Y: r * stdmath.Sin(phi), Z: stdmath.Sqrt(stdmath.Max(0.0, 1.0-u))})
+}
+
+// toWorld maps a direction from the frame whose Z axis is normal into world
+// space, using the branchless basis of Duff et al.
+func toWorld(normal, local math.Vec3) math.Vec3 {
+	sign := stdmath.Copysign(1.0, normal.Z)
+	a := -1.0 / (sign + normal.Z)
+	b := normal.X * normal.Y * a
+	tangent := math.Vec3{X: 1.0 + sign*normal.X*normal.X*a, Y: sign * b, Z: -sign * normal.X}
+	bitangent := math.Vec3{X: b, Y: sign + normal.Y*normal.Y*a, Z: -normal.Y}
+	return tangent.MulScalar(local.X).Add(bitangent.MulScalar(local.Y)).Add(normal.MulScalar(local.Z)).Normalize()
+}
+
+func schlick(f0 math.Vec3, cosTheta float64) math.Vec3 {
+	weight := stdmath.Pow(1.0-math.FastClamp(cosTheta, 0.0, 1.0), 5)
+	return f0.Add(math.Vec3{X: 1, Y: 1, Z: 1}.Sub(f0).MulScalar(weight))
+}
+
+func luminance(color math.Vec3) float64 {
+	return 0.2126*color.X + 0.7152*color.Y + 0.0722*color.Z
+}
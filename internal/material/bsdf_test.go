@@ -0,0 +1,69 @@
+package material
+
+import (
+	stdmath "math"
+	"raytraceGo/internal/geometry"
+	"raytraceGo/internal/math"
+	"testing"
+)
+
+func TestBSDFSampleMatchesEvalAndPdf(t *testing.T) {
+	hit := &geometry.HitRecord{Normal: math.Vec3{X: 0, Y: 1, Z: 0}, FrontFace: true}
+	wo := math.Vec3{X: 0.3, Y: 0.8, Z: -0.2}.Normalize()
+	rng := math.NewFastRandom(3)
+	
+	materials := map[string]BSDF{
+		"lambertian": NewLambertian(math.Vec3{X: 0.8, Y: 0.5, Z: 0.2}),
+		"rough metal": NewMetal(math.Vec3{X: 0.9, Y: 0.7, Z: 0.4}, 0.4, 1.0, 1.0),
+		"plastic": NewShinyMaterial(math.Vec3{X: 0.2, Y: 0.3, Z: 0.9}, 0.3, 0.0, 0.5),
+	}
+	
+	for name, bsdf := range materials {
+		for i := 0; i < 1000; i++ {
+			sample, ok := bsdf.Sample(hit, wo, rng.Float64(), rng.Float64())
+			if !ok {
+				continue
+			}
+			
+			pdf := bsdf.Pdf(hit, wo, sample.Direction)
+			if stdmath.Abs(pdf-sample.Pdf) > 1e-9*stdmath.Max(1, pdf) {
+				t.Fatalf("%s Pdf failed: got %v, want sampled pdf %v", name, pdf, sample.Pdf)
+			}
+			
+			want := bsdf.Eval(hit, wo, sample.Direction).DivScalar(pdf)
+			if sample.Weight.Sub(want).Length() > 1e-9*stdmath.Max(1, want.Length()) {
+				t.Fatalf("%s Sample weight failed: got %v, want %v", name, sample.Weight, want)
+			}
+		}
+	}
+}
+
+// TestBSDFWhiteFurnace checks that the estimated albedo of each material,
+// the mean sample weight, never exceeds one and matches a Lambertian's colour.
+func TestBSDFWhiteFurnace(t *testing.T) {
+	hit := &geometry.HitRecord{Normal: math.Vec3{X: 0, Y: 0, Z: 1}, FrontFace: true}
+	wo := math.Vec3{X: 0.5, Y: 0, Z: 0.7}.Normalize()
+	rng := math.NewFastRandom(11)
+	
+	estimate := func(bsdf BSDF) math.Vec3 {
+		const samples = 200000
+		sum := math.Vec3{}
+		for i := 0; i < samples; i++ {
+			if sample, ok := bsdf.Sample(hit, wo, rng.Float64(), rng.Float64()); ok {
+				sum = sum.Add(sample.Weight)
+			}
+		}
+		return sum.DivScalar(samples)
+	}
+	
+	if albedo := estimate(NewLambertian(math.Vec3{X: 0.8, Y: 0.8, Z: 0.8})); stdmath.Abs(albedo.X-0.8) > 0.01 {
+		t.Errorf("Lambertian furnace failed: got %v, want 0.8", albedo.X)
+	}
+	
+	white := math.Vec3{X: 1, Y: 1, Z: 1}
+	for _, bsdf := range []BSDF{NewMetal(white, 0.0, 1.0, 1.0), NewMetal(white, 0.5, 1.0, 1.0), NewShinyMaterial(white, 0.2, 0.3, 1.0)} {
+		if albedo := estimate(bsdf); albedo.X > 1.01 {
+			t.Errorf("%T furnace failed: got albedo %v, want at most 1", bsdf, albedo.X)
+		}
+	}
+}
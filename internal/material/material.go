@@ -7,7 +7,7 @@ import (
 )
 
 type Material interface {
-	Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool)
+	Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool)
 	Emitted() math.Vec3
 	GetAlbedo() math.Vec3
 	GetRoughness() float64
@@ -23,8 +23,8 @@ func NewLambertian(albedo math.Vec3) *Lambertian {
 	return &Lambertian{Albedo: albedo}
 }
 
-func (l *Lambertian) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
-	scatterDirection := hit.Normal.Add(math.RandomVec3InUnitSphere())
+func (l *Lambertian) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
+	scatterDirection := hit.Normal.Add(rng.Vec3InUnitSphere())
 	if scatterDirection.NearZero() {
 		scatterDirection = hit.Normal
 	}
@@ -72,11 +72,11 @@ func NewMetal(albedo math.Vec3, roughness, metallic, specular float64) *Metal {
 	}
 }
 
-func (m *Metal) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
+func (m *Metal) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
 	reflected := ray.Direction.Reflect(hit.Normal)
 	
 	if m.Roughness > 0.001 {
-		perturbation := math.RandomVec3InUnitSphere().MulScalar(m.Roughness)
+		perturbation := rng.Vec3InUnitSphere().MulScalar(m.Roughness)
 		reflected = reflected.Add(perturbation).Normalize()
 	}
 	
@@ -166,11 +166,11 @@ func NewShinyMaterial(albedo math.Vec3, roughness, metallic, specular float64) *
 	}
 }
 
-func (s *ShinyMaterial) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
+func (s *ShinyMaterial) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
 	reflected := ray.Direction.Reflect(hit.Normal)
 	
 	if s.Roughness > 0 {
-		reflected = reflected.Add(math.RandomVec3InUnitSphere().MulScalar(s.Roughness))
+		reflected = reflected.Add(rng.Vec3InUnitSphere().MulScalar(s.Roughness))
 		reflected = reflected.Normalize()
 	}
 	
@@ -232,7 +232,7 @@ func NewDielectric(refractionIndex float64) *Dielectric {
 	return &Dielectric{RefractionIndex: refractionIndex}
 }
 
-func (d *Dielectric) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
+func (d *Dielectric) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
 	attenuation := math.Vec3{X: 1.0, Y: 1.0, Z: 1.0}
 	
 	var refractionRatio float64
@@ -249,7 +249,7 @@ func (d *Dielectric) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometr
 	cannotRefract := refractionRatio*sinTheta > 1.0
 	
 	var direction math.Vec3
-	if cannotRefract || reflectance(cosTheta, refractionRatio) > math.RandomFloat() {
+	if cannotRefract || reflectance(cosTheta, refractionRatio) > rng.Float64() {
 		direction = unitDirection.Reflect(hit.Normal)
 	} else {
 		direction = unitDirection.Refract(hit.Normal, refractionRatio)
@@ -293,7 +293,7 @@ func NewDiffuseLight(emit math.Vec3) *DiffuseLight {
 	return &DiffuseLight{Emit: emit}
 }
 
-func (dl *DiffuseLight) Scatter(ray geometry.Ray, hit *geometry.HitRecord) (geometry.Ray, math.Vec3, bool) {
+func (dl *DiffuseLight) Scatter(ray geometry.Ray, hit *geometry.HitRecord, rng *math.FastRandom) (geometry.Ray, math.Vec3, bool) {
 	return geometry.Ray{}, math.Vec3{}, false
 }
 
@@ -4,12 +4,42 @@ import (
 	stdmath "math"
 )
 
+// FastRandom is a small xorshift generator. Unlike the global source behind
+// RandomFloat it is not shared, so each pixel sample can own a stream.
 type FastRandom struct {
 	state uint64
 }
 
 func NewFastRandom(seed uint64) *FastRandom {
-	return &FastRandom{state: seed}
+	fr := &FastRandom{}
+	fr.Seed(seed)
+	return fr
+}
+
+// Seed restarts the stream. The seed is scrambled first, so neighbouring
+// seeds, including zero, give unrelated streams.
+func (fr *FastRandom) Seed(seed uint64) {
+	fr.state = splitMix64(seed)
+	if fr.state == 0 {
+		fr.state = 0x9e3779b97f4a7c15
+	}
+}
+
+// DeriveSeed hashes keys such as pixel coordinates and a sample index into
+// seed, giving every combination of keys its own stream.
+func DeriveSeed(seed uint64, keys ...uint64) uint64 {
+	hash := splitMix64(seed)
+	for _, key := range keys {
+		hash = splitMix64(hash ^ key)
+	}
+	return hash
+}
+
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
 }
 
 func (fr *FastRandom) Next() uint64 {
@@ -19,8 +49,9 @@ func (fr *FastRandom) Next() uint64 {
 	return fr.state * 2685821657736338717
 }
 
+// Float64 returns a number in [0, 1).
 func (fr *FastRandom) Float64() float64 {
-	return float64(fr.Next()) / float64(^uint64(0))
+	return float64(fr.Next()>>11) / (1 << 53)
 }
 
 func (fr *FastRandom) Float64Range(min, max float64) float64 {
@@ -28,7 +59,29 @@ func (fr *FastRandom) Float64Range(min, max float64) float64 {
 }
 
 func (fr *FastRandom) IntRange(min, max int) int {
-	return min + int(fr.Next())%(max-min+1)
+	return min + int(fr.Next()%uint64(max-min+1))
+}
+
+func (fr *FastRandom) Vec3InUnitSphere() Vec3 {
+	for {
+		p := Vec3{X: fr.Float64()*2 - 1, Y: fr.Float64()*2 - 1, Z: fr.Float64()*2 - 1}
+		if p.LengthSquared() < 1 {
+			return p
+		}
+	}
+}
+
+func (fr *FastRandom) Vec3InUnitDisk() Vec3 {
+	for {
+		p := Vec3{X: fr.Float64()*2 - 1, Y: fr.Float64()*2 - 1, Z: 0}
+		if p.LengthSquared() < 1 {
+			return p
+		}
+	}
+}
+
+func (fr *FastRandom) UnitVector() Vec3 {
+	return fr.Vec3InUnitSphere().Normalize()
 }
 
 func FastSin(x float64) float64 {
@@ -584,4 +637,5 @@ func FastIsDecagonal(x float64) bool {
 	discriminant := 16 + 20*n
 	root := int(FastSqrt(float64(discriminant)))
 	return root*root == discriminant && (root+4)%10 == 0
-} 
\ No newline at end of file
+}
+
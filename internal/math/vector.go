@@ -113,6 +113,11 @@ func (v Vec3) NearZero() bool {
 	return math.Abs(v.X) < s && math.Abs(v.Y) < s && math.Abs(v.Z) < s
 }
 
+// Luminance is the Rec. 709 relative luminance of a linear RGB color.
+func (v Vec3) Luminance() float64 {
+	return 0.2126*v.X + 0.7152*v.Y + 0.0722*v.Z
+}
+
 func (v Vec3) Lerp(other Vec3, t float64) Vec3 {
 	return v.Add(other.Sub(v).MulScalar(t))
 }
@@ -173,6 +178,26 @@ func FastVec3Distance(a, b Vec3) float64 {
 	return diff.FastLength()
 }
 
+func FastVec3MulScalar(v Vec3, scalar float64) Vec3 {
+	return Vec3{X: v.X * scalar, Y: v.Y * scalar, Z: v.Z * scalar}
+}
+
+func FastVec3Dot(a, b Vec3) float64 {
+	return a.X*b.X + a.Y*b.Y + a.Z*b.Z
+}
+
+func FastVec3Normalize(v Vec3) Vec3 {
+	return v.FastNormalize()
+}
+
+func FastVec3Lerp(a, b Vec3, t float64) Vec3 {
+	return Vec3{
+		X: a.X + t*(b.X-a.X),
+		Y: a.Y + t*(b.Y-a.Y),
+		Z: a.Z + t*(b.Z-a.Z),
+	}
+}
+
 func (v *Vec3) UnmarshalJSON(data []byte) error {
 	var arr []float64
 	if err := json.Unmarshal(data, &arr); err == nil {
@@ -67,6 +67,7 @@ type ProgressReporter struct {
 	reportInterval time.Duration
 	ctx           context.Context
 	cancel        context.CancelFunc
+	done          chan struct{}
 }
 
 func NewMetricsCollector(ctx context.Context) *MetricsCollector {
@@ -237,6 +238,7 @@ func NewProgressReporter(ctx context.Context, totalPixels int64) *ProgressReport
 }
 
 func (pr *ProgressReporter) Start() {
+	pr.done = make(chan struct{})
 	go pr.reportProgress()
 }
 
@@ -244,11 +246,24 @@ func (pr *ProgressReporter) Stop() {
 	pr.cancel()
 }
 
+// Finish stops reporting and prints the final progress, ending the line so
+// that later output does not run into the progress bar.
+func (pr *ProgressReporter) Finish() {
+	pr.cancel()
+	if pr.done != nil {
+		<-pr.done
+	}
+	pr.printProgress()
+	fmt.Println()
+}
+
 func (pr *ProgressReporter) UpdateProgress(completedPixels int64) {
 	atomic.StoreInt64(&pr.completedPixels, completedPixels)
 }
 
 func (pr *ProgressReporter) reportProgress() {
+	defer close(pr.done)
+	
 	ticker := time.NewTicker(pr.reportInterval)
 	defer ticker.Stop()
 	
@@ -1,8 +1,10 @@
 package optimization
 
 import (
+	stdmath "math"
 	"raytraceGo/internal/geometry"
 	"raytraceGo/internal/math"
+	"sort"
 	"sync"
 )
 
@@ -13,6 +15,12 @@ type BVH struct {
 	IsLeaf      bool
 }
 
+const sahBins = 12
+
+// NewBVH builds a bounding volume hierarchy over objects[start:end], which it
+// reorders in place. Each node splits along the longest axis of its object
+// centroids at the bin boundary with the lowest surface area heuristic cost.
+// All objects must be bounded; NewWorld handles scenes with planes.
 func NewBVH(objects []geometry.Hittable, start, end int) *BVH {
 	if end-start == 1 {
 		return &BVH{
@@ -23,14 +31,16 @@ func NewBVH(objects []geometry.Hittable, start, end int) *BVH {
 	}
 	
 	box := objects[start].BoundingBox()
+	centroidBox := pointBox(box.Centroid())
 	for i := start + 1; i < end; i++ {
-		box = surroundingBox(box, objects[i].BoundingBox())
+		objectBox := objects[i].BoundingBox()
+		box = surroundingBox(box, objectBox)
+		centroidBox = surroundingBox(centroidBox, pointBox(objectBox.Centroid()))
 	}
 	
-	_ = longestAxis(box)
-	
+	axis := longestAxis(centroidBox)
 	
-	mid := (start + end) / 2
+	mid := start + sahPartition(objects[start:end], axis, centroidBox)
 	
 	bvh := &BVH{
 		Box: box,
@@ -42,6 +52,89 @@ func NewBVH(objects []geometry.Hittable, start, end int) *BVH {
 	return bvh
 }
 
+// sahPartition reorders objects so the left child comes first and returns
+// its size. It falls back to a median split when every centroid lands on the
+// same side, e.g. when all centroids coincide.
+func sahPartition(objects []geometry.Hittable, axis int, centroidBox geometry.AABB) int {
+	lo, hi := axisValue(centroidBox.Min, axis), axisValue(centroidBox.Max, axis)
+	if hi > lo {
+		var binBoxes [sahBins]geometry.AABB
+		var binCounts [sahBins]int
+		
+		for _, object := range objects {
+			objectBox := object.BoundingBox()
+			bin := int(sahBins * (axisValue(objectBox.Centroid(), axis) - lo) / (hi - lo))
+			if bin >= sahBins {
+				bin = sahBins - 1
+			}
+			if binCounts[bin] == 0 {
+				binBoxes[bin] = objectBox
+			} else {
+				binBoxes[bin] = surroundingBox(binBoxes[bin], objectBox)
+			}
+			binCounts[bin]++
+		}
+		
+		// Sweep from the right to get the cost of the right side of each split,
+		// then from the left to find the cheapest split.
+		var rightAreas [sahBins]float64
+		var rightCounts [sahBins]int
+		var rightBox geometry.AABB
+		count := 0
+		for i := sahBins - 1; i > 0; i-- {
+			if binCounts[i] > 0 {
+				if count == 0 {
+					rightBox = binBoxes[i]
+				} else {
+					rightBox = surroundingBox(rightBox, binBoxes[i])
+				}
+				count += binCounts[i]
+			}
+			rightAreas[i] = rightBox.SurfaceArea()
+			rightCounts[i] = count
+		}
+		
+		bestSplit := -1
+		bestCost := stdmath.Inf(1)
+		var leftBox geometry.AABB
+		count = 0
+		for i := 1; i < sahBins; i++ {
+			if binCounts[i-1] > 0 {
+				if count == 0 {
+					leftBox = binBoxes[i-1]
+				} else {
+					leftBox = surroundingBox(leftBox, binBoxes[i-1])
+				}
+				count += binCounts[i-1]
+			}
+			if count == 0 || rightCounts[i] == 0 {
+				continue
+			}
+			
+			cost := float64(count)*leftBox.SurfaceArea() + float64(rightCounts[i])*rightAreas[i]
+			if cost < bestCost {
+				bestCost = cost
+				bestSplit = i
+			}
+		}
+		
+		if bestSplit > 0 {
+			split := lo + (hi-lo)*float64(bestSplit)/sahBins
+			left, right := partitionObjects(objects, axis, split)
+			if len(left) > 0 && len(right) > 0 {
+				copy(objects, left)
+				copy(objects[len(left):], right)
+				return len(left)
+			}
+		}
+	}
+	
+	sort.Slice(objects, func(i, j int) bool {
+		return axisValue(objects[i].BoundingBox().Centroid(), axis) < axisValue(objects[j].BoundingBox().Centroid(), axis)
+	})
+	return len(objects) / 2
+}
+
 func (bvh *BVH) Hit(ray geometry.Ray, tMin, tMax float64) (*geometry.HitRecord, bool) {
 	if !bvh.Box.Hit(ray, tMin, tMax) {
 		return nil, false
@@ -52,26 +145,79 @@ func (bvh *BVH) Hit(ray geometry.Ray, tMin, tMax float64) (*geometry.HitRecord,
 	}
 	
 	hitLeftRec, hitLeftOk := bvh.Left.Hit(ray, tMin, tMax)
-	hitRightRec, hitRightOk := bvh.Right.Hit(ray, tMin, tMax)
+	if hitLeftOk {
+		tMax = hitLeftRec.T
+	}
 	
-	if hitLeftOk && hitRightOk {
-		if hitLeftRec.T < hitRightRec.T {
-			return hitLeftRec, true
-		}
-		return hitRightRec, true
-	} else if hitLeftOk {
-		return hitLeftRec, true
-	} else if hitRightOk {
+	hitRightRec, hitRightOk := bvh.Right.Hit(ray, tMin, tMax)
+	if hitRightOk {
 		return hitRightRec, true
 	}
 	
-	return nil, false
+	return hitLeftRec, hitLeftOk
 }
 
 func (bvh *BVH) BoundingBox() geometry.AABB {
 	return bvh.Box
 }
 
+// World is the top-level acceleration structure of a scene: a BVH over the
+// bounded objects plus the unbounded ones, such as planes, which are tested
+// against every ray.
+type World struct {
+	bvh       *BVH
+	unbounded []geometry.Hittable
+}
+
+func NewWorld(objects []geometry.Hittable) *World {
+	world := &World{}
+	
+	var bounded []geometry.Hittable
+	for _, object := range objects {
+		if object.BoundingBox().IsBounded() {
+			bounded = append(bounded, object)
+		} else {
+			world.unbounded = append(world.unbounded, object)
+		}
+	}
+	
+	if len(bounded) > 0 {
+		world.bvh = NewBVH(bounded, 0, len(bounded))
+	}
+	
+	return world
+}
+
+func (w *World) Hit(ray geometry.Ray, tMin, tMax float64) (*geometry.HitRecord, bool) {
+	var closestHit *geometry.HitRecord
+	
+	if w.bvh != nil {
+		if hitRecord, hit := w.bvh.Hit(ray, tMin, tMax); hit {
+			closestHit = hitRecord
+			tMax = hitRecord.T
+		}
+	}
+	
+	for _, object := range w.unbounded {
+		if hitRecord, hit := object.Hit(ray, tMin, tMax); hit {
+			closestHit = hitRecord
+			tMax = hitRecord.T
+		}
+	}
+	
+	return closestHit, closestHit != nil
+}
+
+func (w *World) BoundingBox() geometry.AABB {
+	if len(w.unbounded) > 0 {
+		return geometry.InfiniteAABB()
+	}
+	if w.bvh == nil {
+		return geometry.AABB{}
+	}
+	return w.bvh.Box
+}
+
 type Octree struct {
 	Center   math.Vec3
 	Size     float64
@@ -170,6 +316,13 @@ func (ot *Octree) Hit(ray geometry.Ray, tMin, tMax float64) (*geometry.HitRecord
 	return closestHit, closestHit != nil
 }
 
+func (ot *Octree) BoundingBox() geometry.AABB {
+	return geometry.AABB{
+		Min: ot.Center.Sub(math.Vec3{X: ot.Size, Y: ot.Size, Z: ot.Size}),
+		Max: ot.Center.Add(math.Vec3{X: ot.Size, Y: ot.Size, Z: ot.Size}),
+	}
+}
+
 type KDTree struct {
 	Left, Right *KDTree
 	Axis        int
@@ -425,6 +578,21 @@ func surroundingBox(box0, box1 geometry.AABB) geometry.AABB {
 	}
 }
 
+func pointBox(point math.Vec3) geometry.AABB {
+	return geometry.AABB{Min: point, Max: point}
+}
+
+func axisValue(v math.Vec3, axis int) float64 {
+	switch axis {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	default:
+		return v.Z
+	}
+}
+
 func longestAxis(box geometry.AABB) int {
 	extent := box.Max.Sub(box.Min)
 	if extent.X > extent.Y && extent.X > extent.Z {
@@ -0,0 +1,86 @@
+package optimization
+
+import (
+	"raytraceGo/internal/geometry"
+	"raytraceGo/internal/math"
+	"testing"
+)
+
+func randomScene(rng *math.FastRandom, count int) []geometry.Hittable {
+	objects := make([]geometry.Hittable, 0, count)
+	for i := 0; i < count; i++ {
+		center := math.Vec3{X: rng.Float64Range(-20, 20), Y: rng.Float64Range(-20, 20), Z: rng.Float64Range(-20, 20)}
+		if i%2 == 0 {
+			objects = append(objects, geometry.NewSphere(center, rng.Float64Range(0.1, 1.5), nil))
+		} else {
+			offset := math.Vec3{X: rng.Float64Range(-1, 1), Y: rng.Float64Range(-1, 1), Z: rng.Float64Range(-1, 1)}
+			objects = append(objects, geometry.NewTriangle(center, center.Add(offset), center.Add(offset.Cross(math.Vec3{X: 0, Y: 1, Z: 0})), nil))
+		}
+	}
+	return objects
+}
+
+func bruteForceHit(objects []geometry.Hittable, ray geometry.Ray, tMin, tMax float64) (*geometry.HitRecord, bool) {
+	var closest *geometry.HitRecord
+	for _, object := range objects {
+		if hit, ok := object.Hit(ray, tMin, tMax); ok {
+			closest = hit
+			tMax = hit.T
+		}
+	}
+	return closest, closest != nil
+}
+
+func TestWorldMatchesBruteForce(t *testing.T) {
+	rng := math.NewFastRandom(42)
+	objects := randomScene(rng, 500)
+	objects = append(objects, geometry.NewPlane(math.Vec3{X: 0, Y: -25, Z: 0}, math.Vec3{X: 0, Y: 1, Z: 0}, nil))
+	
+	world := NewWorld(append([]geometry.Hittable(nil), objects...))
+	
+	for i := 0; i < 2000; i++ {
+		origin := math.Vec3{X: rng.Float64Range(-30, 30), Y: rng.Float64Range(-30, 30), Z: rng.Float64Range(-30, 30)}
+		direction := math.Vec3{X: rng.Float64Range(-1, 1), Y: rng.Float64Range(-1, 1), Z: rng.Float64Range(-1, 1)}.Normalize()
+		ray := geometry.NewRay(origin, direction)
+		
+		want, wantOk := bruteForceHit(objects, ray, 0.001, 1e9)
+		got, gotOk := world.Hit(ray, 0.001, 1e9)
+		
+		if gotOk != wantOk || (gotOk && got.T != want.T) {
+			t.Fatalf("World.Hit failed for ray %v: got (%v, %v), want (%v, %v)", ray, got, gotOk, want, wantOk)
+		}
+	}
+}
+
+func TestBVHBoundsEveryObject(t *testing.T) {
+	objects := randomScene(math.NewFastRandom(7), 100)
+	bvh := NewBVH(objects, 0, len(objects))
+	
+	var check func(node *BVH) int
+	check = func(node *BVH) int {
+		if node.IsLeaf {
+			return 1
+		}
+		for _, child := range []*BVH{node.Left, node.Right} {
+			if surroundingBox(node.Box, child.Box) != node.Box {
+				t.Errorf("BVH node box %v does not contain child box %v", node.Box, child.Box)
+			}
+		}
+		return check(node.Left) + check(node.Right)
+	}
+	
+	if leaves := check(bvh); leaves != len(objects) {
+		t.Errorf("BVH leaves: got %d, want %d", leaves, len(objects))
+	}
+}
+
+func BenchmarkWorldHit(b *testing.B) {
+	rng := math.NewFastRandom(1)
+	world := NewWorld(randomScene(rng, 10000))
+	ray := geometry.NewRay(math.Vec3{X: -30, Y: 0.5, Z: 0.5}, math.Vec3{X: 1, Y: 0.01, Z: 0.02}.Normalize())
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		world.Hit(ray, 0.001, 1e9)
+	}
+}
@@ -0,0 +1,155 @@
+package output
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	stdmath "math"
+	"os"
+	"sort"
+)
+
+const (
+	exrMagic       = 20000630
+	exrVersion     = 2
+	exrPixelFloat  = 2
+	exrCompression = 0
+	exrLineOrder   = 0
+)
+
+// EXRChannel is one named channel of an OpenEXR image, a value per pixel row
+// by row from the top left. Layered channels use dotted names such as
+// "normal.X".
+type EXRChannel struct {
+	Name   string
+	Values []float32
+}
+
+// SaveEXR writes the framebuffer as an OpenEXR image with R, G, B and A
+// channels, and each layer's channels prefixed with the layer name.
+func SaveEXR(f *Framebuffer, filename string) error {
+	channels := []EXRChannel{
+		{Name: "R", Values: f.channel(0)},
+		{Name: "G", Values: f.channel(1)},
+		{Name: "B", Values: f.channel(2)},
+		{Name: "A", Values: f.channel(3)},
+	}
+	for _, layer := range f.Layers {
+		for c, name := range layer.Channels {
+			channels = append(channels, EXRChannel{Name: layer.Name + "." + name, Values: layer.channel(c)})
+		}
+	}
+	return saveEXRChannels(filename, f.Width, f.Height, channels)
+}
+
+func saveEXRChannels(filename string, width, height int, channels []EXRChannel) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	writer := bufio.NewWriter(file)
+	if err := WriteEXR(writer, width, height, channels); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// WriteEXR encodes a single-part scanline OpenEXR image with uncompressed
+// 32-bit float channels, one scanline per chunk.
+func WriteEXR(w io.Writer, width, height int, channels []EXRChannel) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid EXR size %dx%d", width, height)
+	}
+	
+	sorted := append([]EXRChannel(nil), channels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	for _, channel := range sorted {
+		if len(channel.Values) != width*height {
+			return fmt.Errorf("EXR channel %q has %d values, want %d", channel.Name, len(channel.Values), width*height)
+		}
+	}
+	
+	header := exrHeader(width, height, sorted)
+	
+	lineSize := width * 4 * len(sorted)
+	chunkSize := 8 + lineSize
+	firstChunk := 8 + len(header) + 8*height
+	
+	buf := make([]byte, 0, firstChunk)
+	buf = binary.LittleEndian.AppendUint32(buf, exrMagic)
+	buf = binary.LittleEndian.AppendUint32(buf, exrVersion)
+	buf = append(buf, header...)
+	for y := 0; y < height; y++ {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(firstChunk+y*chunkSize))
+	}
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+	
+	line := make([]byte, 0, chunkSize)
+	for y := 0; y < height; y++ {
+		line = line[:0]
+		line = binary.LittleEndian.AppendUint32(line, uint32(y))
+		line = binary.LittleEndian.AppendUint32(line, uint32(lineSize))
+		for _, channel := range sorted {
+			for _, value := range channel.Values[y*width : (y+1)*width] {
+				line = binary.LittleEndian.AppendUint32(line, stdmath.Float32bits(value))
+			}
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	
+	return nil
+}
+
+func exrHeader(width, height int, channels []EXRChannel) []byte {
+	var header []byte
+	attribute := func(name, kind string, value []byte) {
+		header = append(header, name...)
+		header = append(header, 0)
+		header = append(header, kind...)
+		header = append(header, 0)
+		header = binary.LittleEndian.AppendUint32(header, uint32(len(value)))
+		header = append(header, value...)
+	}
+	
+	var chlist []byte
+	for _, channel := range channels {
+		chlist = append(chlist, channel.Name...)
+		chlist = append(chlist, 0)
+		chlist = binary.LittleEndian.AppendUint32(chlist, exrPixelFloat)
+		chlist = append(chlist, 0, 0, 0, 0)
+		chlist = binary.LittleEndian.AppendUint32(chlist, 1)
+		chlist = binary.LittleEndian.AppendUint32(chlist, 1)
+	}
+	chlist = append(chlist, 0)
+	
+	var window []byte
+	for _, v := range []int{0, 0, width - 1, height - 1} {
+		window = binary.LittleEndian.AppendUint32(window, uint32(v))
+	}
+	
+	float := func(values ...float32) []byte {
+		var b []byte
+		for _, v := range values {
+			b = binary.LittleEndian.AppendUint32(b, stdmath.Float32bits(v))
+		}
+		return b
+	}
+	
+	attribute("channels", "chlist", chlist)
+	attribute("compression", "compression", []byte{exrCompression})
+	attribute("dataWindow", "box2i", window)
+	attribute("displayWindow", "box2i", window)
+	attribute("lineOrder", "lineOrder", []byte{exrLineOrder})
+	attribute("pixelAspectRatio", "float", float(1))
+	attribute("screenWindowCenter", "v2f", float(0, 0))
+	attribute("screenWindowWidth", "float", float(1))
+	
+	return append(header, 0)
+}
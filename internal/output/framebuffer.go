@@ -0,0 +1,135 @@
+package output
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"raytraceGo/internal/math"
+	"raytraceGo/internal/tonemap"
+	"strings"
+)
+
+// Framebuffer holds linear, unclamped RGBA radiance as float32, row by row
+// from the top left. Alpha is the fraction of the pixel the camera covers.
+// Layers are the AOVs rendered with it.
+type Framebuffer struct {
+	Width, Height int
+	Pix           []float32
+	Layers        []*Layer
+}
+
+func NewFramebuffer(width, height int) *Framebuffer {
+	return &Framebuffer{
+		Width:  width,
+		Height: height,
+		Pix:    make([]float32, width*height*4),
+	}
+}
+
+func (f *Framebuffer) Set(x, y int, color math.Vec3, alpha float64) {
+	i := (y*f.Width + x) * 4
+	f.Pix[i] = float32(color.X)
+	f.Pix[i+1] = float32(color.Y)
+	f.Pix[i+2] = float32(color.Z)
+	f.Pix[i+3] = float32(alpha)
+}
+
+func (f *Framebuffer) At(x, y int) math.Vec3 {
+	i := (y*f.Width + x) * 4
+	return math.Vec3{X: float64(f.Pix[i]), Y: float64(f.Pix[i+1]), Z: float64(f.Pix[i+2])}
+}
+
+func (f *Framebuffer) Alpha(x, y int) float64 {
+	return float64(f.Pix[(y*f.Width+x)*4+3])
+}
+
+// Clone returns a copy of the framebuffer's pixels that shares its layers.
+func (f *Framebuffer) Clone() *Framebuffer {
+	clone := *f
+	clone.Pix = append([]float32(nil), f.Pix...)
+	clone.Layers = append([]*Layer(nil), f.Layers...)
+	return &clone
+}
+
+// ToneMap converts the framebuffer to an 8-bit sRGB image.
+func ToneMap(f *Framebuffer, mapper *tonemap.Mapper) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			img.SetRGBA(x, y, mapper.RGBA(f.At(x, y)))
+		}
+	}
+	return img
+}
+
+// channel returns one of the four channels as its own slice.
+func (f *Framebuffer) channel(c int) []float32 {
+	values := make([]float32, f.Width*f.Height)
+	for i := range values {
+		values[i] = f.Pix[i*4+c]
+	}
+	return values
+}
+
+// IsHDRFile reports whether filename has the extension of a floating-point
+// format that SaveFramebuffer writes.
+func IsHDRFile(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".exr", ".hdr", ".pfm":
+		return true
+	}
+	return false
+}
+
+// SaveFramebuffer writes the framebuffer in the format given by the file
+// extension: OpenEXR (.exr), Radiance RGBE (.hdr) or Portable FloatMap (.pfm).
+// EXR files hold the layers too; the other formats get a file per layer.
+func SaveFramebuffer(f *Framebuffer, filename string) error {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".exr":
+		return SaveEXR(f, filename)
+	case ".hdr":
+		if err := SaveRadianceHDR(f, filename); err != nil {
+			return err
+		}
+	case ".pfm":
+		if err := SavePFM(f, filename); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported HDR format %q, expected .exr, .hdr or .pfm", filepath.Ext(filename))
+	}
+	return SaveLayers(f, filename)
+}
+
+// SaveLayers writes each layer of the framebuffer to its LayerFilename.
+func SaveLayers(f *Framebuffer, filename string) error {
+	for _, layer := range f.Layers {
+		if err := SaveLayer(layer, LayerFilename(filename, layer.Name)); err != nil {
+			return fmt.Errorf("saving %s layer: %v", layer.Name, err)
+		}
+	}
+	return nil
+}
+
+// SaveImage writes an 8-bit image as PPM for the .ppm extension and PNG
+// otherwise, creating the file's directory if needed.
+func SaveImage(img *image.RGBA, filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	
+	if strings.EqualFold(filepath.Ext(filename), ".ppm") {
+		return SavePPM(img, filename)
+	}
+	
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	return png.Encode(file, img)
+}
@@ -0,0 +1,144 @@
+package output
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	stdmath "math"
+	"os"
+)
+
+// SavePFM writes the framebuffer's RGB as a little-endian Portable FloatMap.
+// PFM stores rows from the bottom up.
+func SavePFM(f *Framebuffer, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	writer := bufio.NewWriter(file)
+	fmt.Fprintf(writer, "PF\n%d %d\n-1.0\n", f.Width, f.Height)
+	
+	row := make([]byte, 0, f.Width*12)
+	for y := f.Height - 1; y >= 0; y-- {
+		row = row[:0]
+		for x := 0; x < f.Width; x++ {
+			i := (y*f.Width + x) * 4
+			for c := 0; c < 3; c++ {
+				row = binary.LittleEndian.AppendUint32(row, stdmath.Float32bits(f.Pix[i+c]))
+			}
+		}
+		if _, err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	
+	return writer.Flush()
+}
+
+// SaveRadianceHDR writes the framebuffer's RGB as a Radiance RGBE image with
+// run-length encoded scanlines.
+func SaveRadianceHDR(f *Framebuffer, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	writer := bufio.NewWriter(file)
+	fmt.Fprintf(writer, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", f.Height, f.Width)
+	
+	scanline := make([][4]byte, f.Width)
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			i := (y*f.Width + x) * 4
+			scanline[x] = toRGBE(f.Pix[i], f.Pix[i+1], f.Pix[i+2])
+		}
+		if _, err := writer.Write(encodeRGBEScanline(scanline)); err != nil {
+			return err
+		}
+	}
+	
+	return writer.Flush()
+}
+
+// toRGBE packs a color into 8-bit mantissas sharing the exponent of the
+// largest component. Negative and non-finite values are clamped to zero.
+func toRGBE(r, g, b float32) [4]byte {
+	clean := func(v float32) float64 {
+		if v <= 0 || stdmath.IsNaN(float64(v)) || stdmath.IsInf(float64(v), 0) {
+			return 0
+		}
+		return float64(v)
+	}
+	red, green, blue := clean(r), clean(g), clean(b)
+	
+	largest := stdmath.Max(red, stdmath.Max(green, blue))
+	if largest < 1e-32 {
+		return [4]byte{}
+	}
+	
+	mantissa, exponent := stdmath.Frexp(largest)
+	scale := mantissa * 256 / largest
+	return [4]byte{byte(red * scale), byte(green * scale), byte(blue * scale), byte(exponent + 128)}
+}
+
+// encodeRGBEScanline uses the adaptive run-length encoding of Radiance: a
+// 2, 2, width marker, then each of the four components run-length encoded
+// separately. Scanlines outside the widths that encoding allows are written
+// flat.
+func encodeRGBEScanline(scanline [][4]byte) []byte {
+	width := len(scanline)
+	if width < 8 || width > 0x7fff {
+		out := make([]byte, 0, width*4)
+		for _, pixel := range scanline {
+			out = append(out, pixel[:]...)
+		}
+		return out
+	}
+	
+	out := []byte{2, 2, byte(width >> 8), byte(width & 0xff)}
+	component := make([]byte, width)
+	for c := 0; c < 4; c++ {
+		for x := range scanline {
+			component[x] = scanline[x][c]
+		}
+		out = appendRLE(out, component)
+	}
+	return out
+}
+
+// appendRLE encodes runs of at least minRun equal bytes as a count above 128
+// and the byte, and everything else as literal dumps of up to 128 bytes.
+func appendRLE(out, data []byte) []byte {
+	const minRun = 4
+	
+	for i := 0; i < len(data); {
+		run := 1
+		for i+run < len(data) && run < 127 && data[i+run] == data[i] {
+			run++
+		}
+		if run >= minRun {
+			out = append(out, byte(128+run), data[i])
+			i += run
+			continue
+		}
+		
+		start := i
+		for i < len(data) && i-start < 128 {
+			next := 1
+			for i+next < len(data) && next < minRun && data[i+next] == data[i] {
+				next++
+			}
+			if next >= minRun {
+				break
+			}
+			i++
+		}
+		out = append(out, byte(i-start))
+		out = append(out, data[start:i]...)
+	}
+	
+	return out
+}
@@ -0,0 +1,217 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	stdmath "math"
+	"os"
+	"path/filepath"
+	"raytraceGo/internal/math"
+	"testing"
+)
+
+func testFramebuffer() *Framebuffer {
+	f := NewFramebuffer(13, 5)
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			color := math.Vec3{X: float64(x) * 0.75, Y: float64(y) * 3.5, Z: 0.001 * float64(x+y)}
+			if x > 8 {
+				color = math.Vec3{X: 40, Y: 40, Z: 40}
+			}
+			f.Set(x, y, color, 1)
+		}
+	}
+	return f
+}
+
+// readEXR decodes the uncompressed float images WriteEXR produces.
+func readEXR(t *testing.T, data []byte) (int, int, map[string][]float32) {
+	t.Helper()
+	
+	if binary.LittleEndian.Uint32(data) != exrMagic || binary.LittleEndian.Uint32(data[4:]) != exrVersion {
+		t.Fatalf("EXR magic or version failed: got % x", data[:8])
+	}
+	
+	pos := 8
+	readString := func() string {
+		end := bytes.IndexByte(data[pos:], 0)
+		s := string(data[pos : pos+end])
+		pos += end + 1
+		return s
+	}
+	
+	var names []string
+	var width, height int
+	for {
+		name := readString()
+		if name == "" {
+			break
+		}
+		readString()
+		size := int(binary.LittleEndian.Uint32(data[pos:]))
+		value := data[pos+4 : pos+4+size]
+		pos += 4 + size
+		
+		switch name {
+		case "channels":
+			for len(value) > 1 {
+				end := bytes.IndexByte(value, 0)
+				names = append(names, string(value[:end]))
+				if pixelType := binary.LittleEndian.Uint32(value[end+1:]); pixelType != exrPixelFloat {
+					t.Fatalf("EXR pixel type failed: got %d, want %d", pixelType, exrPixelFloat)
+				}
+				value = value[end+17:]
+			}
+		case "dataWindow":
+			width = int(binary.LittleEndian.Uint32(value[8:])) + 1
+			height = int(binary.LittleEndian.Uint32(value[12:])) + 1
+		}
+	}
+	
+	channels := make(map[string][]float32)
+	for y := 0; y < height; y++ {
+		offset := int(binary.LittleEndian.Uint64(data[pos+8*y:]))
+		if line := int(binary.LittleEndian.Uint32(data[offset:])); line != y {
+			t.Fatalf("EXR chunk scanline failed: got %d, want %d", line, y)
+		}
+		offset += 8
+		for _, name := range names {
+			for x := 0; x < width; x++ {
+				channels[name] = append(channels[name], stdmath.Float32frombits(binary.LittleEndian.Uint32(data[offset:])))
+				offset += 4
+			}
+		}
+	}
+	
+	return width, height, channels
+}
+
+func TestSaveEXR(t *testing.T) {
+	f := testFramebuffer()
+	depth, err := NewAOVLayer(AOVDepth, f.Width, f.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	depth.Set(4, 2, math.Vec3{X: 12.5})
+	f.Layers = append(f.Layers, depth)
+	
+	path := filepath.Join(t.TempDir(), "image.exr")
+	if err = SaveFramebuffer(f, path); err != nil {
+		t.Fatalf("SaveFramebuffer failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	width, height, channels := readEXR(t, data)
+	if width != f.Width || height != f.Height {
+		t.Fatalf("EXR size failed: got %dx%d, want %dx%d", width, height, f.Width, f.Height)
+	}
+	for c, name := range []string{"R", "G", "B", "A"} {
+		for i, value := range channels[name] {
+			if want := f.Pix[i*4+c]; value != want {
+				t.Fatalf("EXR channel %s pixel %d failed: got %v, want %v", name, i, value, want)
+			}
+		}
+	}
+	if got := channels["depth.Z"][2*f.Width+4]; got != 12.5 {
+		t.Errorf("EXR depth.Z layer failed: got %v, want 12.5", got)
+	}
+}
+
+func TestSavePFM(t *testing.T) {
+	f := testFramebuffer()
+	path := filepath.Join(t.TempDir(), "image.pfm")
+	if err := SaveFramebuffer(f, path); err != nil {
+		t.Fatalf("SaveFramebuffer failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	header := "PF\n13 5\n-1.0\n"
+	if !bytes.HasPrefix(data, []byte(header)) {
+		t.Fatalf("PFM header failed: got %q, want %q", data[:len(header)], header)
+	}
+	pixels := data[len(header):]
+	if len(pixels) != f.Width*f.Height*12 {
+		t.Fatalf("PFM size failed: got %d bytes, want %d", len(pixels), f.Width*f.Height*12)
+	}
+	
+	// The first stored row is the bottom one.
+	x, y := 3, f.Height-1
+	got := stdmath.Float32frombits(binary.LittleEndian.Uint32(pixels[x*12+4:]))
+	if want := float32(f.At(x, y).Y); got != want {
+		t.Errorf("PFM pixel failed: got %v, want %v", got, want)
+	}
+}
+
+// decodeRGBEScanline reverses encodeRGBEScanline.
+func decodeRGBEScanline(t *testing.T, data []byte, width int) ([][4]byte, []byte) {
+	t.Helper()
+	
+	scanline := make([][4]byte, width)
+	if data[0] != 2 || data[1] != 2 || int(data[2])<<8|int(data[3]) != width {
+		t.Fatalf("RGBE scanline marker failed: got % x", data[:4])
+	}
+	data = data[4:]
+	for c := 0; c < 4; c++ {
+		for x := 0; x < width; {
+			count := int(data[0])
+			if count > 128 {
+				for i := 0; i < count-128; i++ {
+					scanline[x+i][c] = data[1]
+				}
+				x += count - 128
+				data = data[2:]
+			} else {
+				for i := 0; i < count; i++ {
+					scanline[x+i][c] = data[1+i]
+				}
+				x += count
+				data = data[1+count:]
+			}
+		}
+	}
+	return scanline, data
+}
+
+func TestSaveRadianceHDR(t *testing.T) {
+	f := testFramebuffer()
+	path := filepath.Join(t.TempDir(), "image.hdr")
+	if err := SaveFramebuffer(f, path); err != nil {
+		t.Fatalf("SaveFramebuffer failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	header := "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 5 +X 13\n"
+	if !bytes.HasPrefix(data, []byte(header)) {
+		t.Fatalf("HDR header failed: got %q, want %q", data[:len(header)], header)
+	}
+	data = data[len(header):]
+	
+	for y := 0; y < f.Height; y++ {
+		var scanline [][4]byte
+		scanline, data = decodeRGBEScanline(t, data, f.Width)
+		for x, rgbe := range scanline {
+			want := f.At(x, y)
+			scale := 0.0
+			if rgbe[3] != 0 {
+				scale = stdmath.Ldexp(1, int(rgbe[3])-(128+8))
+			}
+			got := math.Vec3{X: (float64(rgbe[0]) + 0.5) * scale, Y: (float64(rgbe[1]) + 0.5) * scale, Z: (float64(rgbe[2]) + 0.5) * scale}
+			tolerance := 0.01*stdmath.Max(want.X, stdmath.Max(want.Y, want.Z)) + 1e-6
+			if stdmath.Abs(got.X-want.X) > tolerance || stdmath.Abs(got.Y-want.Y) > tolerance || stdmath.Abs(got.Z-want.Z) > tolerance {
+				t.Fatalf("HDR pixel (%d, %d) failed: got %v, want %v", x, y, got, want)
+			}
+		}
+	}
+	if len(data) != 0 {
+		t.Errorf("HDR trailing data failed: got %d bytes, want 0", len(data))
+	}
+}
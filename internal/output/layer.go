@@ -0,0 +1,201 @@
+package output
+
+import (
+	"fmt"
+	"image"
+	stdmath "math"
+	"path/filepath"
+	"raytraceGo/internal/math"
+	"raytraceGo/internal/tonemap"
+	"sort"
+	"strings"
+)
+
+// Arbitrary output variables: auxiliary passes rendered next to the beauty
+// image. Pixels where the camera ray hits nothing are 0 in every pass.
+const (
+	AOVDepth      = "depth"
+	AOVNormal     = "normal"
+	AOVAlbedo     = "albedo"
+	AOVPosition   = "position"
+	AOVMaterialID = "materialID"
+	AOVObjectID   = "objectID"
+)
+
+var AOVNames = []string{AOVDepth, AOVNormal, AOVAlbedo, AOVPosition, AOVMaterialID, AOVObjectID}
+
+var aovChannels = map[string][]string{
+	AOVDepth:      {"Z"},
+	AOVNormal:     {"X", "Y", "Z"},
+	AOVAlbedo:     {"R", "G", "B"},
+	AOVPosition:   {"X", "Y", "Z"},
+	AOVMaterialID: {"id"},
+	AOVObjectID:   {"id"},
+}
+
+// Layer is an image of one or more named float channels, such as an AOV.
+type Layer struct {
+	Name          string
+	Channels      []string
+	Width, Height int
+	Pix           []float32
+}
+
+// NewAOVLayer returns an empty layer with the channels of the named AOV.
+func NewAOVLayer(name string, width, height int) (*Layer, error) {
+	channels, ok := aovChannels[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown AOV %q, expected one of %s", name, strings.Join(AOVNames, ", "))
+	}
+	return &Layer{
+		Name:     name,
+		Channels: channels,
+		Width:    width,
+		Height:   height,
+		Pix:      make([]float32, width*height*len(channels)),
+	}, nil
+}
+
+// Set stores the first len(Channels) components of value.
+func (l *Layer) Set(x, y int, value math.Vec3) {
+	i := (y*l.Width + x) * len(l.Channels)
+	components := []float64{value.X, value.Y, value.Z}
+	for c := range l.Channels {
+		l.Pix[i+c] = float32(components[c])
+	}
+}
+
+func (l *Layer) At(x, y int) math.Vec3 {
+	i := (y*l.Width + x) * len(l.Channels)
+	if len(l.Channels) < 3 {
+		v := float64(l.Pix[i])
+		return math.Vec3{X: v, Y: v, Z: v}
+	}
+	return math.Vec3{X: float64(l.Pix[i]), Y: float64(l.Pix[i+1]), Z: float64(l.Pix[i+2])}
+}
+
+func (l *Layer) channel(c int) []float32 {
+	values := make([]float32, l.Width*l.Height)
+	for i := range values {
+		values[i] = l.Pix[i*len(l.Channels)+c]
+	}
+	return values
+}
+
+// LayerFilename names the file a layer is saved to next to the beauty image,
+// such as render.depth.png for render.png.
+func LayerFilename(filename, layer string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "." + layer + ext
+}
+
+// SaveLayer writes a layer in the format given by the file extension.
+// Floating-point formats keep the values; 8-bit formats get a visualization.
+func SaveLayer(l *Layer, filename string) error {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".exr":
+		channels := make([]EXRChannel, len(l.Channels))
+		for c, name := range l.Channels {
+			channels[c] = EXRChannel{Name: name, Values: l.channel(c)}
+		}
+		return saveEXRChannels(filename, l.Width, l.Height, channels)
+	case ".hdr", ".pfm":
+		f := NewFramebuffer(l.Width, l.Height)
+		for y := 0; y < l.Height; y++ {
+			for x := 0; x < l.Width; x++ {
+				f.Set(x, y, l.At(x, y), 1)
+			}
+		}
+		return SaveFramebuffer(f, filename)
+	default:
+		return SaveImage(l.Visualize(), filename)
+	}
+}
+
+// Visualize maps the layer to a displayable image: depth as inverse depth,
+// white at the nearest point and fading towards the horizon, position
+// normalized to its range, normals to 0.5 + 0.5n, albedo sRGB encoded, and IDs
+// to distinct colors.
+func (l *Layer) Visualize() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, l.Width, l.Height))
+	
+	low, high := l.valueRange()
+	for y := 0; y < l.Height; y++ {
+		for x := 0; x < l.Width; x++ {
+			value := l.At(x, y)
+			
+			var c math.Vec3
+			switch l.Name {
+			case AOVNormal:
+				if !value.NearZero() {
+					c = value.MulScalar(0.5).Add(math.Vec3{X: 0.5, Y: 0.5, Z: 0.5})
+				}
+			case AOVAlbedo:
+				c = tonemap.EncodeSRGB(value)
+			case AOVMaterialID, AOVObjectID:
+				c = idColor(value.X)
+			case AOVDepth:
+				if value.X > 0 {
+					d := math.FastClamp(low/value.X, 0, 1)
+					c = math.Vec3{X: d, Y: d, Z: d}
+				}
+			default:
+				if !value.NearZero() && high > low {
+					c = value.Sub(math.Vec3{X: low, Y: low, Z: low}).DivScalar(high - low)
+				}
+			}
+			
+			img.SetRGBA(x, y, tonemap.Quantize(c))
+		}
+	}
+	
+	return img
+}
+
+// valueRange spans the 2nd to 98th percentile of the values over all
+// channels, ignoring the zeros of background pixels.
+func (l *Layer) valueRange() (float64, float64) {
+	var values []float64
+	for _, v := range l.Pix {
+		if v != 0 && !stdmath.IsInf(float64(v), 0) && !stdmath.IsNaN(float64(v)) {
+			values = append(values, float64(v))
+		}
+	}
+	if len(values) == 0 {
+		return 0, 0
+	}
+	
+	sort.Float64s(values)
+	return values[len(values)*2/100], values[(len(values)-1)*98/100]
+}
+
+// idColor spreads consecutive IDs around the hue circle by the golden angle,
+// so that neighbouring IDs get clearly different colors.
+func idColor(id float64) math.Vec3 {
+	if id == 0 {
+		return math.Vec3{}
+	}
+	
+	const saturation, value = 0.65, 0.9
+	hue := stdmath.Mod(id*0.618033988749895, 1) * 6
+	sector := int(hue)
+	f := hue - float64(sector)
+	p := value * (1 - saturation)
+	q := value * (1 - saturation*f)
+	t := value * (1 - saturation*(1-f))
+	
+	switch sector {
+	case 0:
+		return math.Vec3{X: value, Y: t, Z: p}
+	case 1:
+		return math.Vec3{X: q, Y: value, Z: p}
+	case 2:
+		return math.Vec3{X: p, Y: value, Z: t}
+	case 3:
+		return math.Vec3{X: p, Y: q, Z: value}
+	case 4:
+		return math.Vec3{X: t, Y: p, Z: value}
+	default:
+		return math.Vec3{X: value, Y: p, Z: q}
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	stdmath "math"
 	"os"
 	"raytraceGo/internal/math"
+	"raytraceGo/internal/tonemap"
 )
 
 func SavePPM(img *image.RGBA, filename string) error {
@@ -23,7 +25,7 @@ func SavePPM(img *image.RGBA, filename string) error {
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
 			r, g, b, _ := img.At(x, y).RGBA()
-			fmt.Fprintf(file, "%d %d %d ", r, g, b)
+			fmt.Fprintf(file, "%d %d %d ", r>>8, g>>8, b>>8)
 		}
 		fmt.Fprintln(file)
 	}
@@ -116,87 +118,39 @@ func SavePPMFromRGBA(pixels [][]color.RGBA, filename string) error {
 	return nil
 }
 
+// SavePPMFromVec3WithGamma writes linear colors clamped to [0, 1] and
+// encoded with a power-law gamma.
 func SavePPMFromVec3WithGamma(pixels [][]math.Vec3, filename string, gamma float64) error {
-	file, err := os.Create(filename)
+	return savePPMFromVec3Mapped(pixels, filename, func(c math.Vec3) color.RGBA {
+		return tonemap.Quantize(tonemap.EncodeGamma(c, gamma))
+	})
+}
+
+// SavePPMFromVec3WithToneMapping writes linear colors through the
+// exponential tone curve after scaling them by exposure.
+func SavePPMFromVec3WithToneMapping(pixels [][]math.Vec3, filename string, exposure float64) error {
+	mapper, err := tonemap.New(tonemap.Settings{Operator: tonemap.Exponential, Exposure: stdmath.Log2(exposure)})
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	
-	height := len(pixels)
-	if height == 0 {
-		return fmt.Errorf("empty pixel data")
-	}
-	width := len(pixels[0])
-	
-	fmt.Fprintf(file, "P3\n%d %d\n255\n", width, height)
-	
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			color := pixels[y][x]
-			
-			r := math.FastPow(color.X, 1.0/gamma)
-			g := math.FastPow(color.Y, 1.0/gamma)
-			b := math.FastPow(color.Z, 1.0/gamma)
-			
-			r = math.FastClamp(r, 0.0, 1.0)
-			g = math.FastClamp(g, 0.0, 1.0)
-			b = math.FastClamp(b, 0.0, 1.0)
-			
-			ri := int(r * 255)
-			gi := int(g * 255)
-			bi := int(b * 255)
-			
-			fmt.Fprintf(file, "%d %d %d ", ri, gi, bi)
-		}
-		fmt.Fprintln(file)
-	}
-	
-	return nil
+	return savePPMFromVec3Mapped(pixels, filename, mapper.RGBA)
 }
 
-func SavePPMFromVec3WithToneMapping(pixels [][]math.Vec3, filename string, exposure float64) error {
-	file, err := os.Create(filename)
+func SavePPMFromVec3WithReinhardToneMapping(pixels [][]math.Vec3, filename string) error {
+	mapper, err := tonemap.New(tonemap.Settings{Operator: tonemap.Reinhard})
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	
-	height := len(pixels)
-	if height == 0 {
-		return fmt.Errorf("empty pixel data")
-	}
-	width := len(pixels[0])
-	
-	fmt.Fprintf(file, "P3\n%d %d\n255\n", width, height)
-	
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			color := pixels[y][x]
-			
-			color = color.MulScalar(exposure)
-			
-			r := 1.0 - math.FastExp(-color.X)
-			g := 1.0 - math.FastExp(-color.Y)
-			b := 1.0 - math.FastExp(-color.Z)
-			
-			r = math.FastClamp(r, 0.0, 1.0)
-			g = math.FastClamp(g, 0.0, 1.0)
-			b = math.FastClamp(b, 0.0, 1.0)
-			
-			ri := int(r * 255)
-			gi := int(g * 255)
-			bi := int(b * 255)
-			
-			fmt.Fprintf(file, "%d %d %d ", ri, gi, bi)
-		}
-		fmt.Fprintln(file)
-	}
-	
-	return nil
+	return savePPMFromVec3Mapped(pixels, filename, mapper.RGBA)
 }
 
-func SavePPMFromVec3WithReinhardToneMapping(pixels [][]math.Vec3, filename string) error {
+// SavePPMFromVec3WithDisplayTransform writes linear colors through a
+// configured tone mapping and sRGB display transform.
+func SavePPMFromVec3WithDisplayTransform(pixels [][]math.Vec3, filename string, mapper *tonemap.Mapper) error {
+	return savePPMFromVec3Mapped(pixels, filename, mapper.RGBA)
+}
+
+func savePPMFromVec3Mapped(pixels [][]math.Vec3, filename string, mapColor func(math.Vec3) color.RGBA) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -213,24 +167,11 @@ func SavePPMFromVec3WithReinhardToneMapping(pixels [][]math.Vec3, filename strin
 	
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
-			color := pixels[y][x]
-			
-			r := color.X / (1.0 + color.X)
-			g := color.Y / (1.0 + color.Y)
-			b := color.Z / (1.0 + color.Z)
-			
-			r = math.FastClamp(r, 0.0, 1.0)
-			g = math.FastClamp(g, 0.0, 1.0)
-			b = math.FastClamp(b, 0.0, 1.0)
-			
-			ri := int(r * 255)
-			gi := int(g * 255)
-			bi := int(b * 255)
-			
-			fmt.Fprintf(file, "%d %d %d ", ri, gi, bi)
+			pixel := mapColor(pixels[y][x])
+			fmt.Fprintf(file, "%d %d %d ", pixel.R, pixel.G, pixel.B)
 		}
 		fmt.Fprintln(file)
 	}
 	
 	return nil
-} 
\ No newline at end of file
+}
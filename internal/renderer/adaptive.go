@@ -0,0 +1,239 @@
+package renderer
+
+import (
+	"context"
+	"image"
+	"image/color"
+	stdmath "math"
+	"raytraceGo/internal/atmosphere"
+	"raytraceGo/internal/geometry"
+	"raytraceGo/internal/lighting"
+	"raytraceGo/internal/math"
+	"sync"
+)
+
+const (
+	// Samples every unconverged pixel receives per pass after the first.
+	adaptiveBatchSize = 16
+	// z-score of the 95% confidence interval used by the convergence test.
+	confidenceZ = 1.96
+)
+
+// pixelEstimate accumulates the samples of one pixel. The luminance sums give
+// the sample variance for the convergence test.
+type pixelEstimate struct {
+	sum           math.Vec3
+	luminance     float64
+	luminanceSq   float64
+	samples       int
+	covered       int
+	relativeError float64
+	converged     bool
+}
+
+// add records a sample; uncovered samples, where the camera has no ray,
+// count as black.
+func (p *pixelEstimate) add(color math.Vec3, covered bool) {
+	if covered {
+		p.covered++
+	}
+	y := color.Luminance()
+	p.sum = p.sum.Add(color)
+	p.luminance += y
+	p.luminanceSq += y * y
+	p.samples++
+}
+
+func (p *pixelEstimate) mean() math.Vec3 {
+	if p.samples == 0 {
+		return math.Vec3{}
+	}
+	return p.sum.DivScalar(float64(p.samples))
+}
+
+func (p *pixelEstimate) alpha() float64 {
+	if p.samples == 0 {
+		return 0
+	}
+	return float64(p.covered) / float64(p.samples)
+}
+
+// errorEstimate is the half-width of the 95% confidence interval of the mean
+// luminance.
+func (p *pixelEstimate) errorEstimate() float64 {
+	if p.samples < 2 {
+		return stdmath.Inf(1)
+	}
+	n := float64(p.samples)
+	mean := p.luminance / n
+	variance := stdmath.Max(0, (p.luminanceSq-n*mean*mean)/(n-1))
+	return confidenceZ * stdmath.Sqrt(variance/n)
+}
+
+// updateError sets relativeError to the error over threshold times the square
+// root of the mean luminance, so that values up to 1 are converged. Scaling by
+// the square root tolerates more absolute noise in bright pixels and less in
+// dark ones, roughly following how visible the noise is after tone mapping.
+func (p *pixelEstimate) updateError(threshold float64) {
+	tolerance := threshold * stdmath.Sqrt(stdmath.Max(0, p.luminance/float64(p.samples)))
+	errorEstimate := p.errorEstimate()
+	switch {
+	case errorEstimate == 0:
+		p.relativeError = 0
+	case tolerance == 0:
+		p.relativeError = stdmath.Inf(1)
+	default:
+		p.relativeError = errorEstimate / tolerance
+	}
+}
+
+// updateConvergence marks pixels converged once the largest relative error
+// in their 3x3 neighbourhood is at most 1. Variance estimated from a few
+// samples is itself noisy; taking the neighbourhood into account keeps
+// pixels whose first samples happened to agree from stopping early. Pixels
+// at maxSamples are not marked, only no longer counted as active, so that a
+// resumed render with a higher maximum continues them.
+func updateConvergence(estimates []pixelEstimate, width, height, maxSamples int) (active int) {
+	converged := make([]bool, len(estimates))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			if estimates[i].converged {
+				converged[i] = true
+				continue
+			}
+			
+			worst := 0.0
+			for ny := max(y-1, 0); ny <= min(y+1, height-1); ny++ {
+				for nx := max(x-1, 0); nx <= min(x+1, width-1); nx++ {
+					worst = stdmath.Max(worst, estimates[ny*width+nx].relativeError)
+				}
+			}
+			converged[i] = worst <= 1
+		}
+	}
+	
+	for i := range estimates {
+		estimates[i].converged = converged[i]
+		if !converged[i] && estimates[i].samples < maxSamples {
+			active++
+		}
+	}
+	return active
+}
+
+// renderAdaptive renders in passes. The first pass gives every pixel
+// minSamples samples; each later pass adds a batch to the pixels that have
+// not converged, until all have or they reach maxSamples. A pixel's sample
+// indices continue across passes, so its estimate after a pass does not
+// depend on the order the workers ran in, and convergence, which looks at the
+// neighbouring pixels too, is only decided between passes from those
+// estimates. The image is therefore as deterministic as a fixed-count render.
+// Progress is reported per pass, each counting the image's pixels from zero.
+//
+// Rendering starts with the pass whose first sample is nextSample. Pixels
+// that already have that pass's samples, from a render cancelled halfway
+// through it, are skipped, which lets a resumed render finish the pass.
+// Checkpoints are taken between passes and when cancelled, once the workers
+// have stopped.
+func (r *ParallelRenderer) renderAdaptive(ctx context.Context, estimates []pixelEstimate, nextSample, width, height int, camera Camera, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, progress *progressTracker, checkpoint *checkpointer) {
+	firstSample := nextSample
+	for pass := 0; firstSample < r.maxSamples; pass++ {
+		count := adaptiveBatchSize
+		if firstSample == 0 {
+			count = r.minSamples
+		}
+		if firstSample+count > r.maxSamples {
+			count = r.maxSamples - firstSample
+		}
+		
+		tasks := r.createRenderTasks(ctx, width, height, camera)
+		progress.startPass(pass)
+		
+		var wg sync.WaitGroup
+		for i := 0; i < r.numWorkers; i++ {
+			wg.Add(1)
+			go r.adaptiveWorker(ctx, &wg, tasks, estimates, firstSample, count, world, lights, sky, progress)
+		}
+		wg.Wait()
+		
+		if ctx.Err() != nil {
+			break
+		}
+		firstSample += count
+		
+		if updateConvergence(estimates, width, height, r.maxSamples) == 0 {
+			break
+		}
+		checkpoint.saveEvery(estimates, firstSample)
+	}
+	checkpoint.save(estimates, firstSample)
+	
+	totalSamples := 0
+	for i := range estimates {
+		totalSamples += estimates[i].samples
+	}
+	
+	r.heatmap = sampleHeatmap(estimates, width, height, r.minSamples, r.maxSamples)
+	r.benchmarkData.AverageSamples = float64(totalSamples) / float64(len(estimates))
+}
+
+// adaptiveWorker renders one pass. Tiles cover disjoint pixels, so workers
+// update their estimates without locking; convergence, which reads
+// neighbouring pixels, is decided between passes.
+func (r *ParallelRenderer) adaptiveWorker(ctx context.Context, wg *sync.WaitGroup, tasks chan RenderTask, estimates []pixelEstimate, firstSample, count int, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, progress *progressTracker) {
+	defer wg.Done()
+	
+	sampler := r.newSampler()
+	for task := range tasks {
+		for y := task.startY; y < task.endY; y++ {
+			if ctx.Err() != nil {
+				return
+			}
+			for x := task.startX; x < task.endX; x++ {
+				estimate := &estimates[y*task.width+x]
+				if estimate.converged || estimate.samples >= firstSample+count {
+					continue
+				}
+				
+				r.accumulatePixel(estimate, x, y, firstSample+count, task, world, lights, sky, sampler)
+				estimate.updateError(r.noiseThreshold)
+			}
+		}
+		progress.tileDone(task)
+	}
+}
+
+var heatmapColors = []math.Vec3{
+	{X: 0.05, Y: 0.05, Z: 0.3},
+	{X: 0.1, Y: 0.5, Z: 0.9},
+	{X: 0.2, Y: 0.8, Z: 0.3},
+	{X: 1.0, Y: 0.9, Z: 0.2},
+	{X: 0.9, Y: 0.1, Z: 0.1},
+}
+
+// sampleHeatmap shows each pixel's sample count from minSamples (dark blue)
+// to maxSamples (red).
+func sampleHeatmap(estimates []pixelEstimate, width, height, minSamples, maxSamples int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	span := float64(maxSamples - minSamples)
+	
+	for i, estimate := range estimates {
+		t := 1.0
+		if span > 0 {
+			t = math.FastClamp(float64(estimate.samples-minSamples)/span, 0, 1)
+		}
+		
+		position := t * float64(len(heatmapColors)-1)
+		stop := int(position)
+		if stop >= len(heatmapColors)-1 {
+			stop = len(heatmapColors) - 2
+		}
+		c := heatmapColors[stop].Lerp(heatmapColors[stop+1], position-float64(stop))
+		
+		red, green, blue := c.ToRGB()
+		img.Set(i%width, i/width, color.RGBA{red, green, blue, 255})
+	}
+	
+	return img
+}
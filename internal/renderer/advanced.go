@@ -2,21 +2,22 @@ package renderer
 
 import (
 	stdmath "math"
+	"raytraceGo/internal/effects"
 	"raytraceGo/internal/geometry"
+	"raytraceGo/internal/lighting"
 	"raytraceGo/internal/math"
-	"raytraceGo/internal/scene"
+	"raytraceGo/internal/sampling"
 )
 
-func (r *ParallelRenderer) calculateSoftShadows(hit *geometry.HitRecord, hittables []geometry.Hittable, lights []scene.Light) math.Vec3 {
+func (r *ParallelRenderer) calculateSoftShadows(hit *geometry.HitRecord, world geometry.Hittable, lights []lighting.Light) math.Vec3 {
 	shadowColor := math.Vec3{X: 1, Y: 1, Z: 1}
 	
 	for _, light := range lights {
-		lightDir := light.Position.Sub(hit.Point).Normalize()
-		lightDistance := light.Position.Sub(hit.Point).Length()
+		lightDir, lightDistance := light.GetShadowRay(hit.Point)
 		
 		shadowRay := geometry.NewRay(hit.Point, lightDir)
 		
-		shadowHit, hit := r.hitWorld(shadowRay, hittables, 0.001, lightDistance)
+		shadowHit, hit := r.hitWorld(shadowRay, world, 0.001, lightDistance)
 		if hit && shadowHit.T < lightDistance {
 			shadowFactor := 0.3
 			shadowColor = shadowColor.MulScalar(shadowFactor)
@@ -26,19 +27,26 @@ func (r *ParallelRenderer) calculateSoftShadows(hit *geometry.HitRecord, hittabl
 	return shadowColor
 }
 
-func (r *ParallelRenderer) applyDepthOfField(ray geometry.Ray, camera *scene.Camera) geometry.Ray {
-	if !r.depthOfField {
+// applyDepthOfField turns a pinhole ray into a thin-lens ray: the origin is
+// jittered across the lens disk and the ray is re-aimed at the point where
+// the pinhole ray crosses the focus plane.
+func applyDepthOfField(ray geometry.Ray, u, v, w math.Vec3, dof *effects.DepthOfField, lensU, lensV float64) geometry.Ray {
+	if dof == nil || !dof.Enabled || dof.Aperture <= 0 {
 		return ray
 	}
 	
-	lensRadius := 0.1
-	focusDistance := 10.0
+	cosTheta := -ray.Direction.Dot(w)
+	if cosTheta <= 0 {
+		return ray
+	}
+	focusPoint := ray.At(dof.FocusDistance / cosTheta)
 	
-	rd := math.RandomVec3InUnitDisk().MulScalar(lensRadius)
-	offset := camera.Up.MulScalar(rd.X).Add(camera.LookAt.Cross(camera.Up).Normalize().MulScalar(rd.Y))
+	diskX, diskY := sampling.ConcentricDisk(lensU, lensV)
+	lensRadius := dof.GetLensRadius()
+	offset := u.MulScalar(diskX * lensRadius).Add(v.MulScalar(diskY * lensRadius))
 	
 	origin := ray.Origin.Add(offset)
-	direction := ray.Direction.MulScalar(focusDistance).Sub(offset).Normalize()
+	direction := focusPoint.Sub(origin).Normalize()
 	
 	return geometry.NewRay(origin, direction)
 }
@@ -77,13 +85,13 @@ func (r *ParallelRenderer) calculateMotionBlur(ray geometry.Ray, time float64) g
 	return geometry.NewRay(newOrigin, ray.Direction)
 }
 
-func (r *ParallelRenderer) calculateCaustics(hit *geometry.HitRecord, lights []scene.Light) math.Vec3 {
+func (r *ParallelRenderer) calculateCaustics(hit *geometry.HitRecord, lights []lighting.Light) math.Vec3 {
 	causticColor := math.Vec3{}
 	
 	for _, light := range lights {
-		lightDir := light.Position.Sub(hit.Point).Normalize()
+		lightDir := light.GetDirection(hit.Point)
 		causticIntensity := stdmath.Max(0, hit.Normal.Dot(lightDir))
-		causticColor = causticColor.Add(light.Color.MulScalar(causticIntensity))
+		causticColor = causticColor.Add(light.GetColor().MulScalar(causticIntensity))
 	}
 	
 	return causticColor
@@ -99,13 +107,13 @@ func (r *ParallelRenderer) calculateSubsurfaceScattering(hit *geometry.HitRecord
 	return scatterColor.MulScalar(0.5)
 }
 
-func (r *ParallelRenderer) calculateVolumetricLighting(ray geometry.Ray, hittables []geometry.Hittable, lights []scene.Light) math.Vec3 {
+func (r *ParallelRenderer) calculateVolumetricLighting(ray geometry.Ray, world geometry.Hittable, lights []lighting.Light) math.Vec3 {
 	volumetricColor := math.Vec3{}
 	
 	for _, light := range lights {
-		lightDir := light.Position.Sub(ray.Origin).Normalize()
+		lightDir := light.GetDirection(ray.Origin)
 		intensity := stdmath.Max(0, ray.Direction.Dot(lightDir))
-		volumetricColor = volumetricColor.Add(light.Color.MulScalar(intensity * 0.1))
+		volumetricColor = volumetricColor.Add(light.GetColor().MulScalar(intensity * 0.1))
 	}
 	
 	return volumetricColor
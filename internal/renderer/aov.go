@@ -0,0 +1,199 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	stdmath "math"
+	"raytraceGo/internal/geometry"
+	"raytraceGo/internal/material"
+	"raytraceGo/internal/math"
+	"raytraceGo/internal/output"
+	"raytraceGo/internal/sampling"
+	"slices"
+	"sync"
+)
+
+// Most samples per pixel of the AOV pass. First-hit features converge far
+// faster than the beauty, so a few samples are enough to anti-alias them.
+const maxAOVSamples = 16
+
+// objectHittable tags the hits of one scene object with its ID.
+type objectHittable struct {
+	geometry.Hittable
+	id int
+}
+
+func (o *objectHittable) Hit(ray geometry.Ray, tMin, tMax float64) (*geometry.HitRecord, bool) {
+	hit, ok := o.Hittable.Hit(ray, tMin, tMax)
+	if ok {
+		hit.ObjectID = o.id
+	}
+	return hit, ok
+}
+
+// tagObjects gives every hittable an object ID, its 1-based position in the
+// list, leaving 0 for the background.
+func tagObjects(hittables []geometry.Hittable) []geometry.Hittable {
+	tagged := make([]geometry.Hittable, len(hittables))
+	for i, hittable := range hittables {
+		tagged[i] = &objectHittable{Hittable: hittable, id: i + 1}
+	}
+	return tagged
+}
+
+// pixelFeatures are the first-hit values of a pixel, averaged over the samples
+// that hit something. IDs come from the first of those samples.
+type pixelFeatures struct {
+	depth      float64
+	normal     math.Vec3
+	albedo     math.Vec3
+	position   math.Vec3
+	materialID float64
+	objectID   float64
+}
+
+func (f pixelFeatures) value(aov string) math.Vec3 {
+	switch aov {
+	case output.AOVDepth:
+		return math.Vec3{X: f.depth}
+	case output.AOVNormal:
+		return f.normal
+	case output.AOVAlbedo:
+		return f.albedo
+	case output.AOVPosition:
+		return f.position
+	case output.AOVMaterialID:
+		return math.Vec3{X: f.materialID}
+	case output.AOVObjectID:
+		return math.Vec3{X: f.objectID}
+	}
+	return math.Vec3{}
+}
+
+// renderAOVs renders the named AOVs as layers, tile by tile like the beauty.
+func (r *ParallelRenderer) renderAOVs(ctx context.Context, names []string, width, height int, camera Camera, world geometry.Hittable) []*output.Layer {
+	layers := make([]*output.Layer, 0, len(names))
+	for _, name := range names {
+		layer, err := output.NewAOVLayer(name, width, height)
+		if err != nil {
+			continue
+		}
+		layers = append(layers, layer)
+	}
+	
+	tasks := r.createRenderTasks(ctx, width, height, camera)
+	
+	var wg sync.WaitGroup
+	for i := 0; i < r.numWorkers; i++ {
+		wg.Add(1)
+		go r.aovWorker(&wg, tasks, layers, world)
+	}
+	wg.Wait()
+	
+	return layers
+}
+
+// layerNames lists the AOVs to render: the requested ones, plus the denoiser's
+// feature buffers when it is enabled.
+func (r *ParallelRenderer) layerNames() []string {
+	names := append([]string(nil), r.aovs...)
+	if !r.denoise {
+		return names
+	}
+	for _, feature := range denoiseFeatures {
+		if !slices.Contains(names, feature) {
+			names = append(names, feature)
+		}
+	}
+	return names
+}
+
+// requestedLayers keeps the layers of the requested AOVs, in request order.
+func (r *ParallelRenderer) requestedLayers(layers []*output.Layer) []*output.Layer {
+	var requested []*output.Layer
+	for _, name := range r.aovs {
+		if layer := findLayer(layers, name); layer != nil {
+			requested = append(requested, layer)
+		}
+	}
+	return requested
+}
+
+func findLayer(layers []*output.Layer, name string) *output.Layer {
+	for _, layer := range layers {
+		if layer.Name == name {
+			return layer
+		}
+	}
+	return nil
+}
+
+func (r *ParallelRenderer) aovWorker(wg *sync.WaitGroup, tasks chan RenderTask, layers []*output.Layer, world geometry.Hittable) {
+	defer wg.Done()
+	
+	sampler := r.newSampler()
+	for task := range tasks {
+		for y := task.startY; y < task.endY; y++ {
+			for x := task.startX; x < task.endX; x++ {
+				features := r.sampleFeatures(x, y, task, world, sampler)
+				for _, layer := range layers {
+					layer.Set(x, y, features.value(layer.Name))
+				}
+			}
+		}
+	}
+}
+
+// sampleFeatures traces the pixel's first camera rays with the same sample
+// positions as the beauty.
+func (r *ParallelRenderer) sampleFeatures(x, y int, task RenderTask, world geometry.Hittable, sampler sampling.Sampler) pixelFeatures {
+	var features pixelFeatures
+	hits := 0
+	
+	samples := min(r.samplesPerPixel(), maxAOVSamples)
+	for s := 0; s < samples; s++ {
+		ray, ok := r.cameraRay(x, y, s, task.width, task.height, task.camera, sampler)
+		if !ok {
+			continue
+		}
+		hit, ok := r.hitWorld(ray, world, 0.001, stdmath.Inf(1))
+		if !ok {
+			continue
+		}
+		
+		mat := hit.Material.(material.Material)
+		if hits == 0 {
+			features.materialID = materialID(mat)
+			features.objectID = float64(hit.ObjectID)
+		}
+		hits++
+		
+		features.depth += hit.T * ray.Direction.Length()
+		features.normal = features.normal.Add(hit.Normal)
+		features.albedo = features.albedo.Add(mat.GetAlbedo())
+		features.position = features.position.Add(hit.Point)
+	}
+	
+	if hits == 0 {
+		return features
+	}
+	
+	n := float64(hits)
+	features.depth /= n
+	features.albedo = features.albedo.DivScalar(n)
+	features.position = features.position.DivScalar(n)
+	if !features.normal.NearZero() {
+		features.normal = features.normal.Normalize()
+	}
+	return features
+}
+
+// materialID hashes the material's type and parameters, so identical
+// materials share an ID and IDs are stable between renders. IDs stay below
+// 2^24, which float32 channels store exactly.
+func materialID(m material.Material) float64 {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%T %v", m, m)
+	return float64(h.Sum32()%(1<<24-1) + 1)
+}
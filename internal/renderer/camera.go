@@ -0,0 +1,227 @@
+package renderer
+
+import (
+	stdmath "math"
+	"raytraceGo/internal/effects"
+	"raytraceGo/internal/geometry"
+	"raytraceGo/internal/math"
+	"raytraceGo/internal/scene"
+)
+
+// Camera maps normalised image coordinates (s to the right, t upwards, both
+// in [0, 1]) to a primary ray. The bool is false for image points outside
+// the projection's coverage, such as the corners of a fisheye image. lensU
+// and lensV, also in [0, 1), pick the point on the lens for depth of field.
+type Camera interface {
+	GetRay(s, t, lensU, lensV float64) (geometry.Ray, bool)
+}
+
+type PerspectiveCamera struct {
+	Origin          math.Vec3
+	LowerLeftCorner math.Vec3
+	Horizontal      math.Vec3
+	Vertical        math.Vec3
+	U, V, W         math.Vec3
+	DepthOfField    *effects.DepthOfField
+}
+
+func NewPerspectiveCamera(position, lookAt, up math.Vec3, vfov, aspectRatio float64) *PerspectiveCamera {
+	u, v, w := cameraBasis(position, lookAt, up)
+	
+	theta := math.FastDegToRad(vfov)
+	viewportHeight := 2.0 * stdmath.Tan(theta/2)
+	viewportWidth := aspectRatio * viewportHeight
+	
+	horizontal := u.MulScalar(viewportWidth)
+	vertical := v.MulScalar(viewportHeight)
+	lowerLeftCorner := position.Sub(horizontal.DivScalar(2)).Sub(vertical.DivScalar(2)).Sub(w)
+	
+	return &PerspectiveCamera{
+		Origin:          position,
+		LowerLeftCorner: lowerLeftCorner,
+		Horizontal:      horizontal,
+		Vertical:        vertical,
+		U:               u,
+		V:               v,
+		W:               w,
+	}
+}
+
+func (c *PerspectiveCamera) GetRay(s, t, lensU, lensV float64) (geometry.Ray, bool) {
+	direction := c.LowerLeftCorner.Add(c.Horizontal.MulScalar(s)).Add(c.Vertical.MulScalar(t)).Sub(c.Origin)
+	ray := geometry.NewRay(c.Origin, direction.Normalize())
+	return applyDepthOfField(ray, c.U, c.V, c.W, c.DepthOfField, lensU, lensV), true
+}
+
+type OrthographicCamera struct {
+	Origin       math.Vec3
+	U, V, W      math.Vec3
+	ViewWidth    float64
+	ViewHeight   float64
+	DepthOfField *effects.DepthOfField
+}
+
+func NewOrthographicCamera(position, lookAt, up math.Vec3, viewWidth, aspectRatio float64) *OrthographicCamera {
+	u, v, w := cameraBasis(position, lookAt, up)
+	
+	return &OrthographicCamera{
+		Origin:     position,
+		U:          u,
+		V:          v,
+		W:          w,
+		ViewWidth:  viewWidth,
+		ViewHeight: viewWidth / aspectRatio,
+	}
+}
+
+func (c *OrthographicCamera) GetRay(s, t, lensU, lensV float64) (geometry.Ray, bool) {
+	origin := c.Origin.Add(c.U.MulScalar((s - 0.5) * c.ViewWidth)).Add(c.V.MulScalar((t - 0.5) * c.ViewHeight))
+	ray := geometry.NewRay(origin, c.W.MulScalar(-1))
+	return applyDepthOfField(ray, c.U, c.V, c.W, c.DepthOfField, lensU, lensV), true
+}
+
+// FisheyeCamera is an equidistant fisheye: the angle from the view axis grows
+// linearly with the distance from the image centre, and the image circle
+// spans the shorter image dimension.
+type FisheyeCamera struct {
+	Origin      math.Vec3
+	U, V, W     math.Vec3
+	FOV         float64
+	AspectRatio float64
+}
+
+func NewFisheyeCamera(position, lookAt, up math.Vec3, fov, aspectRatio float64) *FisheyeCamera {
+	u, v, w := cameraBasis(position, lookAt, up)
+	
+	return &FisheyeCamera{
+		Origin:      position,
+		U:           u,
+		V:           v,
+		W:           w,
+		FOV:         fov,
+		AspectRatio: aspectRatio,
+	}
+}
+
+func (c *FisheyeCamera) GetRay(s, t, lensU, lensV float64) (geometry.Ray, bool) {
+	x := 2*s - 1
+	y := 2*t - 1
+	if c.AspectRatio > 1 {
+		x *= c.AspectRatio
+	} else {
+		y /= c.AspectRatio
+	}
+	
+	radius := stdmath.Hypot(x, y)
+	if radius > 1 {
+		return geometry.Ray{}, false
+	}
+	
+	theta := radius * math.FastDegToRad(c.FOV) / 2
+	phi := stdmath.Atan2(y, x)
+	sinTheta, cosTheta := stdmath.Sincos(theta)
+	sinPhi, cosPhi := stdmath.Sincos(phi)
+	
+	direction := c.U.MulScalar(sinTheta * cosPhi).
+		Add(c.V.MulScalar(sinTheta * sinPhi)).
+		Sub(c.W.MulScalar(cosTheta))
+		
+	return geometry.NewRay(c.Origin, direction.Normalize()), true
+}
+
+// EquirectangularCamera covers the full sphere around the camera, mapping s to
+// longitude and t to latitude, with the view direction at the image centre.
+type EquirectangularCamera struct {
+	Origin  math.Vec3
+	U, V, W math.Vec3
+}
+
+func NewEquirectangularCamera(position, lookAt, up math.Vec3) *EquirectangularCamera {
+	u, v, w := cameraBasis(position, lookAt, up)
+	
+	return &EquirectangularCamera{
+		Origin: position,
+		U:      u,
+		V:      v,
+		W:      w,
+	}
+}
+
+func (c *EquirectangularCamera) GetRay(s, t, lensU, lensV float64) (geometry.Ray, bool) {
+	longitude := (s - 0.5) * 2 * stdmath.Pi
+	latitude := (t - 0.5) * stdmath.Pi
+	sinLon, cosLon := stdmath.Sincos(longitude)
+	sinLat, cosLat := stdmath.Sincos(latitude)
+	
+	direction := c.U.MulScalar(cosLat * sinLon).
+		Add(c.V.MulScalar(sinLat)).
+		Sub(c.W.MulScalar(cosLat * cosLon))
+		
+	return geometry.NewRay(c.Origin, direction.Normalize()), true
+}
+
+// cameraBasis returns the right, up and backward vectors of a camera at
+// position looking towards lookAt. Degenerate inputs fall back to looking
+// down -Z with +Y up.
+func cameraBasis(position, lookAt, up math.Vec3) (u, v, w math.Vec3) {
+	w = position.Sub(lookAt).Normalize()
+	if w.NearZero() {
+		w = math.Vec3{X: 0, Y: 0, Z: 1}
+	}
+	
+	if up.NearZero() {
+		up = math.Vec3{X: 0, Y: 1, Z: 0}
+	}
+	
+	u = up.Cross(w)
+	if u.NearZero() {
+		u = math.Vec3{X: 0, Y: 0, Z: 1}.Cross(w)
+		if u.NearZero() {
+			u = math.Vec3{X: 1, Y: 0, Z: 0}
+		}
+	}
+	u = u.Normalize()
+	v = w.Cross(u)
+	
+	return u, v, w
+}
+
+func cameraAspectRatio(camera scene.Camera, width, height int) float64 {
+	if width > 0 && height > 0 {
+		return float64(width) / float64(height)
+	}
+	if camera.AspectRatio > 0 {
+		return camera.AspectRatio
+	}
+	return 1.0
+}
+
+func cameraFOV(camera scene.Camera) float64 {
+	if camera.FOV <= 0 || camera.FOV >= 180 {
+		return 90.0
+	}
+	return camera.FOV
+}
+
+func fisheyeFOV(camera scene.Camera) float64 {
+	if camera.FOV <= 0 || camera.FOV > 360 {
+		return 180.0
+	}
+	return camera.FOV
+}
+
+// orthographicViewWidth defaults to the width a perspective camera with the
+// same fov would see at the lookAt distance, so switching projection keeps
+// the framing roughly the same.
+func orthographicViewWidth(camera scene.Camera, aspectRatio float64) float64 {
+	if camera.ViewWidth > 0 {
+		return camera.ViewWidth
+	}
+	
+	distance := camera.LookAt.Sub(camera.Position).Length()
+	if distance == 0 {
+		distance = 1.0
+	}
+	
+	return 2.0 * distance * stdmath.Tan(math.FastDegToRad(cameraFOV(camera))/2) * aspectRatio
+}
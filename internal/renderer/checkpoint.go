@@ -0,0 +1,341 @@
+package renderer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	stdmath "math"
+	"os"
+	"raytraceGo/internal/math"
+	"raytraceGo/internal/sampling"
+	"raytraceGo/internal/scene"
+	"time"
+)
+
+// A checkpoint file starts with checkpointMagic and the length of a JSON
+// header, followed by the header and checkpointPixelSize bytes per pixel.
+const (
+	checkpointMagic     = "RTCKPT01"
+	checkpointPixelSize = 5*8 + 2*4 + 1
+	// Bounds on what loading a checkpoint allocates, well beyond any real
+	// render.
+	maxCheckpointHeader = 1 << 20
+	maxCheckpointPixels = 1 << 26
+	// DefaultCheckpointInterval is how often a render saves its progress.
+	DefaultCheckpointInterval = 5 * time.Minute
+)
+
+// checkpoint is the state of a render: the samples accumulated in every pixel
+// and the settings they were rendered with. Samplers derive their values from
+// the seed, the pixel and the sample index, so the per-pixel sample counts are
+// all the random state a resumed render needs.
+type checkpoint struct {
+	header    checkpointHeader
+	estimates []pixelEstimate
+}
+
+type checkpointHeader struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Scene  string `json:"scene"`
+	// NextSample is the first sample of the adaptive pass to render next.
+	NextSample int                `json:"nextSample"`
+	Settings   checkpointSettings `json:"settings"`
+}
+
+// checkpointSettings are the renderer settings that affect the samples.
+type checkpointSettings struct {
+	Samples              int     `json:"samples"`
+	MaxDepth             int     `json:"maxDepth"`
+	AntiAliasing         bool    `json:"antiAliasing"`
+	RecursiveReflections bool    `json:"recursiveReflections"`
+	SoftShadows          bool    `json:"softShadows"`
+	DepthOfField         bool    `json:"depthOfField"`
+	Integrator           string  `json:"integrator"`
+	Seed                 uint64  `json:"seed"`
+	Sampler              string  `json:"sampler"`
+	Adaptive             bool    `json:"adaptive"`
+	MinSamples           int     `json:"minSamples"`
+	MaxSamples           int     `json:"maxSamples"`
+	NoiseThreshold       float64 `json:"noiseThreshold"`
+}
+
+func (r *ParallelRenderer) checkpointSettings() checkpointSettings {
+	return checkpointSettings{
+		Samples:              r.samples,
+		MaxDepth:             r.maxDepth,
+		AntiAliasing:         r.antiAliasing,
+		RecursiveReflections: r.recursiveReflections,
+		SoftShadows:          r.softShadows,
+		DepthOfField:         r.depthOfField,
+		Integrator:           r.integrator,
+		Seed:                 r.seed,
+		Sampler:              r.sampler,
+		Adaptive:             r.adaptive,
+		MinSamples:           r.minSamples,
+		MaxSamples:           r.maxSamples,
+		NoiseThreshold:       r.noiseThreshold,
+	}
+}
+
+func (r *ParallelRenderer) applyCheckpointSettings(settings checkpointSettings) {
+	r.samples = settings.Samples
+	r.maxDepth = settings.MaxDepth
+	r.antiAliasing = settings.AntiAliasing
+	r.recursiveReflections = settings.RecursiveReflections
+	r.softShadows = settings.SoftShadows
+	r.depthOfField = settings.DepthOfField
+	r.integrator = settings.Integrator
+	r.seed = settings.Seed
+	r.sampler = settings.Sampler
+	r.adaptive = settings.Adaptive
+	r.minSamples = settings.MinSamples
+	r.maxSamples = settings.MaxSamples
+	r.noiseThreshold = settings.NoiseThreshold
+}
+
+// SetCheckpoint makes renders save their progress to path every interval,
+// when cancelled and when finished, for Resume to continue.
+func (r *ParallelRenderer) SetCheckpoint(path string, interval time.Duration) {
+	r.checkpointPath = path
+	r.checkpointInterval = interval
+}
+
+// Resume loads a checkpoint written by an earlier render and restores the
+// settings it was rendered with; the next render of the same scene at the
+// same size continues from it. If samples is positive it raises the samples
+// per pixel of a fixed-count render, or the maximum of an adaptive one, to
+// add samples to a finished render.
+//
+// A resumed render is bit-identical to one that was never interrupted, also
+// when adding samples. Samples cannot be added to renders where that would
+// not hold: those with the stratified sampler, whose strata depend on the
+// sample count, and adaptive renders whose earlier maximum cut their last
+// pass short of a whole batch.
+func (r *ParallelRenderer) Resume(path string, samples int) error {
+	cp, err := loadCheckpoint(path)
+	if err != nil {
+		return err
+	}
+	
+	settings := cp.header.Settings
+	saved := settings.Samples
+	if settings.Adaptive {
+		saved = settings.MaxSamples
+	}
+	if samples > 0 && samples < saved {
+		return fmt.Errorf("checkpoint %s already has up to %d samples per pixel, cannot resume with %d", path, saved, samples)
+	}
+	if samples > saved && settings.Sampler == sampling.Stratified {
+		return fmt.Errorf("checkpoint %s uses the %s sampler, whose samples depend on their count, cannot add samples", path, sampling.Stratified)
+	}
+	if samples > saved && settings.Adaptive && (saved < settings.MinSamples || (saved-settings.MinSamples)%adaptiveBatchSize != 0) {
+		return fmt.Errorf("checkpoint %s ends its last adaptive pass at %d samples per pixel, short of a whole batch, cannot add samples", path, saved)
+	}
+	if samples > 0 {
+		if settings.Adaptive {
+			settings.MaxSamples = samples
+		} else {
+			settings.Samples = samples
+		}
+	}
+	
+	r.applyCheckpointSettings(settings)
+	r.resume = cp
+	return nil
+}
+
+// startEstimates returns the per-pixel estimates a render starts from: those
+// of the checkpoint passed to Resume, or empty ones.
+func (r *ParallelRenderer) startEstimates(s *scene.Scene, width, height int) ([]pixelEstimate, int, error) {
+	cp := r.resume
+	r.resume = nil
+	if cp == nil {
+		return make([]pixelEstimate, width*height), 0, nil
+	}
+	
+	if cp.header.Width != width || cp.header.Height != height {
+		return nil, 0, fmt.Errorf("checkpoint is %dx%d, cannot resume at %dx%d", cp.header.Width, cp.header.Height, width, height)
+	}
+	if cp.header.Scene != sceneFingerprint(s) {
+		return nil, 0, fmt.Errorf("checkpoint was rendered from a different scene")
+	}
+	return cp.estimates, cp.header.NextSample, nil
+}
+
+// sceneFingerprint identifies the parts of a scene that affect its samples;
+// post-processing and tone mapping can change between resumed renders.
+func sceneFingerprint(s *scene.Scene) string {
+	data, _ := json.Marshal([]interface{}{s.Camera, s.Objects, s.Lights, s.Atmosphere})
+	hash := fnv.New64a()
+	hash.Write(data)
+	return fmt.Sprintf("%016x", hash.Sum64())
+}
+
+// checkpointer saves the progress of one render; a nil checkpointer saves
+// nothing.
+type checkpointer struct {
+	path     string
+	interval time.Duration
+	last     time.Time
+	header   checkpointHeader
+}
+
+func (r *ParallelRenderer) newCheckpointer(s *scene.Scene, width, height int) *checkpointer {
+	if r.checkpointPath == "" {
+		return nil
+	}
+	return &checkpointer{
+		path:     r.checkpointPath,
+		interval: r.checkpointInterval,
+		last:     time.Now(),
+		header: checkpointHeader{
+			Width:    width,
+			Height:   height,
+			Scene:    sceneFingerprint(s),
+			Settings: r.checkpointSettings(),
+		},
+	}
+}
+
+// saveEvery saves if the interval has passed since the last save.
+func (c *checkpointer) saveEvery(estimates []pixelEstimate, nextSample int) {
+	if c == nil || time.Since(c.last) < c.interval {
+		return
+	}
+	c.save(estimates, nextSample)
+}
+
+// save writes the checkpoint to a temporary file and renames it over the
+// previous one, so that a crash while saving leaves the last checkpoint
+// intact. Errors are reported but do not stop the render.
+func (c *checkpointer) save(estimates []pixelEstimate, nextSample int) {
+	if c == nil {
+		return
+	}
+	c.last = time.Now()
+	c.header.NextSample = nextSample
+	
+	if err := writeCheckpoint(c.path, &checkpoint{header: c.header, estimates: estimates}); err != nil {
+		fmt.Printf("Error saving checkpoint: %v\n", err)
+	}
+}
+
+func writeCheckpoint(path string, cp *checkpoint) error {
+	header, err := json.Marshal(cp.header)
+	if err != nil {
+		return fmt.Errorf("error encoding checkpoint: %v", err)
+	}
+	
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("error creating checkpoint: %v", err)
+	}
+	
+	writer := bufio.NewWriter(file)
+	buf := []byte(checkpointMagic)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(header)))
+	buf = append(buf, header...)
+	writer.Write(buf)
+	
+	pixel := make([]byte, 0, checkpointPixelSize)
+	for i := range cp.estimates {
+		writer.Write(appendPixelEstimate(pixel[:0], &cp.estimates[i]))
+	}
+	
+	err = writer.Flush()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error writing checkpoint: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func loadCheckpoint(path string) (*checkpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening checkpoint: %v", err)
+	}
+	defer file.Close()
+	
+	reader := bufio.NewReader(file)
+	prefix := make([]byte, len(checkpointMagic)+4)
+	if _, err := io.ReadFull(reader, prefix); err != nil || string(prefix[:len(checkpointMagic)]) != checkpointMagic {
+		return nil, fmt.Errorf("%s is not a render checkpoint", path)
+	}
+	
+	headerLength := binary.LittleEndian.Uint32(prefix[len(checkpointMagic):])
+	if headerLength > maxCheckpointHeader {
+		return nil, fmt.Errorf("checkpoint header of %d bytes is too large", headerLength)
+	}
+	header := make([]byte, headerLength)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("error reading checkpoint: %v", err)
+	}
+	cp := &checkpoint{}
+	if err := json.Unmarshal(header, &cp.header); err != nil {
+		return nil, fmt.Errorf("error parsing checkpoint: %v", err)
+	}
+	width, height := cp.header.Width, cp.header.Height
+	if width <= 0 || height <= 0 || width > maxCheckpointPixels || height > maxCheckpointPixels || width*height > maxCheckpointPixels {
+		return nil, fmt.Errorf("checkpoint has invalid size %dx%d", width, height)
+	}
+	
+	// Check the pixels are all there before allocating them.
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint: %v", err)
+	}
+	if remaining := info.Size() - int64(len(prefix)) - int64(headerLength); remaining < int64(width*height)*checkpointPixelSize {
+		return nil, fmt.Errorf("checkpoint is truncated: %d bytes of pixels, want %d", remaining, width*height*checkpointPixelSize)
+	}
+	
+	cp.estimates = make([]pixelEstimate, width*height)
+	pixel := make([]byte, checkpointPixelSize)
+	for i := range cp.estimates {
+		if _, err := io.ReadFull(reader, pixel); err != nil {
+			return nil, fmt.Errorf("error reading checkpoint: %v", err)
+		}
+		cp.estimates[i] = decodePixelEstimate(pixel, cp.header.Settings.NoiseThreshold)
+	}
+	return cp, nil
+}
+
+// appendPixelEstimate encodes the sums and counts of an estimate. The relative
+// error is not stored; it is a function of them.
+func appendPixelEstimate(buf []byte, p *pixelEstimate) []byte {
+	for _, v := range []float64{p.sum.X, p.sum.Y, p.sum.Z, p.luminance, p.luminanceSq} {
+		buf = binary.LittleEndian.AppendUint64(buf, stdmath.Float64bits(v))
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(p.samples))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(p.covered))
+	if p.converged {
+		return append(buf, 1)
+	}
+	return append(buf, 0)
+}
+
+func decodePixelEstimate(buf []byte, noiseThreshold float64) pixelEstimate {
+	value := func(i int) float64 {
+		return stdmath.Float64frombits(binary.LittleEndian.Uint64(buf[i*8:]))
+	}
+	p := pixelEstimate{
+		sum:         math.Vec3{X: value(0), Y: value(1), Z: value(2)},
+		luminance:   value(3),
+		luminanceSq: value(4),
+		samples:     int(binary.LittleEndian.Uint32(buf[40:])),
+		covered:     int(binary.LittleEndian.Uint32(buf[44:])),
+		converged:   buf[48] == 1,
+	}
+	if p.samples > 0 {
+		p.updateError(noiseThreshold)
+	}
+	return p
+}
@@ -0,0 +1,163 @@
+package renderer
+
+import (
+	"context"
+	stdmath "math"
+	"raytraceGo/internal/math"
+	"raytraceGo/internal/output"
+	"sync"
+)
+
+// The denoiser's feature buffers, rendered with the AOVs when it is enabled.
+var denoiseFeatures = []string{output.AOVAlbedo, output.AOVNormal, output.AOVDepth}
+
+const (
+	// Each à-trous iteration doubles the filter's footprint; five reach
+	// 2^5+1 = 33 pixels across with a 5x5 kernel.
+	denoiseIterations = 5
+	// Edge-stopping parameters of the feature buffers. The color sigma
+	// applies to tone-compressed colors and halves with every iteration.
+	denoiseColorSigma  = 0.5
+	denoiseNormalSigma = 0.3
+	denoiseAlbedoSigma = 0.1
+	denoiseDepthSigma  = 0.1
+)
+
+// B3-spline weights of the à-trous kernel.
+var atrousKernel = [5]float64{1.0 / 16, 1.0 / 4, 3.0 / 8, 1.0 / 4, 1.0 / 16}
+
+// denoiseGuide holds the feature buffers that stop the filter at edges.
+type denoiseGuide struct {
+	width, height int
+	albedo, normal []math.Vec3
+	depth []float64
+}
+
+// denoiseFramebuffer filters the beauty with the edge-avoiding à-trous wavelet
+// transform of Dammertz et al. Every iteration is a sparse 5x5 blur whose taps
+// are weighted down where the color, normal, albedo or depth differ from the
+// centre pixel, so noise is averaged within surfaces but not across them.
+// Iterations run tile by tile on the workers and read only the previous
+// iteration's result, so the output does not depend on the worker count. If
+// ctx is cancelled the framebuffer is left as it was.
+func (r *ParallelRenderer) denoiseFramebuffer(ctx context.Context, framebuffer *output.Framebuffer, layers []*output.Layer, camera Camera) error {
+	albedo := findLayer(layers, output.AOVAlbedo)
+	normal := findLayer(layers, output.AOVNormal)
+	depth := findLayer(layers, output.AOVDepth)
+	if albedo == nil || normal == nil || depth == nil {
+		return nil
+	}
+	
+	width := framebuffer.Width
+	height := framebuffer.Height
+	guide := &denoiseGuide{
+		width:  width,
+		height: height,
+		albedo: make([]math.Vec3, width*height),
+		normal: make([]math.Vec3, width*height),
+		depth:  make([]float64, width*height),
+	}
+	src := make([]math.Vec3, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			src[i] = framebuffer.At(x, y)
+			guide.albedo[i] = albedo.At(x, y)
+			guide.normal[i] = normal.At(x, y)
+			guide.depth[i] = depth.At(x, y).X
+		}
+	}
+	dst := make([]math.Vec3, width*height)
+	
+	colorSigma := denoiseColorSigma
+	for i := 0; i < denoiseIterations; i++ {
+		tasks := r.createRenderTasks(ctx, width, height, camera)
+		
+		var wg sync.WaitGroup
+		for w := 0; w < r.numWorkers; w++ {
+			wg.Add(1)
+			go denoiseWorker(&wg, tasks, guide, src, dst, 1<<i, colorSigma)
+		}
+		wg.Wait()
+		
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		
+		src, dst = dst, src
+		colorSigma /= 2
+	}
+	
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			framebuffer.Set(x, y, src[y*width+x], framebuffer.Alpha(x, y))
+		}
+	}
+	return nil
+}
+
+func denoiseWorker(wg *sync.WaitGroup, tasks chan RenderTask, guide *denoiseGuide, src, dst []math.Vec3, step int, colorSigma float64) {
+	defer wg.Done()
+	
+	for task := range tasks {
+		for y := task.startY; y < task.endY; y++ {
+			for x := task.startX; x < task.endX; x++ {
+				dst[y*guide.width+x] = guide.filter(src, x, y, step, colorSigma)
+			}
+		}
+	}
+}
+
+// filter is one à-trous tap set around (x, y), with taps step pixels apart.
+// Taps outside the image are skipped and the weights renormalized.
+func (g *denoiseGuide) filter(src []math.Vec3, x, y, step int, colorSigma float64) math.Vec3 {
+	centre := y*g.width + x
+	centreColor := compressColor(src[centre])
+	
+	sum := math.Vec3{}
+	totalWeight := 0.0
+	for j := -2; j <= 2; j++ {
+		ty := y + j*step
+		if ty < 0 || ty >= g.height {
+			continue
+		}
+		for i := -2; i <= 2; i++ {
+			tx := x + i*step
+			if tx < 0 || tx >= g.width {
+				continue
+			}
+			
+			tap := ty*g.width + tx
+			distance := compressColor(src[tap]).Sub(centreColor).LengthSquared()/(colorSigma*colorSigma) +
+				g.normal[tap].Sub(g.normal[centre]).LengthSquared()/(denoiseNormalSigma*denoiseNormalSigma) +
+				g.albedo[tap].Sub(g.albedo[centre]).LengthSquared()/(denoiseAlbedoSigma*denoiseAlbedoSigma) +
+				relativeDepth(g.depth[tap], g.depth[centre])/denoiseDepthSigma
+			weight := atrousKernel[i+2] * atrousKernel[j+2] * stdmath.Exp(-distance)
+			
+			sum = sum.Add(src[tap].MulScalar(weight))
+			totalWeight += weight
+		}
+	}
+	
+	if totalWeight == 0 {
+		return src[centre]
+	}
+	return sum.DivScalar(totalWeight)
+}
+
+// compressColor maps radiance to [0, 1) per channel, so that the color weight
+// treats differences between bright pixels like those between dark ones and
+// fireflies do not stop the filter outright.
+func compressColor(c math.Vec3) math.Vec3 {
+	return math.Vec3{X: c.X / (1 + c.X), Y: c.Y / (1 + c.Y), Z: c.Z / (1 + c.Z)}
+}
+
+// relativeDepth is the depth difference relative to the farther of the two;
+// it is 1 between a surface and the background, whose depth is 0.
+func relativeDepth(a, b float64) float64 {
+	far := stdmath.Max(a, b)
+	if far == 0 {
+		return 0
+	}
+	return math.FastAbs(a-b) / far
+}
@@ -0,0 +1,221 @@
+package renderer
+
+import (
+	stdmath "math"
+	"raytraceGo/internal/atmosphere"
+	"raytraceGo/internal/geometry"
+	"raytraceGo/internal/lighting"
+	"raytraceGo/internal/material"
+	"raytraceGo/internal/math"
+	"raytraceGo/internal/sampling"
+)
+
+const (
+	// Paths shorter than this are never terminated by Russian roulette.
+	rouletteDepth = 3
+	maxSurvivalProbability = 0.95
+)
+
+// pathVertex carries what the next bounce needs to weight emission it hits:
+// whether the bounce came from a delta lobe, which light sampling cannot
+// reach, and otherwise the solid-angle pdf the direction was sampled with.
+type pathVertex struct {
+	depth      int
+	throughput math.Vec3
+	specular   bool
+	pdf        float64
+}
+
+// tracePath is the unbiased path tracing integrator. At every bounce the
+// lights are sampled directly and the BSDF is sampled for the continuation
+// ray; the two estimates of area light emission are combined with the power
+// heuristic. Area lights are treated as thin emitters that do not block
+// rays, matching their behaviour in shadow tests. Emissive surfaces are only
+// found by BSDF sampling. Materials without a BSDF fall back to Scatter,
+// treated as a delta lobe.
+func (r *ParallelRenderer) tracePath(ray geometry.Ray, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, vertex pathVertex, sampler sampling.Sampler) math.Vec3 {
+	hitRecord, hit := r.hitWorld(ray, world, 0.001, stdmath.Inf(1))
+	
+	tMax := stdmath.Inf(1)
+	if hit {
+		tMax = hitRecord.T
+	}
+	radiance := r.areaLightEmission(ray, lights, tMax, vertex)
+	
+	if !hit {
+		return radiance.Add(r.skyColor(ray, sky))
+	}
+	
+	mat := hitRecord.Material.(material.Material)
+	radiance = radiance.Add(mat.Emitted())
+	
+	if vertex.depth+1 < r.maxDepth {
+		radiance = radiance.Add(r.scatterPath(ray, hitRecord, mat, world, lights, sky, vertex, sampler))
+	}
+	
+	if sky != nil {
+		radiance = sky.ApplyAerialPerspective(radiance, hitRecord.T*ray.Direction.Length())
+	}
+	return radiance
+}
+
+// scatterPath returns the direct lighting at the hit plus the radiance
+// carried by the continuation ray, both already weighted by the BSDF.
+func (r *ParallelRenderer) scatterPath(ray geometry.Ray, hitRecord *geometry.HitRecord, mat material.Material, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, vertex pathVertex, sampler sampling.Sampler) math.Vec3 {
+	bsdf, ok := mat.(material.BSDF)
+	if !ok {
+		scattered, attenuation, scatteredHit := mat.Scatter(ray, hitRecord, sampler.Random())
+		if !scatteredHit {
+			return math.Vec3{}
+		}
+		sample := material.BSDFSample{Direction: scattered.Direction.Normalize(), Weight: attenuation, Specular: true}
+		return r.continuePath(hitRecord, sample, world, lights, sky, vertex, sampler)
+	}
+	
+	wo := ray.Direction.Normalize().MulScalar(-1)
+	radiance := r.sampleLights(hitRecord, wo, bsdf, world, lights, sampler)
+	
+	u, v := sampler.Get2D()
+	sample, sampled := bsdf.Sample(hitRecord, wo, u, v)
+	if !sampled {
+		return radiance
+	}
+	return radiance.Add(r.continuePath(hitRecord, sample, world, lights, sky, vertex, sampler))
+}
+
+func (r *ParallelRenderer) continuePath(hitRecord *geometry.HitRecord, sample material.BSDFSample, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, vertex pathVertex, sampler sampling.Sampler) math.Vec3 {
+	weight := sample.Weight
+	throughput := vertex.throughput.Mul(weight)
+	
+	if vertex.depth+1 >= rouletteDepth {
+		survival := stdmath.Min(stdmath.Max(throughput.X, stdmath.Max(throughput.Y, throughput.Z)), maxSurvivalProbability)
+		if survival <= 0 || sampler.Get1D() >= survival {
+			return math.Vec3{}
+		}
+		weight = weight.DivScalar(survival)
+		throughput = throughput.DivScalar(survival)
+	}
+	
+	next := pathVertex{
+		depth:      vertex.depth + 1,
+		throughput: throughput,
+		specular:   sample.Specular,
+		pdf:        sample.Pdf,
+	}
+	
+	incoming := r.tracePath(geometry.NewRay(hitRecord.Point, sample.Direction), world, lights, sky, next, sampler)
+	return weight.Mul(incoming)
+}
+
+// sampleLights is next-event estimation: one shadow ray per light. Delta
+// lights cannot be hit by BSDF samples, so only area lights need MIS.
+func (r *ParallelRenderer) sampleLights(hit *geometry.HitRecord, wo math.Vec3, bsdf material.BSDF, world geometry.Hittable, lights []lighting.Light, sampler sampling.Sampler) math.Vec3 {
+	radiance := math.Vec3{}
+	
+	for _, light := range lights {
+		if area, ok := light.(*lighting.AreaLight); ok {
+			radiance = radiance.Add(r.sampleAreaLight(hit, wo, bsdf, world, area, sampler))
+			continue
+		}
+		
+		lightIntensity := light.GetIntensity(hit.Point)
+		if lightIntensity <= 0 {
+			continue
+		}
+		
+		lightDir, lightDistance := light.GetShadowRay(hit.Point)
+		f := bsdf.Eval(hit, wo, lightDir)
+		if f.NearZero() {
+			continue
+		}
+		
+		if _, blocked := r.hitWorld(geometry.NewRay(hit.Point, lightDir), world, 0.001, lightDistance); blocked {
+			continue
+		}
+		
+		radiance = radiance.Add(f.Mul(light.GetColor()).MulScalar(lightIntensity))
+	}
+	
+	return radiance
+}
+
+func (r *ParallelRenderer) sampleAreaLight(hit *geometry.HitRecord, wo math.Vec3, bsdf material.BSDF, world geometry.Hittable, area *lighting.AreaLight, sampler sampling.Sampler) math.Vec3 {
+	target := area.SamplePoint(sampler.Get2D())
+	toLight := target.Sub(hit.Point)
+	distance := toLight.Length()
+	lightDir := toLight.DivScalar(distance)
+	
+	lightPdf := areaLightPdf(area, lightDir, distance)
+	if lightPdf <= 0 {
+		return math.Vec3{}
+	}
+	
+	f := bsdf.Eval(hit, wo, lightDir)
+	if f.NearZero() {
+		return math.Vec3{}
+	}
+	
+	if _, blocked := r.hitWorld(geometry.NewRay(hit.Point, lightDir), world, 0.001, distance-0.001); blocked {
+		return math.Vec3{}
+	}
+	
+	weight := powerHeuristic(lightPdf, bsdf.Pdf(hit, wo, lightDir))
+	return f.Mul(areaLightRadiance(area)).MulScalar(weight / lightPdf)
+}
+
+// areaLightEmission is the emission of area lights the ray crosses before
+// tMax, MIS-weighted against light sampling unless the ray came from the
+// camera or a delta lobe.
+func (r *ParallelRenderer) areaLightEmission(ray geometry.Ray, lights []lighting.Light, tMax float64, vertex pathVertex) math.Vec3 {
+	emission := math.Vec3{}
+	direction := ray.Direction.Normalize()
+	scale := ray.Direction.Length()
+	
+	for _, light := range lights {
+		area, ok := light.(*lighting.AreaLight)
+		if !ok {
+			continue
+		}
+		
+		t, crossed := area.Intersect(ray.Origin, direction, tMax*scale)
+		if !crossed {
+			continue
+		}
+		
+		weight := 1.0
+		if vertex.depth > 0 && !vertex.specular {
+			weight = powerHeuristic(vertex.pdf, areaLightPdf(area, direction, t))
+		}
+		emission = emission.Add(areaLightRadiance(area).MulScalar(weight))
+	}
+	
+	return emission
+}
+
+// areaLightRadiance spreads the light's intensity over its area, so that it
+// illuminates like a point light of the same intensity at a distance.
+func areaLightRadiance(area *lighting.AreaLight) math.Vec3 {
+	if area.Area() <= 0 {
+		return math.Vec3{}
+	}
+	return area.GetColor().MulScalar(area.Intensity / area.Area())
+}
+
+// areaLightPdf converts the uniform area density of a point on the light into
+// a solid-angle density as seen along direction at the given distance.
+func areaLightPdf(area *lighting.AreaLight, direction math.Vec3, distance float64) float64 {
+	cosLight := math.FastAbs(area.GetNormal().Dot(direction))
+	if cosLight < 1e-6 || area.Area() <= 0 {
+		return 0.0
+	}
+	return distance * distance / (cosLight * area.Area())
+}
+
+func powerHeuristic(pdf, otherPdf float64) float64 {
+	a := pdf * pdf
+	b := otherPdf * otherPdf
+	if a+b == 0 {
+		return 0.0
+	}
+	return a / (a + b)
+}
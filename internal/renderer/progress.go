@@ -0,0 +1,62 @@
+package renderer
+
+import (
+	"image"
+	"sync"
+)
+
+// RenderOptions configures a single RenderContext call.
+type RenderOptions struct {
+	// Progress, if set, is called after every finished tile of the beauty
+	// pass. Calls are serialized but come from the workers, so it should
+	// return quickly.
+	Progress func(RenderProgress)
+}
+
+// RenderProgress reports a finished tile. Fixed-count renders have a single
+// pass; adaptive renders report every pass, each over the whole image.
+type RenderProgress struct {
+	Pass            int
+	Tile            image.Rectangle
+	CompletedPixels int64
+	TotalPixels     int64
+}
+
+// progressTracker counts finished tiles for RenderOptions.Progress.
+type progressTracker struct {
+	mu sync.Mutex
+	report func(RenderProgress)
+	pass int
+	completed int64
+	total int64
+}
+
+func newProgressTracker(report func(RenderProgress), width, height int) *progressTracker {
+	return &progressTracker{report: report, total: int64(width * height)}
+}
+
+func (p *progressTracker) startPass(pass int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	p.pass = pass
+	p.completed = 0
+}
+
+func (p *progressTracker) tileDone(task RenderTask) {
+	if p.report == nil {
+		return
+	}
+	
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	tile := image.Rect(task.startX, task.startY, task.endX, task.endY)
+	p.completed += int64(tile.Dx() * tile.Dy())
+	p.report(RenderProgress{
+		Pass:            p.pass,
+		Tile:            tile,
+		CompletedPixels: p.completed,
+		TotalPixels:     p.total,
+	})
+}
@@ -0,0 +1,43 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"raytraceGo/internal/optimization"
+	"raytraceGo/internal/output"
+	"raytraceGo/internal/scene"
+)
+
+// RenderRegion renders only the pixels of region, part of a width x height
+// image, into a framebuffer the size of the region. The pixels are the same
+// as those of a full render with the same settings, so regions rendered
+// separately, for example on other machines, assemble into the full image.
+// Stages that need the whole image are left to the caller: adaptive sampling
+// is not supported, and no AOVs, denoising or post-processing are applied.
+func (r *ParallelRenderer) RenderRegion(ctx context.Context, scene *scene.Scene, width, height int, region image.Rectangle) (*output.Framebuffer, error) {
+	if region.Empty() || !region.In(image.Rect(0, 0, width, height)) {
+		return nil, fmt.Errorf("region %v is not within the %dx%d image", region, width, height)
+	}
+	if r.adaptive {
+		return nil, fmt.Errorf("adaptive sampling needs the whole image and cannot render a region")
+	}
+	
+	camera, err := r.setupCamera(scene.Camera, width, height)
+	if err != nil {
+		return nil, err
+	}
+	world := optimization.NewWorld(scene.GetHittables())
+	lights := scene.GetLights()
+	sky := scene.GetAtmosphere()
+	
+	estimates := make([]pixelEstimate, region.Dx()*region.Dy())
+	progress := newProgressTracker(nil, region.Dx(), region.Dy())
+	r.renderFixed(ctx, estimates, region, width, height, r.samples, camera, world, lights, sky, progress, nil)
+	
+	framebuffer := output.NewFramebuffer(region.Dx(), region.Dy())
+	for i := range estimates {
+		framebuffer.Set(i%region.Dx(), i/region.Dx(), estimates[i].mean(), estimates[i].alpha())
+	}
+	return framebuffer, ctx.Err()
+}
@@ -1,22 +1,37 @@
 package renderer
 
 import (
+	"context"
 	"fmt"
 	"image"
-	"image/color"
-	"image/png"
 	stdmath "math"
 	"os"
 	"path/filepath"
+	"raytraceGo/internal/atmosphere"
+	"raytraceGo/internal/effects"
 	"raytraceGo/internal/geometry"
+	"raytraceGo/internal/lighting"
 	"raytraceGo/internal/math"
 	"raytraceGo/internal/material"
+	"raytraceGo/internal/optimization"
+	"raytraceGo/internal/output"
+	"raytraceGo/internal/sampling"
 	"raytraceGo/internal/scene"
+	"raytraceGo/internal/tonemap"
 	"sync"
 	"time"
 	"encoding/json"
 )
 
+const defaultAperture = 0.2
+
+// Integrators select how radiance is computed. The artistic integrator is the
+// original hand-tuned shading; the path integrator is physically based.
+const (
+	IntegratorArtistic = "artistic"
+	IntegratorPath     = "path"
+)
+
 type ParallelRenderer struct {
 	numWorkers int
 	maxDepth   int
@@ -25,6 +40,21 @@ type ParallelRenderer struct {
 	recursiveReflections bool
 	softShadows bool
 	depthOfField bool
+	integrator string
+	seed uint64
+	sampler string
+	adaptive bool
+	minSamples int
+	maxSamples int
+	noiseThreshold float64
+	heatmap *image.RGBA
+	aovs []string
+	denoise bool
+	rawFramebuffer *output.Framebuffer
+	toneMapper *tonemap.Mapper
+	checkpointPath string
+	checkpointInterval time.Duration
+	resume *checkpoint
 	benchmarkData *BenchmarkData
 }
 
@@ -33,6 +63,7 @@ type BenchmarkData struct {
 	Resolution    string    `json:"resolution"`
 	RenderTime    float64   `json:"render_time_seconds"`
 	Samples       int       `json:"samples"`
+	AverageSamples float64  `json:"average_samples,omitempty"`
 	MaxDepth      int       `json:"max_depth"`
 	NumWorkers    int       `json:"num_workers"`
 	Objects       int       `json:"objects"`
@@ -48,7 +79,7 @@ type RenderResult struct {
 
 type Pixel struct {
 	x, y int
-	color math.Vec3
+	estimate pixelEstimate
 }
 
 func NewParallelRenderer(numWorkers int) *ParallelRenderer {
@@ -60,50 +91,114 @@ func NewParallelRenderer(numWorkers int) *ParallelRenderer {
 		recursiveReflections: true,
 		softShadows:          true,
 		depthOfField:         false,
+		integrator:           IntegratorArtistic,
+		sampler:              sampling.Independent,
+		toneMapper:           tonemap.Default(),
 		benchmarkData:        &BenchmarkData{},
 	}
 }
 
-func (r *ParallelRenderer) Render(scene *scene.Scene, width, height int) *image.RGBA {
-	startTime := time.Now()
+// Render renders the scene and tone maps it for display.
+func (r *ParallelRenderer) Render(scene *scene.Scene, width, height int) (*image.RGBA, error) {
+	framebuffer, err := r.RenderHDR(scene, width, height)
+	if err != nil {
+		return nil, err
+	}
+	return r.ToneMap(framebuffer), nil
+}
+
+// RenderHDR renders the scene into a linear floating-point framebuffer,
+// denoised if enabled, with the scene's post-process chain applied. It fails
+// if the render cannot start, such as when resuming a checkpoint of another
+// scene.
+func (r *ParallelRenderer) RenderHDR(scene *scene.Scene, width, height int) (*output.Framebuffer, error) {
+	framebuffer, err := r.RenderContext(context.Background(), scene, width, height, RenderOptions{})
+	if err != nil {
+		return nil, err
+	}
 	
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fmt.Printf("Rendering complete!\n")
+	fmt.Printf("Enhanced materials features:\n")
+	for _, feature := range r.benchmarkData.Features {
+		fmt.Printf("- %s\n", feature)
+	}
 	
-	camera := r.setupCamera(scene.Camera, width, height)
-	hittables := scene.GetHittables()
-	lights := scene.GetLights()
+	return framebuffer, nil
+}
+
+// RenderContext is RenderHDR that can be cancelled through ctx and reports
+// its progress through opts. When ctx is cancelled the workers stop within a
+// scanline and RenderContext returns the partial image with ctx's error;
+// pixels that were not rendered are black with zero alpha, and later stages
+// such as AOVs and post-processing are skipped. With checkpointing enabled
+// the samples rendered so far are saved first, to be continued by Resume.
+func (r *ParallelRenderer) RenderContext(ctx context.Context, scene *scene.Scene, width, height int, opts RenderOptions) (*output.Framebuffer, error) {
+	startTime := time.Now()
 	
-	tasks := r.createRenderTasks(width, height, scene, camera)
-	results := make(chan RenderResult, r.numWorkers*2)
+	estimates, nextSample, err := r.startEstimates(scene, width, height)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := r.newCheckpointer(scene, width, height)
 	
-	var wg sync.WaitGroup
+	framebuffer := output.NewFramebuffer(width, height)
 	
-	for i := 0; i < r.numWorkers; i++ {
-		wg.Add(1)
-		go r.worker(&wg, tasks, results, hittables, lights)
+	camera, err := r.setupCamera(scene.Camera, width, height)
+	if err != nil {
+		return nil, err
+	}
+	hittables := scene.GetHittables()
+	layerNames := r.layerNames()
+	if len(layerNames) > 0 {
+		hittables = tagObjects(hittables)
+	}
+	world := optimization.NewWorld(hittables)
+	lights := scene.GetLights()
+	sky := scene.GetAtmosphere()
+	
+	r.heatmap = nil
+	r.rawFramebuffer = nil
+	r.benchmarkData.AverageSamples = 0
+	progress := newProgressTracker(opts.Progress, width, height)
+	if r.adaptive {
+		r.renderAdaptive(ctx, estimates, nextSample, width, height, camera, world, lights, sky, progress, checkpoint)
+	} else {
+		r.renderFixed(ctx, estimates, image.Rect(0, 0, width, height), width, height, r.samples, camera, world, lights, sky, progress, checkpoint)
+	}
+	for i := range estimates {
+		framebuffer.Set(i%width, i/width, estimates[i].mean(), estimates[i].alpha())
+	}
+	if err := ctx.Err(); err != nil {
+		return framebuffer, err
 	}
 	
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+	var layers []*output.Layer
+	if len(layerNames) > 0 {
+		layers = r.renderAOVs(ctx, layerNames, width, height, camera, world)
+		if err := ctx.Err(); err != nil {
+			return framebuffer, err
+		}
+		framebuffer.Layers = r.requestedLayers(layers)
+	}
 	
-	resultCount := 0
-	for result := range results {
-		for _, pixel := range result.pixels {
-			mappedColor := r.toneMap(pixel.color)
-			r, g, b := mappedColor.ToRGB()
-			img.Set(pixel.x, pixel.y, color.RGBA{uint8(r), uint8(g), uint8(b), 255})
+	postProcess := scene.GetPostProcess()
+	if r.denoise {
+		raw := framebuffer.Clone()
+		raw.Layers = nil
+		if err := r.denoiseFramebuffer(ctx, framebuffer, layers, camera); err != nil {
+			return raw, err
 		}
-		resultCount++
+		effects.ApplyPostProcess(raw, postProcess)
+		r.rawFramebuffer = raw
 	}
+	effects.ApplyPostProcess(framebuffer, postProcess)
 	
 	renderTime := time.Since(startTime).Seconds()
 	
 	r.benchmarkData.SceneName = scene.GetSceneName()
 	r.benchmarkData.Resolution = fmt.Sprintf("%dx%d", width, height)
 	r.benchmarkData.RenderTime = renderTime
-	r.benchmarkData.Samples = r.samples
+	r.benchmarkData.Samples = r.samplesPerPixel()
 	r.benchmarkData.MaxDepth = r.maxDepth
 	r.benchmarkData.NumWorkers = r.numWorkers
 	r.benchmarkData.Objects = len(hittables)
@@ -116,76 +211,167 @@ func (r *ParallelRenderer) Render(scene *scene.Scene, width, height int) *image.
 		"Better specular highlights for metallic surfaces",
 	}
 	
-	fmt.Printf("Rendering complete!\n")
-	fmt.Printf("Enhanced materials features:\n")
-	for _, feature := range r.benchmarkData.Features {
-		fmt.Printf("- %s\n", feature)
+	return framebuffer, nil
+}
+
+// ToneMap converts a framebuffer to an 8-bit sRGB image with the renderer's
+// display transform.
+func (r *ParallelRenderer) ToneMap(framebuffer *output.Framebuffer) *image.RGBA {
+	return output.ToneMap(framebuffer, r.toneMapper)
+}
+
+// renderFixed brings every pixel of region, part of a width x height image,
+// up to count samples; estimates cover the region.
+// Workers start from a copy of their pixels' estimates and only this
+// goroutine writes the results back, so checkpoints taken between results
+// see every pixel either before or after its tile.
+func (r *ParallelRenderer) renderFixed(ctx context.Context, estimates []pixelEstimate, region image.Rectangle, width, height, count int, camera Camera, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, progress *progressTracker, checkpoint *checkpointer) {
+	tasks := r.createRegionTasks(ctx, region, width, height, camera)
+	results := make(chan RenderResult, r.numWorkers*2)
+	
+	var wg sync.WaitGroup
+	
+	for i := 0; i < r.numWorkers; i++ {
+		wg.Add(1)
+		go r.worker(ctx, &wg, tasks, results, estimates, count, world, lights, sky, progress)
 	}
 	
-	return img
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	
+	for result := range results {
+		for _, pixel := range result.pixels {
+			estimates[(pixel.y-region.Min.Y)*region.Dx()+pixel.x-region.Min.X] = pixel.estimate
+		}
+		checkpoint.saveEvery(estimates, 0)
+	}
+	checkpoint.save(estimates, 0)
 }
 
-func (r *ParallelRenderer) worker(wg *sync.WaitGroup, tasks chan RenderTask, results chan RenderResult, hittables []geometry.Hittable, lights []scene.Light) {
+func (r *ParallelRenderer) worker(ctx context.Context, wg *sync.WaitGroup, tasks chan RenderTask, results chan RenderResult, estimates []pixelEstimate, count int, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, progress *progressTracker) {
 	defer wg.Done()
 	
+	sampler := r.newSampler()
 	for task := range tasks {
-		pixels := r.renderTile(task, hittables, lights)
+		pixels, complete := r.renderTile(ctx, task, estimates, count, world, lights, sky, sampler)
 		results <- RenderResult{pixels: pixels, startX: task.startX, startY: task.startY}
+		if complete {
+			progress.tileDone(task)
+		}
 	}
 }
 
-func (r *ParallelRenderer) renderTile(task RenderTask, hittables []geometry.Hittable, lights []scene.Light) []Pixel {
+// renderTile brings the tile's pixels up to count samples, a scanline at a
+// time until ctx is cancelled, and reports whether it finished them all.
+func (r *ParallelRenderer) renderTile(ctx context.Context, task RenderTask, estimates []pixelEstimate, count int, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, sampler sampling.Sampler) ([]Pixel, bool) {
 	var pixels []Pixel
 	
 	for y := task.startY; y < task.endY; y++ {
+		if ctx.Err() != nil {
+			return pixels, false
+		}
 		for x := task.startX; x < task.endX; x++ {
-			color := r.tracePixel(x, y, task.width, task.height, task.camera, hittables, lights)
-			pixels = append(pixels, Pixel{x: x, y: y, color: color})
+			estimate := estimates[task.pixelIndex(x, y)]
+			r.accumulatePixel(&estimate, x, y, count, task, world, lights, sky, sampler)
+			pixels = append(pixels, Pixel{x: x, y: y, estimate: estimate})
 		}
 	}
 	
-	return pixels
+	return pixels, true
 }
 
-func (r *ParallelRenderer) tracePixel(x, y, width, height int, camera *scene.Camera, hittables []geometry.Hittable, lights []scene.Light) math.Vec3 {
-	color := math.Vec3{}
-	samples := r.samples
-	
-	for s := 0; s < samples; s++ {
-		u := (float64(x) + math.RandomFloat()) / float64(width)
-		v := (float64(y) + math.RandomFloat()) / float64(height)
-		
-		ray := r.getRay(u, v, camera)
-		color = color.Add(r.traceRay(ray, hittables, lights, 0))
+// newSampler returns a sampler for one worker; samplers keep per-sample
+// state and must not be shared between goroutines.
+func (r *ParallelRenderer) newSampler() sampling.Sampler {
+	sampler, err := sampling.New(r.sampler, r.samplesPerPixel(), r.seed)
+	if err != nil {
+		return sampling.NewIndependentSampler(r.seed)
+	}
+	return sampler
+}
+
+// accumulatePixel adds samples to the pixel's estimate until it has count,
+// continuing from the samples it already has. It restarts the sampler for
+// every sample. Samplers derive their values from the seed, the pixel and
+// the sample index, so the result does not depend on which worker renders
+// the pixel, in what order, or whether the render was resumed in between.
+func (r *ParallelRenderer) accumulatePixel(estimate *pixelEstimate, x, y, count int, task RenderTask, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, sampler sampling.Sampler) {
+	for s := estimate.samples; s < count; s++ {
+		estimate.add(r.samplePixel(x, y, s, task.width, task.height, task.camera, world, lights, sky, sampler))
 	}
+}
+
+// samplePixel returns the radiance of sample s of the pixel, or false where
+// the camera has no ray.
+func (r *ParallelRenderer) samplePixel(x, y, s, width, height int, camera Camera, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, sampler sampling.Sampler) (math.Vec3, bool) {
+	ray, ok := r.cameraRay(x, y, s, width, height, camera, sampler)
+	if !ok {
+		return math.Vec3{}, false
+	}
+	return r.radiance(ray, world, lights, sky, sampler), true
+}
+
+// cameraRay starts sample s of the pixel and returns its camera ray.
+func (r *ParallelRenderer) cameraRay(x, y, s, width, height int, camera Camera, sampler sampling.Sampler) (geometry.Ray, bool) {
+	sampler.StartPixelSample(x, y, s)
+	
+	pixelU, pixelV := sampler.Get2D()
+	u := (float64(x) + pixelU) / float64(width)
+	v := 1.0 - (float64(y)+pixelV)/float64(height)
 	
-	return color.DivScalar(float64(samples))
+	lensU, lensV := sampler.Get2D()
+	return camera.GetRay(u, v, lensU, lensV)
+}
+
+// samplesPerPixel is the most samples any pixel receives.
+func (r *ParallelRenderer) samplesPerPixel() int {
+	if r.adaptive {
+		return r.maxSamples
+	}
+	return r.samples
+}
+
+func (r *ParallelRenderer) radiance(ray geometry.Ray, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, sampler sampling.Sampler) math.Vec3 {
+	if r.integrator == IntegratorPath {
+		return r.tracePath(ray, world, lights, sky, pathVertex{throughput: math.Vec3{X: 1, Y: 1, Z: 1}, specular: true}, sampler)
+	}
+	return r.traceRay(ray, world, lights, sky, 0, sampler.Random())
 }
 
-func (r *ParallelRenderer) traceRay(ray geometry.Ray, hittables []geometry.Hittable, lights []scene.Light, depth int) math.Vec3 {
+func (r *ParallelRenderer) traceRay(ray geometry.Ray, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, depth int, rng *math.FastRandom) math.Vec3 {
 	if depth >= r.maxDepth {
 		return math.Vec3{}
 	}
 	
-	hitRecord, hit := r.hitWorld(ray, hittables, 0.001, stdmath.Inf(1))
+	hitRecord, hit := r.hitWorld(ray, world, 0.001, stdmath.Inf(1))
 	if !hit {
-		return math.Vec3{X: 0.0, Y: 0.0, Z: 0.0}
+		return r.skyColor(ray, sky)
 	}
 	
+	color := r.shadeHit(ray, hitRecord, world, lights, sky, depth, rng)
+	if sky != nil {
+		color = sky.ApplyAerialPerspective(color, hitRecord.T*ray.Direction.Length())
+	}
+	return color
+}
+
+func (r *ParallelRenderer) shadeHit(ray geometry.Ray, hitRecord *geometry.HitRecord, world geometry.Hittable, lights []lighting.Light, sky *atmosphere.AtmosphereConfig, depth int, rng *math.FastRandom) math.Vec3 {
 	material := hitRecord.Material.(material.Material)
 	
 	emitted := material.Emitted()
 	
-	directLighting := r.calculateDirectLighting(hitRecord, hittables, lights)
+	directLighting := r.calculateDirectLighting(hitRecord, world, lights, rng)
 	
-	scattered, attenuation, scatteredHit := material.Scatter(ray, hitRecord)
+	scattered, attenuation, scatteredHit := material.Scatter(ray, hitRecord, rng)
 	if !scatteredHit {
 		return emitted.Add(directLighting)
 	}
 	
 	reflectedColor := math.Vec3{}
 	if r.recursiveReflections {
-		reflectedColor = r.traceRay(scattered, hittables, lights, depth+1)
+		reflectedColor = r.traceRay(scattered, world, lights, sky, depth+1, rng)
 	}
 	
 	metallic := material.GetMetallic()
@@ -226,7 +412,7 @@ func (r *ParallelRenderer) traceRay(ray geometry.Ray, hittables []geometry.Hitta
 	return finalColor
 }
 
-func (r *ParallelRenderer) calculateDirectLighting(hit *geometry.HitRecord, hittables []geometry.Hittable, lights []scene.Light) math.Vec3 {
+func (r *ParallelRenderer) calculateDirectLighting(hit *geometry.HitRecord, world geometry.Hittable, lights []lighting.Light, rng *math.FastRandom) math.Vec3 {
 	totalLighting := math.Vec3{}
 	
 	material := hit.Material.(material.Material)
@@ -246,18 +432,21 @@ func (r *ParallelRenderer) calculateDirectLighting(hit *geometry.HitRecord, hitt
 	totalLighting = totalLighting.Add(ambientLight)
 	
 	for _, light := range lights {
-		lightDir := light.Position.Sub(hit.Point).Normalize()
-		lightDistance := light.Position.Sub(hit.Point).Length()
+		if light.GetType() != "directional" && light.GetPosition().Sub(hit.Point).Length() < 0.001 {
+			continue
+		}
 		
-		if lightDistance < 0.001 {
+		lightDir := light.GetDirection(hit.Point)
+		lightIntensity := light.GetIntensity(hit.Point)
+		if lightIntensity <= 0 {
 			continue
 		}
 		
-		shadowFactor := r.calculateSmartShadow(hit, light, hittables)
+		shadowFactor := r.calculateSmartShadow(hit, light, world, rng)
 		
 		if shadowFactor > 0.0 {
 			cosTheta := stdmath.Max(0, hit.Normal.Dot(lightDir))
-			intensity := cosTheta * light.Intensity / (lightDistance * lightDistance)
+			intensity := cosTheta * lightIntensity
 			
 			diffuseStrength := 0.25
 			if metallic > 0.95 {
@@ -272,7 +461,7 @@ func (r *ParallelRenderer) calculateDirectLighting(hit *geometry.HitRecord, hitt
 				diffuseStrength = 0.2
 			}
 			
-			diffuse := albedo.MulScalar(diffuseStrength * intensity * shadowFactor)
+			diffuse := albedo.Mul(light.GetColor()).MulScalar(diffuseStrength * intensity * shadowFactor)
 			totalLighting = totalLighting.Add(diffuse)
 			
 			if metallic > 0.5 {
@@ -287,7 +476,7 @@ func (r *ParallelRenderer) calculateDirectLighting(hit *geometry.HitRecord, hitt
 				}
 				
 				specularIntensity := stdmath.Pow(stdmath.Max(0, hit.Normal.Dot(halfDir)), specularPower)
-				specular := light.Color.MulScalar(specularIntensity * intensity * shadowFactor * metallic * 3.0)
+				specular := light.GetColor().MulScalar(specularIntensity * intensity * shadowFactor * metallic * 3.0)
 				totalLighting = totalLighting.Add(specular)
 			}
 		}
@@ -296,13 +485,30 @@ func (r *ParallelRenderer) calculateDirectLighting(hit *geometry.HitRecord, hitt
 	return totalLighting
 }
 
-func (r *ParallelRenderer) calculateSmartShadow(hit *geometry.HitRecord, light scene.Light, hittables []geometry.Hittable) float64 {
-	lightDir := light.Position.Sub(hit.Point).Normalize()
-	lightDistance := light.Position.Sub(hit.Point).Length()
+// calculateSmartShadow returns the unoccluded fraction of the light. Area
+// lights are sampled across their surface; other lights get a hard shadow
+// test, softened by jittered rays when soft shadows are enabled.
+func (r *ParallelRenderer) calculateSmartShadow(hit *geometry.HitRecord, light lighting.Light, world geometry.Hittable, rng *math.FastRandom) float64 {
+	if area, ok := light.(*lighting.AreaLight); ok {
+		samples := area.Samples
+		if samples < 1 {
+			samples = 1
+		}
+		visible := 0
+		for i := 0; i < samples; i++ {
+			lightDir, lightDistance := area.SampleShadowRay(hit.Point, rng.Float64(), rng.Float64())
+			if _, hitShadow := r.hitWorld(geometry.NewRay(hit.Point, lightDir), world, 0.001, lightDistance); !hitShadow {
+				visible++
+			}
+		}
+		return float64(visible) / float64(samples)
+	}
+	
+	lightDir, lightDistance := light.GetShadowRay(hit.Point)
 	
 	shadowRay := geometry.NewRay(hit.Point, lightDir)
 	
-	_, hitShadow := r.hitWorld(shadowRay, hittables, 0.001, lightDistance)
+	_, hitShadow := r.hitWorld(shadowRay, world, 0.001, lightDistance)
 	
 	if hitShadow {
 		return 0.0
@@ -313,11 +519,11 @@ func (r *ParallelRenderer) calculateSmartShadow(hit *geometry.HitRecord, light s
 		shadowSum := 0.0
 		
 		for i := 0; i < shadowSamples; i++ {
-			randomOffset := math.RandomVec3InUnitSphere().MulScalar(0.1)
+			randomOffset := rng.Vec3InUnitSphere().MulScalar(0.1)
 			softLightDir := lightDir.Add(randomOffset).Normalize()
 			softShadowRay := geometry.NewRay(hit.Point, softLightDir)
 			
-			_, softHit := r.hitWorld(softShadowRay, hittables, 0.001, lightDistance)
+			_, softHit := r.hitWorld(softShadowRay, world, 0.001, lightDistance)
 			
 			if !softHit {
 				shadowSum += 1.0
@@ -330,90 +536,98 @@ func (r *ParallelRenderer) calculateSmartShadow(hit *geometry.HitRecord, light s
 	return 1.0
 }
 
-func (r *ParallelRenderer) hitWorld(ray geometry.Ray, hittables []geometry.Hittable, tMin, tMax float64) (*geometry.HitRecord, bool) {
-	var closestHit *geometry.HitRecord
-	closestT := tMax
-	
-	for _, hittable := range hittables {
-		hitRecord, hit := hittable.Hit(ray, tMin, closestT)
-		if hit {
-			closestT = hitRecord.T
-			closestHit = hitRecord
-		}
-	}
-	
-	return closestHit, closestHit != nil
-}
-
-func (r *ParallelRenderer) toneMap(color math.Vec3) math.Vec3 {
-	exposure := 1.0
-	gamma := 2.2
-	
-	color = color.MulScalar(exposure)
-	
-	color.X = 1.0 - stdmath.Exp(-color.X)
-	color.Y = 1.0 - stdmath.Exp(-color.Y)
-	color.Z = 1.0 - stdmath.Exp(-color.Z)
-	
-	color.X = stdmath.Pow(color.X, 1.0/gamma)
-	color.Y = stdmath.Pow(color.Y, 1.0/gamma)
-	color.Z = stdmath.Pow(color.Z, 1.0/gamma)
-	
-	color.X = stdmath.Max(0.0, stdmath.Min(1.0, color.X))
-	color.Y = stdmath.Max(0.0, stdmath.Min(1.0, color.Y))
-	color.Z = stdmath.Max(0.0, stdmath.Min(1.0, color.Z))
-	
-	return color
+func (r *ParallelRenderer) hitWorld(ray geometry.Ray, world geometry.Hittable, tMin, tMax float64) (*geometry.HitRecord, bool) {
+	return world.Hit(ray, tMin, tMax)
 }
 
-func (r *ParallelRenderer) skyColor(ray geometry.Ray) math.Vec3 {
-	return math.Vec3{X: 0.1, Y: 0.1, Z: 0.1}
+// skyColor is the radiance of rays that leave the scene: the atmosphere's sky
+// and sun disk, or black for scenes without an atmosphere.
+func (r *ParallelRenderer) skyColor(ray geometry.Ray, sky *atmosphere.AtmosphereConfig) math.Vec3 {
+	if sky == nil {
+		return math.Vec3{}
+	}
+	return sky.GetSkyColor(ray.Direction)
 }
 
-func (r *ParallelRenderer) setupCamera(camera scene.Camera, width, height int) *scene.Camera {
-	return &camera
+// setupCamera builds the scene's camera. Depth of field fails for
+// projections without a lens, as scene validation does for an aperture.
+func (r *ParallelRenderer) setupCamera(camera scene.Camera, width, height int) (Camera, error) {
+	if r.depthOfField && !camera.HasLens() {
+		return nil, fmt.Errorf("depth of field needs a lens, which the %s projection does not have", camera.Projection)
+	}
+	aspectRatio := cameraAspectRatio(camera, width, height)
+	
+	switch camera.Projection {
+	case scene.ProjectionOrthographic:
+		orthographic := NewOrthographicCamera(camera.Position, camera.LookAt, camera.Up, orthographicViewWidth(camera, aspectRatio), aspectRatio)
+		orthographic.DepthOfField = r.setupDepthOfField(camera)
+		return orthographic, nil
+		
+	case scene.ProjectionFisheye:
+		return NewFisheyeCamera(camera.Position, camera.LookAt, camera.Up, fisheyeFOV(camera), aspectRatio), nil
+		
+	case scene.ProjectionEquirectangular:
+		return NewEquirectangularCamera(camera.Position, camera.LookAt, camera.Up), nil
+		
+	default:
+		perspective := NewPerspectiveCamera(camera.Position, camera.LookAt, camera.Up, cameraFOV(camera), aspectRatio)
+		perspective.DepthOfField = r.setupDepthOfField(camera)
+		return perspective, nil
+	}
 }
 
-func (r *ParallelRenderer) getRay(u, v float64, camera *scene.Camera) geometry.Ray {
-	viewportHeight := 2.0
-	viewportWidth := viewportHeight * float64(camera.AspectRatio)
-	focalLength := 1.0
-	
-	origin := camera.Position
-	horizontal := math.Vec3{X: viewportWidth, Y: 0, Z: 0}
-	vertical := math.Vec3{X: 0, Y: viewportHeight, Z: 0}
-	lowerLeftCorner := origin.Sub(horizontal.DivScalar(2)).Sub(vertical.DivScalar(2)).Sub(math.Vec3{X: 0, Y: 0, Z: focalLength})
-	
-	direction := lowerLeftCorner.Add(horizontal.MulScalar(u)).Add(vertical.MulScalar(v)).Sub(origin)
+func (r *ParallelRenderer) setupDepthOfField(camera scene.Camera) *effects.DepthOfField {
+	aperture := camera.Aperture
+	if aperture <= 0 {
+		if !r.depthOfField {
+			return nil
+		}
+		aperture = defaultAperture
+	}
 	
-	return geometry.NewRay(origin, direction)
+	return effects.NewDepthOfField(camera.GetFocusDistance(), aperture)
 }
 
 type RenderTask struct {
 	startX, startY, endX, endY int
 	width, height               int
-	camera                      *scene.Camera
+	camera                      Camera
+	// region is the part of the image being rendered, which the task's
+	// buffers cover.
+	region image.Rectangle
+}
+
+// pixelIndex is the index of pixel (x, y) in buffers covering the region.
+func (t RenderTask) pixelIndex(x, y int) int {
+	return (y-t.region.Min.Y)*t.region.Dx() + x - t.region.Min.X
 }
 
-func (r *ParallelRenderer) createRenderTasks(width, height int, scene *scene.Scene, camera *scene.Camera) chan RenderTask {
+// createRenderTasks queues the image's tiles for the workers. It stops
+// queueing and closes the channel once ctx is cancelled.
+func (r *ParallelRenderer) createRenderTasks(ctx context.Context, width, height int, camera Camera) chan RenderTask {
+	return r.createRegionTasks(ctx, image.Rect(0, 0, width, height), width, height, camera)
+}
+
+// createRegionTasks queues the tiles of region, part of a width x height image.
+func (r *ParallelRenderer) createRegionTasks(ctx context.Context, region image.Rectangle, width, height int, camera Camera) chan RenderTask {
 	tasks := make(chan RenderTask, r.numWorkers*4)
 	
 	tileSize := 32
-	numTilesX := (width + tileSize - 1) / tileSize
-	numTilesY := (height + tileSize - 1) / tileSize
+	numTilesX := (region.Dx() + tileSize - 1) / tileSize
+	numTilesY := (region.Dy() + tileSize - 1) / tileSize
 	
 	go func() {
 		for y := 0; y < numTilesY; y++ {
 			for x := 0; x < numTilesX; x++ {
-				startX := x * tileSize
-				startY := y * tileSize
+				startX := region.Min.X + x*tileSize
+				startY := region.Min.Y + y*tileSize
 				endX := startX + tileSize
-				if endX > width {
-					endX = width
+				if endX > region.Max.X {
+					endX = region.Max.X
 				}
 				endY := startY + tileSize
-				if endY > height {
-					endY = height
+				if endY > region.Max.Y {
+					endY = region.Max.Y
 				}
 				
 				task := RenderTask{
@@ -424,9 +638,15 @@ func (r *ParallelRenderer) createRenderTasks(width, height int, scene *scene.Sce
 					width:   width,
 					height:  height,
 					camera:  camera,
+					region:  region,
 				}
 				
-				tasks <- task
+				select {
+				case tasks <- task:
+				case <-ctx.Done():
+					close(tasks)
+					return
+				}
 			}
 		}
 		close(tasks)
@@ -435,19 +655,27 @@ func (r *ParallelRenderer) createRenderTasks(width, height int, scene *scene.Sce
 	return tasks
 }
 
-func (r *ParallelRenderer) SaveImage(img *image.RGBA, filename string) error {
-	dir := filepath.Dir(filename)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
+// SaveFramebuffer writes a render in the format given by the file extension:
+// floating-point formats keep the linear radiance, others get the tone-mapped
+// image. AOV layers go into EXR files, and next to other formats as files
+// named by output.LayerFilename.
+func (r *ParallelRenderer) SaveFramebuffer(framebuffer *output.Framebuffer, filename string) error {
+	if !output.IsHDRFile(filename) {
+		if err := r.SaveImage(r.ToneMap(framebuffer), filename); err != nil {
+			return err
+		}
+		return output.SaveLayers(framebuffer, filename)
 	}
 	
-	file, err := os.Create(filename)
-	if err != nil {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
 		return err
 	}
-	defer file.Close()
-	
-	return png.Encode(file, img)
+	return output.SaveFramebuffer(framebuffer, filename)
+}
+
+// SaveImage writes a PPM for the .ppm extension and a PNG otherwise.
+func (r *ParallelRenderer) SaveImage(img *image.RGBA, filename string) error {
+	return output.SaveImage(img, filename)
 }
 
 func (r *ParallelRenderer) PrintASCIIPreview(img *image.RGBA) {
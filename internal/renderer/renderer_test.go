@@ -0,0 +1,452 @@
+package renderer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	stdmath "math"
+	"os"
+	"raytraceGo/internal/math"
+	"raytraceGo/internal/output"
+	"raytraceGo/internal/sampling"
+	"raytraceGo/internal/scene"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testScene = `{
+	"camera": {"position": [0, 1, -4], "lookAt": [0, 0.5, 0], "fov": 50, "aperture": 0.1},
+	"objects": [
+		{"type": "plane", "position": [0, 0, 0], "normal": [0, 1, 0], "material": {"type": "lambertian", "color": [0.7, 0.7, 0.7]}},
+		{"type": "sphere", "position": [-0.6, 0.5, 0], "radius": 0.5, "material": {"type": "metal", "color": [0.9, 0.8, 0.6], "roughness": 0.3}},
+		{"type": "sphere", "position": [0.6, 0.5, 0], "radius": 0.5, "material": {"type": "dielectric", "refractionIndex": 1.5}}
+	],
+	"lights": [
+		{"type": "area", "position": [0, 3, 0], "u": [1, 0, 0], "v": [0, 0, 1], "color": [1, 1, 1], "intensity": 10}
+	],
+	"atmosphere": "default"
+}`
+
+func renderTestScene(t *testing.T, workers int, integrator string, seed uint64) []byte {
+	t.Helper()
+	
+	s, err := scene.Parse([]byte(testScene))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	
+	r := NewParallelRenderer(workers)
+	r.SetSamples(4)
+	r.SetSeed(seed)
+	if err := r.SetIntegrator(integrator); err != nil {
+		t.Fatal(err)
+	}
+	
+	img, err := r.Render(s, 40, 30)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	return img.Pix
+}
+
+func TestRenderIsDeterministic(t *testing.T) {
+	for _, integrator := range []string{IntegratorArtistic, IntegratorPath} {
+		single := renderTestScene(t, 1, integrator, 7)
+		parallel := renderTestScene(t, 5, integrator, 7)
+		if !bytes.Equal(single, parallel) {
+			t.Errorf("%s render with 1 and 5 workers differs for the same seed", integrator)
+		}
+		
+		if other := renderTestScene(t, 5, integrator, 8); bytes.Equal(single, other) {
+			t.Errorf("%s render is identical for seeds 7 and 8", integrator)
+		}
+	}
+}
+
+func TestAdaptiveSampling(t *testing.T) {
+	s, err := scene.Parse([]byte(testScene))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	
+	render := func(workers int) ([]byte, *ParallelRenderer) {
+		r := NewParallelRenderer(workers)
+		r.SetSeed(3)
+		if err := r.SetAdaptiveSampling(4, 64, 0.05); err != nil {
+			t.Fatal(err)
+		}
+		img, err := r.Render(s, 40, 30)
+		if err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		return img.Pix, r
+	}
+	
+	single, r := render(1)
+	parallel, _ := render(4)
+	if !bytes.Equal(single, parallel) {
+		t.Errorf("adaptive render with 1 and 4 workers differs for the same seed")
+	}
+	
+	if heatmap := r.GetSampleHeatmap(); heatmap == nil || heatmap.Bounds().Dx() != 40 || heatmap.Bounds().Dy() != 30 {
+		t.Errorf("GetSampleHeatmap failed: got %v, want a 40x30 image", heatmap)
+	}
+	
+	average := r.benchmarkData.AverageSamples
+	if average <= 4 || average >= 64 {
+		t.Errorf("AverageSamples failed: got %v, want strictly between 4 and 64", average)
+	}
+}
+
+func TestPixelEstimateError(t *testing.T) {
+	var flat pixelEstimate
+	for i := 0; i < 8; i++ {
+		flat.add(math.Vec3{X: 0.5, Y: 0.5, Z: 0.5}, true)
+	}
+	flat.updateError(0.01)
+	if flat.relativeError != 0 {
+		t.Errorf("relativeError of constant samples failed: got %v, want 0", flat.relativeError)
+	}
+	
+	var noisy pixelEstimate
+	for i := 0; i < 8; i++ {
+		noisy.add(math.Vec3{X: float64(i % 2), Y: float64(i % 2), Z: float64(i % 2)}, true)
+	}
+	noisy.updateError(0.01)
+	if noisy.relativeError <= 1 {
+		t.Errorf("relativeError of alternating samples failed: got %v, want > 1", noisy.relativeError)
+	}
+}
+
+func TestAOVs(t *testing.T) {
+	s, err := scene.Parse([]byte(testScene))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	
+	r := NewParallelRenderer(3)
+	r.SetSamples(2)
+	if err := r.SetAOVs([]string{"specular"}); err == nil {
+		t.Errorf("SetAOVs failed: accepted an unknown AOV")
+	}
+	if err := r.SetAOVs(output.AOVNames); err != nil {
+		t.Fatal(err)
+	}
+	
+	framebuffer, err := r.RenderHDR(s, 40, 30)
+	if err != nil {
+		t.Fatalf("RenderHDR failed: %v", err)
+	}
+	layers := make(map[string]*output.Layer)
+	for _, layer := range framebuffer.Layers {
+		layers[layer.Name] = layer
+	}
+	if len(layers) != len(output.AOVNames) {
+		t.Fatalf("Layers failed: got %d, want %d", len(layers), len(output.AOVNames))
+	}
+	
+	// The bottom rows see only the ground plane, the first object.
+	x, y := 20, 29
+	if id := layers[output.AOVObjectID].At(x, y).X; id != 1 {
+		t.Errorf("objectID of the ground failed: got %v, want 1", id)
+	}
+	if normal := layers[output.AOVNormal].At(x, y); normal.Sub(math.Vec3{X: 0, Y: 1, Z: 0}).Length() > 1e-3 {
+		t.Errorf("normal of the ground failed: got %v, want (0, 1, 0)", normal)
+	}
+	if albedo := layers[output.AOVAlbedo].At(x, y); albedo.Sub(math.Vec3{X: 0.7, Y: 0.7, Z: 0.7}).Length() > 1e-6 {
+		t.Errorf("albedo of the ground failed: got %v, want (0.7, 0.7, 0.7)", albedo)
+	}
+	if depth := layers[output.AOVDepth].At(x, y).X; depth <= 0 {
+		t.Errorf("depth of the ground failed: got %v, want > 0", depth)
+	}
+	if position := layers[output.AOVPosition].At(x, y); stdmath.Abs(position.Y) > 1e-6 {
+		t.Errorf("position of the ground failed: got %v, want y = 0", position)
+	}
+	
+	groundMaterial := layers[output.AOVMaterialID].At(x, y).X
+	sphereMaterials := make(map[float64]bool)
+	for py := 0; py < 30; py++ {
+		for px := 0; px < 40; px++ {
+			if id := layers[output.AOVObjectID].At(px, py).X; id > 1 {
+				sphereMaterials[layers[output.AOVMaterialID].At(px, py).X] = true
+			}
+		}
+	}
+	if len(sphereMaterials) != 2 || sphereMaterials[groundMaterial] {
+		t.Errorf("materialID failed: got ground %v and spheres %v, want three distinct IDs", groundMaterial, sphereMaterials)
+	}
+}
+
+func TestDenoise(t *testing.T) {
+	const width, height = 64, 32
+	framebuffer := output.NewFramebuffer(width, height)
+	albedo, _ := output.NewAOVLayer(output.AOVAlbedo, width, height)
+	normal, _ := output.NewAOVLayer(output.AOVNormal, width, height)
+	depth, _ := output.NewAOVLayer(output.AOVDepth, width, height)
+	
+	// Two surfaces meet at x = 32: a bright one on the left and a dark one
+	// facing another way on the right, both with a checkerboard of noise.
+	level := func(x int) float64 {
+		if x < width/2 {
+			return 0.5
+		}
+		return 0.1
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			noise := 0.8
+			if (x+y)%2 == 0 {
+				noise = 1.2
+			}
+			c := level(x) * noise
+			framebuffer.Set(x, y, math.Vec3{X: c, Y: c, Z: c}, 1)
+			if x < width/2 {
+				albedo.Set(x, y, math.Vec3{X: 0.8, Y: 0.8, Z: 0.8})
+				normal.Set(x, y, math.Vec3{X: 0, Y: 1, Z: 0})
+			} else {
+				albedo.Set(x, y, math.Vec3{X: 0.2, Y: 0.2, Z: 0.2})
+				normal.Set(x, y, math.Vec3{X: 1, Y: 0, Z: 0})
+			}
+			depth.Set(x, y, math.Vec3{X: 2})
+		}
+	}
+	
+	r := NewParallelRenderer(4)
+	if err := r.denoiseFramebuffer(context.Background(), framebuffer, []*output.Layer{albedo, normal, depth}, nil); err != nil {
+		t.Fatal(err)
+	}
+	
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			want := level(x)
+			if got := framebuffer.At(x, y).X; stdmath.Abs(got-want) > 0.05*want {
+				t.Fatalf("denoised pixel (%d, %d) failed: got %v, want within 5%% of %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestRenderContext(t *testing.T) {
+	s, err := scene.Parse([]byte(testScene))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	
+	r := NewParallelRenderer(2)
+	r.SetSamples(2)
+	
+	var last RenderProgress
+	framebuffer, err := r.RenderContext(context.Background(), s, 80, 60, RenderOptions{
+		Progress: func(p RenderProgress) { last = p },
+	})
+	if err != nil {
+		t.Fatalf("RenderContext failed: %v", err)
+	}
+	if last.CompletedPixels != 80*60 || last.TotalPixels != 80*60 {
+		t.Errorf("Progress failed: got %d of %d pixels, want %d of %d", last.CompletedPixels, last.TotalPixels, 80*60, 80*60)
+	}
+	
+	// Cancelling after the first tile leaves most of the image unrendered.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	framebuffer, err = r.RenderContext(ctx, s, 80, 60, RenderOptions{
+		Progress: func(RenderProgress) { cancel() },
+	})
+	if err != context.Canceled {
+		t.Fatalf("RenderContext after cancel failed: got error %v, want %v", err, context.Canceled)
+	}
+	rendered := 0
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 80; x++ {
+			if framebuffer.Alpha(x, y) > 0 {
+				rendered++
+			}
+		}
+	}
+	if rendered == 0 || rendered == 80*60 {
+		t.Errorf("partial image failed: got %d rendered pixels, want some but not all", rendered)
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	s, err := scene.Parse([]byte(testScene))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	
+	newRenderer := func(adaptive bool, samples int) *ParallelRenderer {
+		r := NewParallelRenderer(3)
+		r.SetSeed(5)
+		r.SetSamples(samples)
+		if adaptive {
+			if err := r.SetAdaptiveSampling(4, samples, 0.05); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return r
+	}
+	render := func(r *ParallelRenderer, ctx context.Context, opts RenderOptions) []float32 {
+		framebuffer, err := r.RenderContext(ctx, s, 64, 48, opts)
+		if err != nil && err != context.Canceled {
+			t.Fatalf("RenderContext failed: %v", err)
+		}
+		return framebuffer.Pix
+	}
+	equal := func(a, b []float32) bool {
+		for i := range a {
+			if stdmath.Float32bits(a[i]) != stdmath.Float32bits(b[i]) {
+				return false
+			}
+		}
+		return len(a) == len(b)
+	}
+	
+	for _, adaptive := range []bool{false, true} {
+		path := t.TempDir() + "/render.checkpoint"
+		want := render(newRenderer(adaptive, 36), context.Background(), RenderOptions{})
+		
+		// Interrupt after the first tile, then resume from the checkpoint.
+		ctx, cancel := context.WithCancel(context.Background())
+		r := newRenderer(adaptive, 36)
+		r.SetCheckpoint(path, time.Hour)
+		render(r, ctx, RenderOptions{Progress: func(RenderProgress) { cancel() }})
+		cancel()
+		
+		resumed := NewParallelRenderer(2)
+		if err := resumed.Resume(path, 0); err != nil {
+			t.Fatalf("Resume failed: %v", err)
+		}
+		if got := render(resumed, context.Background(), RenderOptions{}); !equal(got, want) {
+			t.Errorf("resumed render (adaptive %v) differs from an uninterrupted one", adaptive)
+		}
+	}
+	
+	// A finished render resumed with more samples matches one rendered with
+	// them from the start.
+	path := t.TempDir() + "/render.checkpoint"
+	r := newRenderer(false, 4)
+	r.SetCheckpoint(path, time.Hour)
+	render(r, context.Background(), RenderOptions{})
+	
+	resumed := NewParallelRenderer(2)
+	if err := resumed.Resume(path, 2); err == nil {
+		t.Errorf("Resume failed: accepted fewer samples than the checkpoint has")
+	}
+	if err := resumed.Resume(path, 9); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if got, want := render(resumed, context.Background(), RenderOptions{}), render(newRenderer(false, 9), context.Background(), RenderOptions{}); !equal(got, want) {
+		t.Errorf("render resumed with more samples differs from an uninterrupted one")
+	}
+	
+	// Adding samples is refused where the result would differ from an
+	// uninterrupted render: with the stratified sampler, and for adaptive
+	// renders whose last pass was cut short of a whole batch.
+	stratified := newRenderer(false, 4)
+	if err := stratified.SetSampler(sampling.Stratified); err != nil {
+		t.Fatal(err)
+	}
+	partial := newRenderer(true, 30)
+	for _, r := range []*ParallelRenderer{stratified, partial} {
+		path := t.TempDir() + "/render.checkpoint"
+		r.SetCheckpoint(path, time.Hour)
+		render(r, context.Background(), RenderOptions{})
+		if err := NewParallelRenderer(2).Resume(path, 0); err != nil {
+			t.Fatalf("Resume failed: %v", err)
+		}
+		if err := NewParallelRenderer(2).Resume(path, 64); err == nil {
+			t.Errorf("Resume failed: added samples to a checkpoint where they would not match an uninterrupted render")
+		}
+	}
+	
+	// A checkpoint of another image size fails the render instead of
+	// returning no image.
+	mismatched := NewParallelRenderer(2)
+	if err := mismatched.Resume(path, 0); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if img, err := mismatched.Render(s, 32, 32); err == nil || img != nil {
+		t.Errorf("Render failed: got %v, want an error for a checkpoint of another size", err)
+	}
+	
+	// Truncated and corrupt checkpoints are rejected before their pixels are
+	// allocated.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huge := bytes.Clone(data)
+	binary.LittleEndian.PutUint32(huge[len(checkpointMagic):], 1<<31)
+	for name, corrupt := range map[string][]byte{
+		"truncated":   data[:len(data)-1],
+		"huge header": huge,
+		"huge size":   bytes.Replace(data, []byte(`"width":64`), []byte(`"width":1048576`), 1),
+	} {
+		if err := os.WriteFile(path, corrupt, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := NewParallelRenderer(2).Resume(path, 0); err == nil {
+			t.Errorf("Resume failed: accepted a %s checkpoint", name)
+		}
+	}
+}
+
+func TestRenderSamples(t *testing.T) {
+	s, err := scene.Parse([]byte(testScene))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	
+	r := NewParallelRenderer(3)
+	r.SetSeed(3)
+	r.SetSamples(6)
+	want, err := r.RenderContext(context.Background(), s, 40, 30, RenderOptions{})
+	if err != nil {
+		t.Fatalf("RenderContext failed: %v", err)
+	}
+	
+	// Ranges merged in any order give the full render.
+	merged := NewSampleBuffer(40, 30)
+	for _, samples := range [][2]int{{4, 2}, {0, 1}, {1, 3}} {
+		buffer, err := r.RenderSamples(context.Background(), s, 40, 30, samples[0], samples[1])
+		if err != nil {
+			t.Fatalf("RenderSamples failed: %v", err)
+		}
+		if err := merged.Merge(buffer); err != nil {
+			t.Fatalf("Merge failed: %v", err)
+		}
+	}
+	got := merged.Framebuffer()
+	for i := range want.Pix {
+		if d := stdmath.Abs(float64(got.Pix[i] - want.Pix[i])); d > 1e-5*stdmath.Max(1, float64(want.Pix[i])) {
+			t.Fatalf("merged sample ranges failed: got %v, want %v at %d", got.Pix[i], want.Pix[i], i)
+		}
+	}
+	
+	if _, err := r.RenderSamples(context.Background(), s, 40, 30, 4, 3); err == nil {
+		t.Errorf("RenderSamples failed: accepted a range beyond the samples per pixel")
+	}
+	if err := merged.Merge(NewSampleBuffer(30, 40)); err == nil {
+		t.Errorf("Merge failed: accepted a buffer of another size")
+	}
+}
+
+func TestDepthOfFieldNeedsLens(t *testing.T) {
+	for _, projection := range []string{scene.ProjectionFisheye, scene.ProjectionEquirectangular} {
+		s, err := scene.Parse([]byte(strings.Replace(testScene, `"aperture": 0.1`, `"projection": "`+projection+`"`, 1)))
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		
+		r := NewParallelRenderer(2)
+		r.SetSamples(1)
+		if _, err := r.RenderContext(context.Background(), s, 16, 8, RenderOptions{}); err != nil {
+			t.Fatalf("RenderContext failed: %v", err)
+		}
+		r.SetDepthOfField(true)
+		if _, err := r.RenderContext(context.Background(), s, 16, 8, RenderOptions{}); err == nil {
+			t.Errorf("RenderContext failed: rendered depth of field with the %s projection", projection)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"raytraceGo/internal/math"
+	"raytraceGo/internal/optimization"
+	"raytraceGo/internal/output"
+	"raytraceGo/internal/scene"
+)
+
+// SampleBuffer accumulates a range of the samples of every pixel of an image:
+// the sum of their radiance and how many there were. Buffers of disjoint
+// sample ranges of the same render merge into the buffer of their union, so a
+// render's samples can be split between machines and combined in any order.
+type SampleBuffer struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Sum holds the red, green and blue sums of each pixel in turn.
+	Sum []float64 `json:"sum"`
+	// Samples counts each pixel's samples, and Covered those that had a
+	// camera ray.
+	Samples []uint32 `json:"samples"`
+	Covered []uint32 `json:"covered"`
+}
+
+func NewSampleBuffer(width, height int) *SampleBuffer {
+	return &SampleBuffer{
+		Width:   width,
+		Height:  height,
+		Sum:     make([]float64, width*height*3),
+		Samples: make([]uint32, width*height),
+		Covered: make([]uint32, width*height),
+	}
+}
+
+// Merge adds the samples of other, which must be the same size, to the
+// buffer.
+func (b *SampleBuffer) Merge(other *SampleBuffer) error {
+	if other.Width != b.Width || other.Height != b.Height {
+		return fmt.Errorf("cannot merge a %dx%d sample buffer into a %dx%d one", other.Width, other.Height, b.Width, b.Height)
+	}
+	for i := range b.Sum {
+		b.Sum[i] += other.Sum[i]
+	}
+	for i := range b.Samples {
+		b.Samples[i] += other.Samples[i]
+		b.Covered[i] += other.Covered[i]
+	}
+	return nil
+}
+
+// Framebuffer returns the mean of each pixel's samples. Pixels without
+// samples are black with zero alpha.
+func (b *SampleBuffer) Framebuffer() *output.Framebuffer {
+	framebuffer := output.NewFramebuffer(b.Width, b.Height)
+	for i, samples := range b.Samples {
+		if samples == 0 {
+			continue
+		}
+		n := float64(samples)
+		mean := math.Vec3{X: b.Sum[i*3] / n, Y: b.Sum[i*3+1] / n, Z: b.Sum[i*3+2] / n}
+		framebuffer.Set(i%b.Width, i/b.Width, mean, float64(b.Covered[i])/n)
+	}
+	return framebuffer
+}
+
+// RenderSamples renders samples firstSample to firstSample+count-1 of every
+// pixel of a width x height image, out of the configured samples per pixel.
+// The samples are the same as those of a full render, so the buffers of
+// ranges that together cover all samples merge into the full render, up to
+// floating-point rounding. As with RenderRegion, adaptive sampling is not
+// supported and stages that work on the finished image are left to the
+// caller. When ctx is cancelled the pixels not rendered have no samples.
+func (r *ParallelRenderer) RenderSamples(ctx context.Context, scene *scene.Scene, width, height, firstSample, count int) (*SampleBuffer, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	if firstSample < 0 || count <= 0 || firstSample+count > r.samples {
+		return nil, fmt.Errorf("samples %d to %d are not within the %d samples per pixel", firstSample, firstSample+count-1, r.samples)
+	}
+	if r.adaptive {
+		return nil, fmt.Errorf("adaptive sampling needs every sample of a pixel and cannot render a sample range")
+	}
+	
+	camera, err := r.setupCamera(scene.Camera, width, height)
+	if err != nil {
+		return nil, err
+	}
+	world := optimization.NewWorld(scene.GetHittables())
+	lights := scene.GetLights()
+	sky := scene.GetAtmosphere()
+	
+	// Starting every estimate at firstSample makes the workers render only
+	// the range.
+	estimates := make([]pixelEstimate, width*height)
+	for i := range estimates {
+		estimates[i].samples = firstSample
+	}
+	progress := newProgressTracker(nil, width, height)
+	r.renderFixed(ctx, estimates, image.Rect(0, 0, width, height), width, height, firstSample+count, camera, world, lights, sky, progress, nil)
+	
+	buffer := NewSampleBuffer(width, height)
+	for i := range estimates {
+		buffer.Sum[i*3] = estimates[i].sum.X
+		buffer.Sum[i*3+1] = estimates[i].sum.Y
+		buffer.Sum[i*3+2] = estimates[i].sum.Z
+		buffer.Samples[i] = uint32(estimates[i].samples - firstSample)
+		buffer.Covered[i] = uint32(estimates[i].covered)
+	}
+	return buffer, ctx.Err()
+}
@@ -1,9 +1,21 @@
 package renderer
 
+import (
+	"fmt"
+	"image"
+	"raytraceGo/internal/output"
+	"raytraceGo/internal/sampling"
+	"raytraceGo/internal/tonemap"
+)
+
 func (r *ParallelRenderer) SetSamples(samples int) {
 	r.samples = samples
 }
 
+func (r *ParallelRenderer) GetSamples() int {
+	return r.samples
+}
+
 func (r *ParallelRenderer) SetMaxDepth(maxDepth int) {
 	r.maxDepth = maxDepth
 }
@@ -20,10 +32,101 @@ func (r *ParallelRenderer) SetSoftShadows(softShadows bool) {
 	r.softShadows = softShadows
 }
 
+// SetDepthOfField enables depth of field, with a default lens for cameras
+// without an aperture. Renders with a projection that has no lens, fisheye or
+// equirectangular, then fail.
 func (r *ParallelRenderer) SetDepthOfField(depthOfField bool) {
 	r.depthOfField = depthOfField
 }
 
+// SetSeed selects the random streams used for sampling. Renders with the same
+// seed and settings are identical.
+func (r *ParallelRenderer) SetSeed(seed uint64) {
+	r.seed = seed
+}
+
+// SetSampler selects the sample generator by name, one of sampling.Names.
+func (r *ParallelRenderer) SetSampler(name string) error {
+	if _, err := sampling.New(name, r.samples, r.seed); err != nil {
+		return err
+	}
+	r.sampler = name
+	return nil
+}
+
+// SetAdaptiveSampling replaces the fixed sample count with adaptive sampling:
+// every pixel gets at least minSamples and at most maxSamples, and stops once
+// the 95% confidence interval of its luminance is within noiseThreshold
+// times the square root of its mean.
+func (r *ParallelRenderer) SetAdaptiveSampling(minSamples, maxSamples int, noiseThreshold float64) error {
+	if minSamples < 2 {
+		return fmt.Errorf("adaptive sampling needs at least 2 samples per pixel, got %d", minSamples)
+	}
+	if maxSamples < minSamples {
+		return fmt.Errorf("max samples %d is below min samples %d", maxSamples, minSamples)
+	}
+	if noiseThreshold <= 0 {
+		return fmt.Errorf("noise threshold must be positive, got %v", noiseThreshold)
+	}
+	
+	r.adaptive = true
+	r.minSamples = minSamples
+	r.maxSamples = maxSamples
+	r.noiseThreshold = noiseThreshold
+	return nil
+}
+
+// GetSampleHeatmap returns the per-pixel sample counts of the last adaptive
+// render as an image, or nil if the last render was not adaptive.
+func (r *ParallelRenderer) GetSampleHeatmap() *image.RGBA {
+	return r.heatmap
+}
+
+// SetAOVs selects the auxiliary passes rendered with the beauty, from
+// output.AOVNames. They are returned as layers of RenderHDR's framebuffer.
+func (r *ParallelRenderer) SetAOVs(names []string) error {
+	for _, name := range names {
+		if _, err := output.NewAOVLayer(name, 0, 0); err != nil {
+			return err
+		}
+	}
+	r.aovs = append([]string(nil), names...)
+	return nil
+}
+
+// SetDenoise enables the denoiser, which filters the beauty guided by the
+// albedo, normal and depth AOVs before post-processing. The unfiltered image
+// is kept, see GetRawFramebuffer.
+func (r *ParallelRenderer) SetDenoise(denoise bool) {
+	r.denoise = denoise
+}
+
+// GetRawFramebuffer returns the last render before denoising, or nil if the
+// denoiser was off.
+func (r *ParallelRenderer) GetRawFramebuffer() *output.Framebuffer {
+	return r.rawFramebuffer
+}
+
+// SetToneMapping configures the display transform used for 8-bit output.
+func (r *ParallelRenderer) SetToneMapping(settings tonemap.Settings) error {
+	mapper, err := tonemap.New(settings)
+	if err != nil {
+		return err
+	}
+	r.toneMapper = mapper
+	return nil
+}
+
+func (r *ParallelRenderer) SetIntegrator(integrator string) error {
+	switch integrator {
+	case IntegratorArtistic, IntegratorPath:
+		r.integrator = integrator
+		return nil
+	default:
+		return fmt.Errorf("unknown integrator %q, expected %q or %q", integrator, IntegratorArtistic, IntegratorPath)
+	}
+}
+
 func (r *ParallelRenderer) GetStats() map[string]interface{} {
 	return map[string]interface{}{
 		"workers":              r.numWorkers,
@@ -33,5 +136,14 @@ func (r *ParallelRenderer) GetStats() map[string]interface{} {
 		"recursiveReflections": r.recursiveReflections,
 		"softShadows":          r.softShadows,
 		"depthOfField":         r.depthOfField,
+		"integrator":           r.integrator,
+		"seed":                 r.seed,
+		"sampler":              r.sampler,
+		"adaptive":             r.adaptive,
+		"minSamples":           r.minSamples,
+		"maxSamples":           r.maxSamples,
+		"noiseThreshold":       r.noiseThreshold,
+		"aovs":                 r.aovs,
+		"denoise":              r.denoise,
 	}
 } 
\ No newline at end of file
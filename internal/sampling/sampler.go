@@ -0,0 +1,323 @@
+package sampling
+
+import (
+	"fmt"
+	stdmath "math"
+	"raytraceGo/internal/math"
+	"strings"
+)
+
+const (
+	Independent = "independent"
+	Stratified  = "stratified"
+	Halton      = "halton"
+	Sobol       = "sobol"
+)
+
+var Names = []string{Independent, Stratified, Halton, Sobol}
+
+// Sampler supplies the random numbers of one pixel sample at a time. After
+// StartPixelSample, successive Get1D and Get2D calls return consecutive
+// dimensions of that sample, so callers must request them in the same order
+// for every sample: pixel position, lens position, then light, BSDF and
+// Russian roulette dimensions for each bounce.
+type Sampler interface {
+	StartPixelSample(x, y, index int)
+	Get1D() float64
+	Get2D() (float64, float64)
+	// Random is an independent stream for the current sample, for code
+	// such as rejection sampling that needs an unbounded number of values.
+	Random() *math.FastRandom
+}
+
+// New returns the named sampler for images rendered with samplesPerPixel
+// samples.
+func New(name string, samplesPerPixel int, seed uint64) (Sampler, error) {
+	switch name {
+	case Independent:
+		return NewIndependentSampler(seed), nil
+	case Stratified:
+		return NewStratifiedSampler(samplesPerPixel, seed), nil
+	case Halton:
+		return NewHaltonSampler(seed), nil
+	case Sobol:
+		return NewSobolSampler(seed), nil
+	default:
+		return nil, fmt.Errorf("unknown sampler %q, expected one of %s", name, strings.Join(Names, ", "))
+	}
+}
+
+// pixelSample is the state every sampler keeps: the current pixel sample,
+// the next dimension to hand out and the sample's random stream.
+type pixelSample struct {
+	seed      uint64
+	x, y      int
+	index     int
+	dimension int
+	rng       math.FastRandom
+}
+
+func (p *pixelSample) StartPixelSample(x, y, index int) {
+	p.x, p.y, p.index = x, y, index
+	p.dimension = 0
+	p.rng.Seed(math.DeriveSeed(p.seed, uint64(x), uint64(y), uint64(index)))
+}
+
+func (p *pixelSample) Random() *math.FastRandom {
+	return &p.rng
+}
+
+// hash gives a value that is fixed for the pixel and dimension but varies
+// between them, used to decorrelate dimensions and neighbouring pixels.
+func (p *pixelSample) hash(dimension int) uint32 {
+	return uint32(math.DeriveSeed(p.seed, uint64(p.x), uint64(p.y), uint64(dimension)))
+}
+
+// IndependentSampler draws every dimension uniformly at random.
+type IndependentSampler struct {
+	pixelSample
+}
+
+func NewIndependentSampler(seed uint64) *IndependentSampler {
+	return &IndependentSampler{pixelSample{seed: seed}}
+}
+
+func (s *IndependentSampler) Get1D() float64 {
+	return s.rng.Float64()
+}
+
+func (s *IndependentSampler) Get2D() (float64, float64) {
+	return s.rng.Float64(), s.rng.Float64()
+}
+
+// StratifiedSampler splits every dimension into one stratum per pixel sample
+// (a grid of them in 2D) and jitters within the stratum. Each dimension
+// visits the strata in its own pseudo-random order so that dimensions do not
+// correlate.
+type StratifiedSampler struct {
+	pixelSample
+	samplesPerPixel int
+	gridX, gridY    int
+}
+
+func NewStratifiedSampler(samplesPerPixel int, seed uint64) *StratifiedSampler {
+	if samplesPerPixel < 1 {
+		samplesPerPixel = 1
+	}
+	gridX := int(stdmath.Sqrt(float64(samplesPerPixel)))
+	gridY := (samplesPerPixel + gridX - 1) / gridX
+	
+	return &StratifiedSampler{
+		pixelSample:     pixelSample{seed: seed},
+		samplesPerPixel: samplesPerPixel,
+		gridX:           gridX,
+		gridY:           gridY,
+	}
+}
+
+func (s *StratifiedSampler) Get1D() float64 {
+	stratum := permute(uint32(s.index%s.samplesPerPixel), uint32(s.samplesPerPixel), s.hash(s.dimension))
+	s.dimension++
+	return (float64(stratum) + s.rng.Float64()) / float64(s.samplesPerPixel)
+}
+
+func (s *StratifiedSampler) Get2D() (float64, float64) {
+	cells := s.gridX * s.gridY
+	stratum := int(permute(uint32(s.index%cells), uint32(cells), s.hash(s.dimension)))
+	s.dimension += 2
+	
+	u := (float64(stratum%s.gridX) + s.rng.Float64()) / float64(s.gridX)
+	v := (float64(stratum/s.gridX) + s.rng.Float64()) / float64(s.gridY)
+	return u, v
+}
+
+var haltonPrimes = []uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71, 73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131}
+
+// HaltonSampler uses the radical inverse of the sample index in a different
+// prime base per dimension, randomly rotated per pixel (Cranley-Patterson)
+// so that neighbouring pixels do not repeat the same pattern. Dimensions past
+// the prime table are drawn at random.
+type HaltonSampler struct {
+	pixelSample
+}
+
+func NewHaltonSampler(seed uint64) *HaltonSampler {
+	return &HaltonSampler{pixelSample{seed: seed}}
+}
+
+func (s *HaltonSampler) Get1D() float64 {
+	dimension := s.dimension
+	s.dimension++
+	
+	if dimension >= len(haltonPrimes) {
+		return s.rng.Float64()
+	}
+	
+	value := radicalInverse(uint64(s.index), haltonPrimes[dimension]) + float64(s.hash(dimension))/(1<<32)
+	if value >= 1 {
+		value--
+	}
+	return value
+}
+
+func (s *HaltonSampler) Get2D() (float64, float64) {
+	return s.Get1D(), s.Get1D()
+}
+
+func radicalInverse(index, base uint64) float64 {
+	inverseBase := 1.0 / float64(base)
+	scale := inverseBase
+	result := 0.0
+	for index > 0 {
+		result += float64(index%base) * scale
+		index /= base
+		scale *= inverseBase
+	}
+	return stdmath.Min(result, 1.0-1e-16)
+}
+
+// SobolSampler uses the first two Sobol dimensions with Owen scrambling. Each
+// requested dimension pair gets its own scramble and its own shuffle of the
+// sample order, which pads the 2D sequence into as many dimensions as the
+// integrator needs (Burley, "Practical Hash-based Owen Scrambling", 2020).
+// It converges best when the sample count is a power of two.
+type SobolSampler struct {
+	pixelSample
+}
+
+func NewSobolSampler(seed uint64) *SobolSampler {
+	return &SobolSampler{pixelSample{seed: seed}}
+}
+
+func (s *SobolSampler) Get1D() float64 {
+	seed := s.hash(s.dimension)
+	s.dimension++
+	
+	index := nestedUniformScramble(uint32(s.index), hashUint32(seed))
+	return toUnitFloat(nestedUniformScramble(sobol(index, 0), hashUint32(seed^0x1)))
+}
+
+func (s *SobolSampler) Get2D() (float64, float64) {
+	seed := s.hash(s.dimension)
+	s.dimension += 2
+	
+	index := nestedUniformScramble(uint32(s.index), hashUint32(seed))
+	u := nestedUniformScramble(sobol(index, 0), hashUint32(seed^0x1))
+	v := nestedUniformScramble(sobol(index, 1), hashUint32(seed^0x2))
+	return toUnitFloat(u), toUnitFloat(v)
+}
+
+var sobolDirections = func() [2][32]uint32 {
+	var directions [2][32]uint32
+	v := uint32(1 << 31)
+	for i := 0; i < 32; i++ {
+		directions[0][i] = uint32(1<<31) >> i
+		directions[1][i] = v
+		v ^= v >> 1
+	}
+	return directions
+}()
+
+func sobol(index uint32, dimension int) uint32 {
+	result := uint32(0)
+	for bit := 0; index != 0; bit, index = bit+1, index>>1 {
+		if index&1 != 0 {
+			result ^= sobolDirections[dimension][bit]
+		}
+	}
+	return result
+}
+
+func nestedUniformScramble(x, seed uint32) uint32 {
+	x = reverseBits(x)
+	x = laineKarrasPermutation(x, seed)
+	return reverseBits(x)
+}
+
+func laineKarrasPermutation(x, seed uint32) uint32 {
+	x += seed
+	x ^= x * 0x6c50b47c
+	x ^= x * 0xb82f1e52
+	x ^= x * 0xc7afe638
+	x ^= x * 0x8d22f6e6
+	return x
+}
+
+func reverseBits(x uint32) uint32 {
+	x = (x << 16) | (x >> 16)
+	x = ((x & 0x00ff00ff) << 8) | ((x & 0xff00ff00) >> 8)
+	x = ((x & 0x0f0f0f0f) << 4) | ((x & 0xf0f0f0f0) >> 4)
+	x = ((x & 0x33333333) << 2) | ((x & 0xcccccccc) >> 2)
+	x = ((x & 0x55555555) << 1) | ((x & 0xaaaaaaaa) >> 1)
+	return x
+}
+
+func hashUint32(x uint32) uint32 {
+	x ^= x >> 16
+	x *= 0x21f0aaad
+	x ^= x >> 15
+	x *= 0xd35a2d97
+	x ^= x >> 15
+	return x
+}
+
+func toUnitFloat(x uint32) float64 {
+	return float64(x) / (1 << 32)
+}
+
+// permute maps i in [0, n) to a pseudo-random permutation of [0, n) chosen
+// by seed (Kensler, "Correlated Multi-Jittered Sampling", 2013).
+func permute(i, n, seed uint32) uint32 {
+	w := n - 1
+	w |= w >> 1
+	w |= w >> 2
+	w |= w >> 4
+	w |= w >> 8
+	w |= w >> 16
+	
+	for {
+		i ^= seed
+		i *= 0xe170893d
+		i ^= seed >> 16
+		i ^= (i & w) >> 4
+		i ^= seed >> 8
+		i *= 0x0929eb3f
+		i ^= seed >> 23
+		i ^= (i & w) >> 1
+		i *= 1 | seed>>27
+		i *= 0x6935fa69
+		i ^= (i & w) >> 11
+		i *= 0x74dcb303
+		i ^= (i & w) >> 2
+		i *= 0x9e501cc3
+		i ^= (i & w) >> 2
+		i *= 0xc860a3df
+		i &= w
+		i ^= i >> 5
+		if i < n {
+			break
+		}
+	}
+	
+	return (i + seed) % n
+}
+
+// ConcentricDisk maps a point of the unit square to the unit disk, keeping
+// strata of the square adjacent on the disk (Shirley and Chiu).
+func ConcentricDisk(u, v float64) (float64, float64) {
+	a := 2*u - 1
+	b := 2*v - 1
+	if a == 0 && b == 0 {
+		return 0, 0
+	}
+	
+	var r, theta float64
+	if a*a > b*b {
+		r = a
+		theta = stdmath.Pi / 4 * (b / a)
+	} else {
+		r = b
+		theta = stdmath.Pi/2 - stdmath.Pi/4*(a/b)
+	}
+	return r * stdmath.Cos(theta), r * stdmath.Sin(theta)
+}
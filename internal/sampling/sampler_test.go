@@ -0,0 +1,85 @@
+package sampling
+
+import (
+	stdmath "math"
+	"testing"
+)
+
+func TestSamplersStayInUnitInterval(t *testing.T) {
+	for _, name := range Names {
+		sampler, err := New(name, 16, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		
+		for i := 0; i < 64; i++ {
+			sampler.StartPixelSample(3, 5, i)
+			for d := 0; d < 40; d++ {
+				u, v := sampler.Get2D()
+				w := sampler.Get1D()
+				for _, x := range []float64{u, v, w} {
+					if x < 0 || x >= 1 {
+						t.Fatalf("%s sample %d dimension %d: got %v, want a value in [0, 1)", name, i, d, x)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestStratifiedSamplersCoverEveryStratum(t *testing.T) {
+	for _, name := range []string{Stratified, Sobol} {
+		sampler, _ := New(name, 16, 9)
+		
+		// Every 2D dimension, not just the first, should put one of 16
+		// samples in each cell of a 4x4 grid.
+		for dimension := 0; dimension < 5; dimension++ {
+			var cells [16]int
+			for i := 0; i < 16; i++ {
+				sampler.StartPixelSample(1, 2, i)
+				for d := 0; d < dimension; d++ {
+					sampler.Get2D()
+				}
+				u, v := sampler.Get2D()
+				cells[int(v*4)*4+int(u*4)]++
+			}
+			
+			for cell, count := range cells {
+				if count != 1 {
+					t.Errorf("%s dimension %d: cell %d got %d samples, want 1", name, dimension, cell, count)
+				}
+			}
+		}
+	}
+}
+
+func TestLowDiscrepancySamplersConvergeFaster(t *testing.T) {
+	const samples = 64
+	
+	// Mean squared error over many pixels of estimating the area of the
+	// quarter disk, pi/4.
+	errorOf := func(name string) float64 {
+		sampler, _ := New(name, samples, 5)
+		total := 0.0
+		for pixel := 0; pixel < 200; pixel++ {
+			inside := 0
+			for i := 0; i < samples; i++ {
+				sampler.StartPixelSample(pixel, 0, i)
+				u, v := sampler.Get2D()
+				if u*u+v*v < 1 {
+					inside++
+				}
+			}
+			diff := float64(inside)/samples - stdmath.Pi/4
+			total += diff * diff
+		}
+		return total / 200
+	}
+	
+	independent := errorOf(Independent)
+	for _, name := range []string{Stratified, Halton, Sobol} {
+		if got := errorOf(name); got >= independent/2 {
+			t.Errorf("%s error: got %v, want well below independent %v", name, got, independent)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package scene
+
+import (
+	"encoding/json"
+	"raytraceGo/internal/atmosphere"
+	"raytraceGo/internal/math"
+)
+
+// AtmosphereSettings picks an atmosphere preset and overrides individual
+// fields of it. In a scene file it is either a bare preset name or an object
+// with an optional "preset" and any of the overrides.
+type AtmosphereSettings struct {
+	Preset             string     `json:"preset,omitempty"`
+	SkyColorTop        *math.Vec3 `json:"skyColorTop,omitempty"`
+	SkyColorBottom     *math.Vec3 `json:"skyColorBottom,omitempty"`
+	SunDirection       *math.Vec3 `json:"sunDirection,omitempty"`
+	SunColor           *math.Vec3 `json:"sunColor,omitempty"`
+	SunIntensity       *float64   `json:"sunIntensity,omitempty"`
+	SunSize            *float64   `json:"sunSize,omitempty"`
+	RayleighScattering *math.Vec3 `json:"rayleighScattering,omitempty"`
+	MieScattering      *math.Vec3 `json:"mieScattering,omitempty"`
+	AtmosphericDepth   *float64   `json:"atmosphericDepth,omitempty"`
+	FogDensity         *float64   `json:"fogDensity,omitempty"`
+	FogColor           *math.Vec3 `json:"fogColor,omitempty"`
+	HazeIntensity      *float64   `json:"hazeIntensity,omitempty"`
+	TimeOfDay          *float64   `json:"timeOfDay,omitempty"`
+}
+
+func (a *AtmosphereSettings) UnmarshalJSON(data []byte) error {
+	var preset string
+	if err := json.Unmarshal(data, &preset); err == nil {
+		*a = AtmosphereSettings{Preset: preset}
+		return nil
+	}
+	
+	type settings AtmosphereSettings
+	return json.Unmarshal(data, (*settings)(a))
+}
+
+// Config resolves the preset, falling back to the default one, and applies
+// the overrides.
+func (a *AtmosphereSettings) Config() *atmosphere.AtmosphereConfig {
+	config, err := atmosphere.NewPreset(a.Preset)
+	if err != nil {
+		config = atmosphere.NewDefaultAtmosphere()
+	}
+	
+	overrideVec3(&config.SkyColorTop, a.SkyColorTop)
+	overrideVec3(&config.SkyColorBottom, a.SkyColorBottom)
+	overrideVec3(&config.SunDirection, a.SunDirection)
+	overrideVec3(&config.SunColor, a.SunColor)
+	overrideFloat(&config.SunIntensity, a.SunIntensity)
+	overrideFloat(&config.SunSize, a.SunSize)
+	overrideVec3(&config.RayleighScattering, a.RayleighScattering)
+	overrideVec3(&config.MieScattering, a.MieScattering)
+	overrideFloat(&config.AtmosphericDepth, a.AtmosphericDepth)
+	overrideFloat(&config.FogDensity, a.FogDensity)
+	overrideVec3(&config.FogColor, a.FogColor)
+	overrideFloat(&config.HazeIntensity, a.HazeIntensity)
+	overrideFloat(&config.TimeOfDay, a.TimeOfDay)
+	
+	return config
+}
+
+// GetAtmosphere returns nil when the scene has no atmosphere section, in
+// which case rays that miss everything stay black.
+func (s *Scene) GetAtmosphere() *atmosphere.AtmosphereConfig {
+	if s.Atmosphere == nil {
+		return nil
+	}
+	return s.Atmosphere.Config()
+}
+
+func overrideVec3(target *math.Vec3, value *math.Vec3) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+func overrideFloat(target *float64, value *float64) {
+	if value != nil {
+		*target = *value
+	}
+}
@@ -0,0 +1,106 @@
+package scene
+
+import (
+	"fmt"
+	"raytraceGo/internal/geometry"
+	"raytraceGo/internal/material"
+	"raytraceGo/internal/math"
+	"raytraceGo/internal/optimization"
+)
+
+type Mesh struct {
+	Triangles []geometry.Hittable
+	bvh       *optimization.BVH
+}
+
+// NewMesh builds a bounding volume hierarchy over the triangles so that hit
+// tests cost roughly log(n) instead of a scan over every triangle.
+func NewMesh(triangles []geometry.Hittable) *Mesh {
+	mesh := &Mesh{Triangles: triangles}
+	if len(triangles) > 0 {
+		ordered := append([]geometry.Hittable(nil), triangles...)
+		mesh.bvh = optimization.NewBVH(ordered, 0, len(ordered))
+	}
+	return mesh
+}
+
+func (m *Mesh) Hit(ray geometry.Ray, tMin, tMax float64) (*geometry.HitRecord, bool) {
+	if m.bvh == nil {
+		return nil, false
+	}
+	return m.bvh.Hit(ray, tMin, tMax)
+}
+
+func (m *Mesh) BoundingBox() geometry.AABB {
+	if m.bvh != nil {
+		return m.bvh.Box
+	}
+	return geometry.AABB{}
+}
+
+// createOBJMesh loads an OBJ mesh object. The object's material, if given,
+// overrides the MTL materials; faces without either get a grey Lambertian.
+func createOBJMesh(obj Object, baseDir string) (*Mesh, error) {
+	model, err := LoadOBJ(resolvePath(baseDir, obj.File))
+	if err != nil {
+		return nil, err
+	}
+	
+	scale := obj.Scale
+	if scale == 0 {
+		scale = 1.0
+	}
+	
+	var override material.Material
+	if len(obj.Material) > 0 {
+		override = createMaterial(obj.Material)
+	}
+	
+	defaultMaterial := material.NewLambertian(math.Vec3{X: 0.5, Y: 0.5, Z: 0.5})
+	materials := make(map[string]material.Material)
+	materialFor := func(name string) material.Material {
+		if override != nil {
+			return override
+		}
+		if m, ok := materials[name]; ok {
+			return m
+		}
+		m := material.Material(defaultMaterial)
+		if mtl, ok := model.Materials[name]; ok {
+			m = mtl.ToMaterial()
+		}
+		materials[name] = m
+		return m
+	}
+	
+	var triangles []geometry.Hittable
+	for _, face := range model.Faces {
+		points := make([]math.Vec3, len(face.Vertices))
+		for i, vertex := range face.Vertices {
+			points[i] = model.Positions[vertex.Position].MulScalar(scale).Add(obj.Position)
+		}
+		
+		faceMaterial := materialFor(face.Material)
+		
+		for _, corners := range triangulatePolygon(points) {
+			v0, v1, v2 := points[corners[0]], points[corners[1]], points[corners[2]]
+			if v1.Sub(v0).Cross(v2.Sub(v0)).LengthSquared() == 0 {
+				continue
+			}
+			
+			n0, n1, n2 := face.Vertices[corners[0]].Normal, face.Vertices[corners[1]].Normal, face.Vertices[corners[2]].Normal
+			if n0 >= 0 && n1 >= 0 && n2 >= 0 {
+				triangles = append(triangles, geometry.NewTriangleWithNormals(v0, v1, v2,
+					model.Normals[n0].Normalize(), model.Normals[n1].Normalize(), model.Normals[n2].Normalize(), faceMaterial))
+			} else {
+				triangles = append(triangles, geometry.NewTriangle(v0, v1, v2, faceMaterial))
+			}
+		}
+	}
+	
+	if len(triangles) == 0 {
+		return nil, fmt.Errorf("%s contains no triangles", obj.File)
+	}
+	
+	return NewMesh(triangles), nil
+}
@@ -0,0 +1,169 @@
+package scene
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	stdmath "math"
+	"os"
+	"raytraceGo/internal/material"
+	"raytraceGo/internal/math"
+	"strconv"
+	"strings"
+)
+
+type MTLMaterial struct {
+	Name            string
+	Diffuse         math.Vec3
+	Specular        math.Vec3
+	Emission        math.Vec3
+	Shininess       float64
+	RefractionIndex float64
+	Dissolve        float64
+	Illum           int
+	Metallic        float64
+	Roughness       float64
+	HasRoughness    bool
+}
+
+func LoadMTL(path string) (map[string]MTLMaterial, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening MTL file: %v", err)
+	}
+	defer file.Close()
+	
+	materials, err := ParseMTL(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return materials, nil
+}
+
+func ParseMTL(r io.Reader) (map[string]MTLMaterial, error) {
+	materials := make(map[string]MTLMaterial)
+	
+	var current *MTLMaterial
+	flush := func() {
+		if current != nil {
+			materials[current.Name] = *current
+		}
+	}
+	
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		
+		if fields[0] == "newmtl" {
+			flush()
+			current = &MTLMaterial{
+				Name:            strings.Join(fields[1:], " "),
+				Diffuse:         math.Vec3{X: 0.8, Y: 0.8, Z: 0.8},
+				RefractionIndex: 1.0,
+				Dissolve:        1.0,
+				Illum:           2,
+			}
+			continue
+		}
+		
+		if current == nil {
+			continue
+		}
+		
+		var err error
+		switch fields[0] {
+		case "Kd":
+			current.Diffuse, err = parseMTLColor(fields[1:])
+		case "Ks":
+			current.Specular, err = parseMTLColor(fields[1:])
+		case "Ke":
+			current.Emission, err = parseMTLColor(fields[1:])
+		case "Ns":
+			current.Shininess, err = parseMTLFloat(fields[1:])
+		case "Ni":
+			current.RefractionIndex, err = parseMTLFloat(fields[1:])
+		case "d":
+			current.Dissolve, err = parseMTLFloat(fields[1:])
+		case "Tr":
+			var transparency float64
+			transparency, err = parseMTLFloat(fields[1:])
+			current.Dissolve = 1.0 - transparency
+		case "Pm":
+			current.Metallic, err = parseMTLFloat(fields[1:])
+		case "Pr":
+			current.Roughness, err = parseMTLFloat(fields[1:])
+			current.HasRoughness = true
+		case "illum":
+			var illum float64
+			illum, err = parseMTLFloat(fields[1:])
+			current.Illum = int(illum)
+		}
+		
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+	}
+	flush()
+	
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading MTL: %v", err)
+	}
+	
+	return materials, nil
+}
+
+// ToMaterial maps the MTL illumination model and PBR extensions onto the
+// closest renderer material: emissive materials become lights, transparent
+// ones dielectrics, and metallic or mirror-like ones metals.
+func (m MTLMaterial) ToMaterial() material.Material {
+	if !m.Emission.NearZero() {
+		return material.NewDiffuseLight(m.Emission)
+	}
+	
+	if m.Dissolve < 1.0 || m.Illum == 4 || m.Illum == 6 || m.Illum == 7 || m.Illum == 9 {
+		refractionIndex := m.RefractionIndex
+		if refractionIndex <= 1.0 {
+			refractionIndex = 1.5
+		}
+		return material.NewDielectric(refractionIndex)
+	}
+	
+	roughness := m.Roughness
+	if !m.HasRoughness {
+		// Blinn-Phong exponent to an approximate microfacet roughness.
+		roughness = math.FastClamp(stdmath.Sqrt(2.0/(m.Shininess+2.0)), 0.0, 1.0)
+	}
+	
+	if m.Metallic > 0.5 {
+		return material.NewMetal(m.Diffuse, roughness, m.Metallic, 1.0)
+	}
+	
+	if m.Illum == 3 && !m.Specular.NearZero() {
+		return material.NewMetal(m.Specular, roughness, 1.0, 1.0)
+	}
+	
+	return material.NewLambertian(m.Diffuse)
+}
+
+func parseMTLColor(fields []string) (math.Vec3, error) {
+	if len(fields) == 1 {
+		value, err := parseMTLFloat(fields)
+		return math.Vec3{X: value, Y: value, Z: value}, err
+	}
+	return parseOBJVec3(fields, 3)
+}
+
+func parseMTLFloat(fields []string) (float64, error) {
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("expected a number")
+	}
+	
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", fields[0])
+	}
+	return value, nil
+}
@@ -0,0 +1,311 @@
+package scene
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"raytraceGo/internal/math"
+	"strconv"
+	"strings"
+)
+
+// OBJModel is the parsed content of a Wavefront OBJ file. Face indices are
+// zero-based and already resolved, so negative (relative) indices never
+// reach callers; -1 marks a missing texture coordinate or normal.
+type OBJModel struct {
+	Positions         []math.Vec3
+	Normals           []math.Vec3
+	TexCoords         []math.Vec3
+	Faces             []OBJFace
+	MaterialLibraries []string
+	Materials         map[string]MTLMaterial
+}
+
+type OBJFace struct {
+	Vertices []OBJVertex
+	Group    string
+	Material string
+}
+
+type OBJVertex struct {
+	Position int
+	TexCoord int
+	Normal   int
+}
+
+// LoadOBJ parses an OBJ file and every MTL library it references. Library
+// paths are resolved relative to the OBJ file.
+func LoadOBJ(path string) (*OBJModel, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening OBJ file: %v", err)
+	}
+	defer file.Close()
+	
+	model, err := ParseOBJ(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	
+	for _, library := range model.MaterialLibraries {
+		libraryPath := resolvePath(filepath.Dir(path), library)
+		materials, err := LoadMTL(libraryPath)
+		if err != nil {
+			return nil, err
+		}
+		for name, material := range materials {
+			model.Materials[name] = material
+		}
+	}
+	
+	return model, nil
+}
+
+func ParseOBJ(r io.Reader) (*OBJModel, error) {
+	model := &OBJModel{
+		Materials: make(map[string]MTLMaterial),
+	}
+	
+	group := ""
+	materialName := ""
+	
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		
+		var err error
+		switch fields[0] {
+		case "v":
+			var position math.Vec3
+			position, err = parseOBJVec3(fields[1:], 3)
+			model.Positions = append(model.Positions, position)
+			
+		case "vn":
+			var normal math.Vec3
+			normal, err = parseOBJVec3(fields[1:], 3)
+			model.Normals = append(model.Normals, normal)
+			
+		case "vt":
+			var texCoord math.Vec3
+			texCoord, err = parseOBJVec3(fields[1:], 1)
+			model.TexCoords = append(model.TexCoords, texCoord)
+			
+		case "f":
+			var face OBJFace
+			face, err = model.parseFace(fields[1:])
+			face.Group = group
+			face.Material = materialName
+			model.Faces = append(model.Faces, face)
+			
+		case "g", "o":
+			group = strings.Join(fields[1:], " ")
+			
+		case "usemtl":
+			materialName = strings.Join(fields[1:], " ")
+			
+		case "mtllib":
+			model.MaterialLibraries = append(model.MaterialLibraries, fields[1:]...)
+		}
+		
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+	}
+	
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading OBJ: %v", err)
+	}
+	
+	return model, nil
+}
+
+func (m *OBJModel) parseFace(fields []string) (OBJFace, error) {
+	if len(fields) < 3 {
+		return OBJFace{}, fmt.Errorf("face needs at least 3 vertices, got %d", len(fields))
+	}
+	
+	face := OBJFace{Vertices: make([]OBJVertex, len(fields))}
+	for i, field := range fields {
+		parts := strings.Split(field, "/")
+		if len(parts) > 3 {
+			return OBJFace{}, fmt.Errorf("invalid face vertex %q", field)
+		}
+		
+		vertex := OBJVertex{TexCoord: -1, Normal: -1}
+		
+		var err error
+		if vertex.Position, err = resolveOBJIndex(parts[0], len(m.Positions)); err != nil {
+			return OBJFace{}, fmt.Errorf("face vertex %q: %v", field, err)
+		}
+		if len(parts) > 1 && parts[1] != "" {
+			if vertex.TexCoord, err = resolveOBJIndex(parts[1], len(m.TexCoords)); err != nil {
+				return OBJFace{}, fmt.Errorf("face vertex %q: %v", field, err)
+			}
+		}
+		if len(parts) > 2 && parts[2] != "" {
+			if vertex.Normal, err = resolveOBJIndex(parts[2], len(m.Normals)); err != nil {
+				return OBJFace{}, fmt.Errorf("face vertex %q: %v", field, err)
+			}
+		}
+		
+		face.Vertices[i] = vertex
+	}
+	
+	return face, nil
+}
+
+// resolveOBJIndex converts a one-based OBJ index, or a negative index counted
+// back from the most recent element, into a zero-based index.
+func resolveOBJIndex(s string, count int) (int, error) {
+	index, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid index %q", s)
+	}
+	
+	if index < 0 {
+		index += count
+	} else {
+		index--
+	}
+	
+	if index < 0 || index >= count {
+		return 0, fmt.Errorf("index %s out of range, %d defined so far", s, count)
+	}
+	return index, nil
+}
+
+func parseOBJVec3(fields []string, required int) (math.Vec3, error) {
+	if len(fields) < required {
+		return math.Vec3{}, fmt.Errorf("expected at least %d numbers, got %d", required, len(fields))
+	}
+	
+	var values [3]float64
+	for i := 0; i < len(fields) && i < 3; i++ {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return math.Vec3{}, fmt.Errorf("invalid number %q", fields[i])
+		}
+		values[i] = value
+	}
+	
+	return math.Vec3{X: values[0], Y: values[1], Z: values[2]}, nil
+}
+
+func stripComment(line string) string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+func resolvePath(baseDir, path string) string {
+	if filepath.IsAbs(path) || baseDir == "" {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// triangulatePolygon splits a planar polygon into triangles by ear clipping,
+// so concave faces exported by modelling tools come out right. It returns
+// indices into points.
+func triangulatePolygon(points []math.Vec3) [][3]int {
+	n := len(points)
+	if n == 3 {
+		return [][3]int{{0, 1, 2}}
+	}
+	
+	// Project onto the plane most perpendicular to the Newell normal.
+	var normal math.Vec3
+	for i := 0; i < n; i++ {
+		current, next := points[i], points[(i+1)%n]
+		normal.X += (current.Y - next.Y) * (current.Z + next.Z)
+		normal.Y += (current.Z - next.Z) * (current.X + next.X)
+		normal.Z += (current.X - next.X) * (current.Y + next.Y)
+	}
+	
+	project := func(p math.Vec3) (float64, float64) {
+		ax, ay, az := math.FastAbs(normal.X), math.FastAbs(normal.Y), math.FastAbs(normal.Z)
+		switch {
+		case ax >= ay && ax >= az:
+			if normal.X < 0 {
+				return p.Z, p.Y
+			}
+			return p.Y, p.Z
+		case ay >= az:
+			if normal.Y < 0 {
+				return p.X, p.Z
+			}
+			return p.Z, p.X
+		default:
+			if normal.Z < 0 {
+				return p.Y, p.X
+			}
+			return p.X, p.Y
+		}
+	}
+	
+	xs := make([]float64, n)
+	ys := make([]float64, n)
+	for i, p := range points {
+		xs[i], ys[i] = project(p)
+	}
+	
+	cross := func(a, b, c int) float64 {
+		return (xs[b]-xs[a])*(ys[c]-ys[a]) - (ys[b]-ys[a])*(xs[c]-xs[a])
+	}
+	
+	remaining := make([]int, n)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	
+	var triangles [][3]int
+	for len(remaining) > 3 {
+		clipped := false
+		
+		for i := range remaining {
+			prev := remaining[(i+len(remaining)-1)%len(remaining)]
+			curr := remaining[i]
+			next := remaining[(i+1)%len(remaining)]
+			
+			if cross(prev, curr, next) <= 0 {
+				continue
+			}
+			
+			ear := true
+			for _, other := range remaining {
+				if other == prev || other == curr || other == next {
+					continue
+				}
+				if cross(prev, curr, other) >= 0 && cross(curr, next, other) >= 0 && cross(next, prev, other) >= 0 {
+					ear = false
+					break
+				}
+			}
+			
+			if ear {
+				triangles = append(triangles, [3]int{prev, curr, next})
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				clipped = true
+				break
+			}
+		}
+		
+		// Degenerate or self-intersecting polygons have no ear; fan the rest.
+		if !clipped {
+			for i := 1; i+1 < len(remaining); i++ {
+				triangles = append(triangles, [3]int{remaining[0], remaining[i], remaining[i+1]})
+			}
+			return triangles
+		}
+	}
+	
+	return append(triangles, [3]int{remaining[0], remaining[1], remaining[2]})
+}
@@ -0,0 +1,185 @@
+package scene
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"raytraceGo/internal/geometry"
+	"raytraceGo/internal/material"
+	"raytraceGo/internal/math"
+	"strings"
+	"testing"
+)
+
+func TestParseOBJ(t *testing.T) {
+	data := `# quad with relative indices
+mtllib box.mtl
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0
+vn 0 0 1
+g front
+usemtl red
+f -4/1/1 -3/1/1 -2/1/1 -1/1/1
+g back
+f 1 3 2
+`
+
+	model, err := ParseOBJ(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseOBJ failed: %v", err)
+	}
+	
+	if len(model.Positions) != 4 || len(model.Normals) != 1 || len(model.TexCoords) != 1 {
+		t.Fatalf("ParseOBJ failed: got %d positions, %d normals, %d texcoords", len(model.Positions), len(model.Normals), len(model.TexCoords))
+	}
+	if len(model.MaterialLibraries) != 1 || model.MaterialLibraries[0] != "box.mtl" {
+		t.Errorf("ParseOBJ failed: got material libraries %v", model.MaterialLibraries)
+	}
+	if len(model.Faces) != 2 {
+		t.Fatalf("ParseOBJ failed: got %d faces, want 2", len(model.Faces))
+	}
+	
+	quad := model.Faces[0]
+	if quad.Group != "front" || quad.Material != "red" {
+		t.Errorf("ParseOBJ failed: got group %q material %q", quad.Group, quad.Material)
+	}
+	for i, vertex := range quad.Vertices {
+		if vertex != (OBJVertex{Position: i, TexCoord: 0, Normal: 0}) {
+			t.Errorf("ParseOBJ vertex %d failed: got %+v", i, vertex)
+		}
+	}
+	
+	if back := model.Faces[1]; back.Group != "back" || back.Vertices[1].Normal != -1 || back.Vertices[1].Position != 2 {
+		t.Errorf("ParseOBJ failed: got back face %+v", back)
+	}
+}
+
+func TestParseOBJRejectsOutOfRangeIndex(t *testing.T) {
+	_, err := ParseOBJ(strings.NewReader("v 0 0 0\nv 1 0 0\nf 1 2 3\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("ParseOBJ error: got %v, want an error on line 3", err)
+	}
+}
+
+func TestTriangulateConcavePolygon(t *testing.T) {
+	// An L shape whose reflex corner at index 4 breaks a naive fan from index 0.
+	points := []math.Vec3{
+		{X: 0, Y: 0}, {X: 2, Y: 0}, {X: 2, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: 2}, {X: 0, Y: 2},
+	}
+	
+	triangles := triangulatePolygon(points)
+	if len(triangles) != 4 {
+		t.Fatalf("triangulatePolygon failed: got %d triangles, want 4", len(triangles))
+	}
+	
+	area := 0.0
+	for _, tri := range triangles {
+		a, b, c := points[tri[0]], points[tri[1]], points[tri[2]]
+		signed := b.Sub(a).Cross(c.Sub(a)).Z / 2
+		if signed <= 0 {
+			t.Errorf("triangulatePolygon failed: triangle %v is inverted or degenerate", tri)
+		}
+		area += signed
+	}
+	if area != 3 {
+		t.Errorf("triangulatePolygon failed: got area %v, want 3", area)
+	}
+}
+
+func TestMeshObjectLoadsRelativeToScene(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"model.obj": "mtllib model.mtl\nv -1 -1 0\nv 1 -1 0\nv 1 1 0\nv -1 1 0\nusemtl chrome\nf 1 2 3 4\n",
+		"model.mtl": "newmtl chrome\nKd 0.9 0.9 0.9\nPm 1\nPr 0.1\n",
+		"scene.json": `{
+			"camera": {"position": [0, 0, -5], "lookAt": [0, 0, 0]},
+			"objects": [{"type": "mesh", "file": "model.obj", "position": [0, 0, 2]}]
+		}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	
+	scene, err := LoadFromFile(filepath.Join(dir, "scene.json"))
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	
+	hittables := scene.GetHittables()
+	if len(hittables) != 1 {
+		t.Fatalf("GetHittables failed: got %d hittables, want 1", len(hittables))
+	}
+	
+	ray := geometry.NewRay(math.Vec3{X: 0.5, Y: 0.5, Z: -5}, math.Vec3{X: 0, Y: 0, Z: 1})
+	hit, ok := hittables[0].Hit(ray, 0.001, 100)
+	if !ok || hit.T != 7 {
+		t.Fatalf("Mesh hit failed: got %v %v, want a hit at t=7", hit, ok)
+	}
+	if _, isMetal := hit.Material.(*material.Metal); !isMetal {
+		t.Errorf("Mesh material failed: got %T, want *material.Metal", hit.Material)
+	}
+}
+
+func TestMissingMeshFailsSceneLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"empty.obj": "v 0 0 0\n",
+		"scene.json": `{
+			"camera": {"position": [0, 0, -5], "lookAt": [0, 0, 0]},
+			"objects": [
+				{"type": "mesh", "file": "modle.obj"},
+				{"type": "sphere", "position": [0, 0, 0], "radius": 1, "material": {"type": "lambertian", "color": [1, 0, 0]}},
+				{"type": "mesh", "file": "empty.obj"}
+			]
+		}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	
+	_, err := LoadFromFile(filepath.Join(dir, "scene.json"))
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) || len(validationErrors) != 2 {
+		t.Fatalf("LoadFromFile failed: got %v, want two ValidationErrors", err)
+	}
+	if validationErrors[0].Path != "$.objects[0].file" || !strings.Contains(validationErrors[0].Got, "modle.obj") {
+		t.Errorf("LoadFromFile failed: got %+v, want the missing file", validationErrors[0])
+	}
+	if validationErrors[1].Path != "$.objects[2].file" {
+		t.Errorf("LoadFromFile failed: got %+v, want the mesh without triangles", validationErrors[1])
+	}
+}
+
+func TestParseWithoutFilesRejectsMeshes(t *testing.T) {
+	// The file exists, but a scene from another machine must not open it.
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tri.obj")
+	if err := os.WriteFile(path, []byte("v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{
+		"camera": {"position": [0, 0, -5], "lookAt": [0, 0, 0]},
+		"objects": [{"type": "mesh", "file": "` + filepath.ToSlash(path) + `"}]
+	}`)
+	
+	s, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !s.UsesFiles() {
+		t.Errorf("UsesFiles failed: got false for a mesh scene")
+	}
+	
+	_, err = ParseWithoutFiles(data)
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) || len(validationErrors) != 1 || validationErrors[0].Path != "$.objects[0].file" {
+		t.Errorf("ParseWithoutFiles failed: got %v, want an error at $.objects[0].file", err)
+	}
+}
@@ -0,0 +1,72 @@
+package scene
+
+import (
+	"raytraceGo/internal/effects"
+	"raytraceGo/internal/math"
+)
+
+// PostEffect is one stage of the scene's postProcess chain. Each field is
+// used by the effect types noted beside it; zero values select defaults.
+type PostEffect struct {
+	Type        string    `json:"type"`
+	Threshold   *float64  `json:"threshold,omitempty"`   // bloom, lensFlare
+	Intensity   float64   `json:"intensity,omitempty"`   // bloom, vignette, lensFlare
+	Radius      float64   `json:"radius,omitempty"`      // bloom, vignette
+	Softness    float64   `json:"softness,omitempty"`    // vignette
+	RedOffset   float64   `json:"redOffset,omitempty"`   // chromaticAberration
+	GreenOffset float64   `json:"greenOffset,omitempty"` // chromaticAberration
+	BlueOffset  float64   `json:"blueOffset,omitempty"`  // chromaticAberration
+	Color       math.Vec3 `json:"color,omitempty"`       // lensFlare
+	Size        float64   `json:"size,omitempty"`        // lensFlare
+}
+
+var postEffectTypes = []string{"bloom", "vignette", "chromaticAberration", "lensFlare"}
+
+// GetPostProcess decodes the postProcess chain in order. Bloom radius is a
+// fraction of the image height; chromatic aberration offsets scale each
+// channel's image about the center.
+func (s *Scene) GetPostProcess() []effects.PostEffect {
+	chain := make([]effects.PostEffect, 0, len(s.PostProcess))
+	
+	for _, e := range s.PostProcess {
+		switch e.Type {
+		case "bloom":
+			chain = append(chain, effects.NewBloom(thresholdOrDefault(e.Threshold), orDefault(e.Intensity, 0.3), orDefault(e.Radius, 0.05)))
+			
+		case "vignette":
+			chain = append(chain, effects.NewVignette(orDefault(e.Intensity, 0.5), orDefault(e.Radius, 0.75), orDefault(e.Softness, 1.0)))
+			
+		case "chromaticAberration":
+			red, green, blue := e.RedOffset, e.GreenOffset, e.BlueOffset
+			if red == 0 && green == 0 && blue == 0 {
+				red, blue = 0.002, -0.002
+			}
+			chain = append(chain, effects.NewChromaticAberration(red, green, blue))
+			
+		case "lensFlare":
+			color := e.Color
+			if color.NearZero() {
+				color = math.Vec3{X: 1, Y: 1, Z: 1}
+			}
+			flare := effects.NewLensFlare(orDefault(e.Intensity, 0.1), color, orDefault(e.Size, 1.0))
+			flare.Threshold = thresholdOrDefault(e.Threshold)
+			chain = append(chain, flare)
+		}
+	}
+	
+	return chain
+}
+
+func orDefault(value, fallback float64) float64 {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func thresholdOrDefault(threshold *float64) float64 {
+	if threshold == nil {
+		return 1.0
+	}
+	return *threshold
+}
@@ -3,24 +3,68 @@ package scene
 import (
 	"encoding/json"
 	"fmt"
+	stdmath "math"
 	"os"
+	"path/filepath"
 	"raytraceGo/internal/geometry"
+	"raytraceGo/internal/lighting"
 	"raytraceGo/internal/material"
 	"raytraceGo/internal/math"
+	"raytraceGo/internal/tonemap"
 )
 
 type Scene struct {
 	Camera  Camera   `json:"camera"`
 	Objects []Object `json:"objects"`
 	Lights  []Light  `json:"lights"`
+	
+	Atmosphere  *AtmosphereSettings `json:"atmosphere,omitempty"`
+	Renderer    *RenderSettings     `json:"renderer,omitempty"`
+	PostProcess []PostEffect        `json:"postProcess,omitempty"`
+	
+	// baseDir is the directory of the scene file; relative asset paths such
+	// as mesh files are resolved against it.
+	baseDir string
+	// meshes holds the mesh objects loaded with the scene, by object index.
+	meshes map[int]*Mesh
 }
 
 type Camera struct {
-	Position    math.Vec3 `json:"position"`
-	LookAt      math.Vec3 `json:"lookAt"`
-	Up          math.Vec3 `json:"up"`
-	FOV         float64   `json:"fov"`
-	AspectRatio float64   `json:"aspectRatio"`
+	Position      math.Vec3 `json:"position"`
+	LookAt        math.Vec3 `json:"lookAt"`
+	Up            math.Vec3 `json:"up"`
+	FOV           float64   `json:"fov"`
+	AspectRatio   float64   `json:"aspectRatio"`
+	Aperture      float64   `json:"aperture,omitempty"`
+	FocusDistance float64   `json:"focusDistance,omitempty"`
+	AutoFocus     bool      `json:"autoFocus,omitempty"`
+	Projection    string    `json:"projection,omitempty"`
+	ViewWidth     float64   `json:"viewWidth,omitempty"`
+}
+
+const (
+	ProjectionPerspective     = "perspective"
+	ProjectionOrthographic    = "orthographic"
+	ProjectionFisheye         = "fisheye"
+	ProjectionEquirectangular = "equirectangular"
+)
+
+// HasLens reports whether the camera's projection has a lens for depth of
+// field; the fisheye and equirectangular projections do not.
+func (c Camera) HasLens() bool {
+	return projectionHasLens(c.Projection)
+}
+
+func projectionHasLens(projection string) bool {
+	return projection != ProjectionFisheye && projection != ProjectionEquirectangular
+}
+
+// RenderSettings are renderer options saved with the scene. Command-line
+// flags take precedence over them.
+type RenderSettings struct {
+	Sampler     string            `json:"sampler,omitempty"`
+	AOVs        []string          `json:"aovs,omitempty"`
+	ToneMapping *tonemap.Settings `json:"toneMapping,omitempty"`
 }
 
 type Object struct {
@@ -28,16 +72,35 @@ type Object struct {
 	Position math.Vec3             `json:"position"`
 	Size     math.Vec3             `json:"size,omitempty"`
 	Radius   float64               `json:"radius,omitempty"`
+	Normal   math.Vec3             `json:"normal,omitempty"`
+	U        math.Vec3             `json:"u,omitempty"`
+	V        math.Vec3             `json:"v,omitempty"`
+	Vertices []math.Vec3           `json:"vertices,omitempty"`
+	Normals  []math.Vec3           `json:"normals,omitempty"`
+	File     string                `json:"file,omitempty"`
+	Scale    float64               `json:"scale,omitempty"`
 	Material map[string]interface{} `json:"material"`
 }
 
 type Light struct {
-	Type      string    `json:"type"`
-	Position  math.Vec3 `json:"position"`
-	Color     math.Vec3 `json:"color"`
-	Intensity float64   `json:"intensity"`
+	Type        string                `json:"type"`
+	Position    math.Vec3             `json:"position"`
+	Direction   math.Vec3             `json:"direction,omitempty"`
+	Color       math.Vec3             `json:"color"`
+	Intensity   float64               `json:"intensity"`
+	Attenuation *lighting.Attenuation `json:"attenuation,omitempty"`
+	InnerCutoff float64               `json:"innerCutoff,omitempty"`
+	OuterCutoff float64               `json:"outerCutoff,omitempty"`
+	U           math.Vec3             `json:"u,omitempty"`
+	V           math.Vec3             `json:"v,omitempty"`
+	Samples     int                   `json:"samples,omitempty"`
 }
 
+const (
+	defaultSpotInnerCutoff = 20.0
+	defaultSpotOuterCutoff = 30.0
+)
+
 type Hittable interface {
 	Hit(ray geometry.Ray, tMin, tMax float64) (*geometry.HitRecord, bool)
 }
@@ -48,21 +111,97 @@ func LoadFromFile(filename string) (*Scene, error) {
 		return nil, fmt.Errorf("error reading file: %v", err)
 	}
 	
+	return ParseWithBaseDir(data, filepath.Dir(filename))
+}
+
+// Parse decodes and validates a scene description. Schema problems are
+// returned together as ValidationErrors rather than failing on the first one,
+// followed by mesh files that cannot be loaded; relative mesh paths are
+// resolved against the working directory.
+func Parse(data []byte) (*Scene, error) {
+	return parse(data, "", true)
+}
+
+// ParseWithBaseDir is Parse with relative mesh paths resolved against
+// baseDir, for scenes read from a file in that directory.
+func ParseWithBaseDir(data []byte, baseDir string) (*Scene, error) {
+	return parse(data, baseDir, true)
+}
+
+// ParseWithoutFiles is Parse for scenes from other machines, such as those
+// sent to render nodes. The files mesh objects name are paths on the sender's
+// machine, so rather than opening them it reports the objects as
+// ValidationErrors.
+func ParseWithoutFiles(data []byte) (*Scene, error) {
+	return parse(data, "", false)
+}
+
+func parse(data []byte, baseDir string, files bool) (*Scene, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %v", err)
+	}
+	
+	if errs := validateScene(raw); len(errs) > 0 {
+		return nil, errs
+	}
+	
 	var scene Scene
 	if err := json.Unmarshal(data, &scene); err != nil {
 		return nil, fmt.Errorf("error parsing JSON: %v", err)
 	}
 	
+	scene.baseDir = baseDir
+	if errs := scene.loadMeshes(files); len(errs) > 0 {
+		return nil, errs
+	}
 	return &scene, nil
 }
 
+// loadMeshes loads the scene's mesh objects, so that a missing or broken
+// mesh file fails the scene instead of leaving the mesh out of the image.
+// Without files every mesh object is an error.
+func (s *Scene) loadMeshes(files bool) ValidationErrors {
+	var errs ValidationErrors
+	s.meshes = make(map[int]*Mesh)
+	for i, obj := range s.Objects {
+		if obj.Type != "mesh" {
+			continue
+		}
+		if !files {
+			errs = append(errs, ValidationError{Path: fmt.Sprintf("$.objects[%d].file", i), Expected: "no mesh file in a scene from another machine", Got: describeValue(obj.File)})
+			continue
+		}
+		mesh, err := createOBJMesh(obj, s.baseDir)
+		if err != nil {
+			errs = append(errs, ValidationError{Path: fmt.Sprintf("$.objects[%d].file", i), Expected: "a readable OBJ file with triangles", Got: err.Error()})
+			continue
+		}
+		s.meshes[i] = mesh
+	}
+	return errs
+}
+
+// UsesFiles reports whether the scene has objects loaded from files, which
+// ParseWithoutFiles rejects.
+func (s *Scene) UsesFiles() bool {
+	for _, obj := range s.Objects {
+		if obj.Type == "mesh" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetHittables builds the scene's objects, which Parse has validated and
+// whose meshes it has loaded.
 func (s *Scene) GetHittables() []geometry.Hittable {
 	var hittables []geometry.Hittable
 	
 	fmt.Println("Creating hittables from", len(s.Objects), "scene objects...")
 	
 	for i, obj := range s.Objects {
-		fmt.Printf("  Processing object %d: Type=%s, Material=%s\n", i+1, obj.Type, obj.Material["type"])
+		fmt.Printf("  Processing object %d: Type=%s, Material=%v\n", i+1, obj.Type, obj.Material["type"])
 		
 		var hittable geometry.Hittable
 		
@@ -77,9 +216,30 @@ func (s *Scene) GetHittables() []geometry.Hittable {
 			hittable = createCube(obj.Position, obj.Size, cubeMaterial)
 			fmt.Printf("    Created cube at %v with size %v\n", obj.Position, obj.Size)
 			
-		default:
-			fmt.Printf("    Unknown object type: %s\n", obj.Type)
-			continue
+		case "triangularPrism":
+			hittable = createTriangularPrism(obj.Vertices, createMaterial(obj.Material))
+			fmt.Printf("    Created triangular prism with vertices %v\n", obj.Vertices)
+			
+		case "plane":
+			hittable = geometry.NewPlane(obj.Position, obj.Normal, createMaterial(obj.Material))
+			fmt.Printf("    Created plane at %v with normal %v\n", obj.Position, obj.Normal)
+			
+		case "triangle":
+			hittable = createTriangle(obj.Vertices, obj.Normals, createMaterial(obj.Material))
+			fmt.Printf("    Created triangle with vertices %v\n", obj.Vertices)
+			
+		case "quad":
+			hittable = createPolygonMesh(obj.Vertices, [][]int{{0, 1, 2, 3}}, createMaterial(obj.Material))
+			fmt.Printf("    Created quad with vertices %v\n", obj.Vertices)
+			
+		case "mesh":
+			mesh := s.meshes[i]
+			hittable = mesh
+			fmt.Printf("    Created mesh from %s with %d triangles\n", obj.File, len(mesh.Triangles))
+			
+		case "rectangle":
+			hittable = createRectangle(obj.Position, obj.U, obj.V, createMaterial(obj.Material))
+			fmt.Printf("    Created rectangle at %v with edges %v and %v\n", obj.Position, obj.U, obj.V)
 		}
 		
 		hittables = append(hittables, hittable)
@@ -89,47 +249,103 @@ func (s *Scene) GetHittables() []geometry.Hittable {
 	return hittables
 }
 
-func (s *Scene) GetLights() []Light {
-	return s.Lights
+// GetLights decodes the scene lights by type. Point, spot and area lights
+// fall off with the inverse square of distance unless they set attenuation.
+func (s *Scene) GetLights() []lighting.Light {
+	lights := make([]lighting.Light, 0, len(s.Lights))
+	
+	for _, l := range s.Lights {
+		attenuation := lighting.Attenuation{Quadratic: 1.0}
+		if l.Attenuation != nil {
+			attenuation = *l.Attenuation
+		}
+		
+		switch l.Type {
+		case "point":
+			light := lighting.NewPointLight(l.Position, l.Color, l.Intensity)
+			light.Attenuation = attenuation
+			lights = append(lights, light)
+			
+		case "directional":
+			lights = append(lights, lighting.NewDirectionalLight(l.Direction, l.Color, l.Intensity))
+			
+		case "spot":
+			inner, outer := l.InnerCutoff, l.OuterCutoff
+			if outer <= 0 {
+				outer = defaultSpotOuterCutoff
+			}
+			if inner <= 0 {
+				inner = stdmath.Min(defaultSpotInnerCutoff, outer)
+			}
+			
+			light := lighting.NewSpotLight(l.Position, l.Direction, l.Color, l.Intensity,
+				stdmath.Cos(math.FastDegToRad(inner)), stdmath.Cos(math.FastDegToRad(outer)))
+			light.Attenuation = attenuation
+			lights = append(lights, light)
+			
+		case "area":
+			light := lighting.NewRectAreaLight(l.Position, l.U, l.V, l.Color, l.Intensity)
+			light.Attenuation = attenuation
+			if l.Samples > 0 {
+				light.Samples = l.Samples
+			}
+			lights = append(lights, light)
+			
+		default:
+			fmt.Printf("Unknown light type: %s\n", l.Type)
+		}
+	}
+	
+	return lights
 }
 
 func (s *Scene) GetCamera() Camera {
 	return s.Camera
 }
 
+func (c Camera) GetFocusDistance() float64 {
+	if c.AutoFocus || c.FocusDistance <= 0 {
+		return c.LookAt.Sub(c.Position).Length()
+	}
+	return c.FocusDistance
+}
+
+func (s *Scene) GetRenderSettings() RenderSettings {
+	if s.Renderer == nil {
+		return RenderSettings{}
+	}
+	return *s.Renderer
+}
+
 func (s *Scene) GetSceneName() string {
 	return "demo_scene"
 }
 
 func createMaterial(materialData map[string]interface{}) material.Material {
-	materialType := materialData["type"].(string)
+	materialType, _ := materialData["type"].(string)
+	color := getVec3(materialData, "color", math.Vec3{X: 0.5, Y: 0.5, Z: 0.5})
 	
 	switch materialType {
 	case "lambertian":
-		color := parseVec3(materialData["color"].([]interface{}))
 		return material.NewLambertian(color)
 		
 	case "metal":
-		color := parseVec3(materialData["color"].([]interface{}))
 		roughness := getFloat(materialData, "roughness", 0.0)
 		metallic := getFloat(materialData, "metallic", 1.0)
 		specular := getFloat(materialData, "specular", 1.0)
 		return material.NewMetal(color, roughness, metallic, specular)
 		
 	case "shiny":
-		color := parseVec3(materialData["color"].([]interface{}))
 		roughness := getFloat(materialData, "roughness", 0.0)
 		metallic := getFloat(materialData, "metallic", 0.0)
 		specular := getFloat(materialData, "specular", 1.0)
 		return material.NewShinyMaterial(color, roughness, metallic, specular)
 		
 	case "perfectmirror":
-		color := parseVec3(materialData["color"].([]interface{}))
 		roughness := getFloat(materialData, "roughness", 0.0)
 		return material.NewPerfectMirror(color, roughness)
 		
 	case "glass":
-		color := parseVec3(materialData["color"].([]interface{}))
 		refractionIndex := getFloat(materialData, "refractionIndex", 1.5)
 		return material.NewGlass(refractionIndex, color)
 		
@@ -138,11 +354,9 @@ func createMaterial(materialData map[string]interface{}) material.Material {
 		return material.NewDielectric(refractionIndex)
 		
 	case "diffuselight":
-		color := parseVec3(materialData["color"].([]interface{}))
 		return material.NewDiffuseLight(color)
 		
 	default:
-		color := parseVec3(materialData["color"].([]interface{}))
 		return material.NewLambertian(color)
 	}
 }
@@ -170,55 +384,83 @@ func createCube(position, size math.Vec3, material interface{}) geometry.Hittabl
 		{4, 5, 1, 0},
 	}
 	
-	var triangles []geometry.Hittable
-	
-	for _, face := range faces {
-		v0 := vertices[face[0]]
-		v1 := vertices[face[1]]
-		v2 := vertices[face[2]]
-		v3 := vertices[face[3]]
-		
-		triangle1 := geometry.NewTriangle(v0, v1, v2, material)
-		triangle2 := geometry.NewTriangle(v0, v2, v3, material)
-		
-		triangles = append(triangles, triangle1, triangle2)
+	return createPolygonMesh(vertices, faces, material)
+}
+
+// createTriangularPrism expects the first three vertices to be one triangular
+// cap and the last three the matching corners of the other cap.
+func createTriangularPrism(vertices []math.Vec3, material interface{}) geometry.Hittable {
+	faces := [][]int{
+		{0, 1, 2},
+		{5, 4, 3},
+		{0, 3, 4, 1},
+		{1, 4, 5, 2},
+		{2, 5, 3, 0},
 	}
 	
-	return &Mesh{
-		Triangles: triangles,
+	return createPolygonMesh(vertices, faces, material)
+}
+
+func createTriangle(vertices, normals []math.Vec3, material interface{}) geometry.Hittable {
+	if len(normals) == 3 {
+		return geometry.NewTriangleWithNormals(vertices[0], vertices[1], vertices[2], normals[0].Normalize(), normals[1].Normalize(), normals[2].Normalize(), material)
 	}
+	return geometry.NewTriangle(vertices[0], vertices[1], vertices[2], material)
 }
 
-type Mesh struct {
-	Triangles []geometry.Hittable
+// createRectangle builds the parallelogram with one corner at corner and
+// edges u and v.
+func createRectangle(corner, u, v math.Vec3, material interface{}) geometry.Hittable {
+	vertices := []math.Vec3{
+		corner,
+		corner.Add(u),
+		corner.Add(u).Add(v),
+		corner.Add(v),
+	}
+	
+	return createPolygonMesh(vertices, [][]int{{0, 1, 2, 3}}, material)
 }
 
-func (m *Mesh) Hit(ray geometry.Ray, tMin, tMax float64) (*geometry.HitRecord, bool) {
-	var closestHit *geometry.HitRecord
-	closestT := tMax
+// createPolygonMesh fan-triangulates each face, given as indices into
+// vertices. Faces are assumed to be convex and planar.
+func createPolygonMesh(vertices []math.Vec3, faces [][]int, material interface{}) geometry.Hittable {
+	var triangles []geometry.Hittable
 	
-	for _, triangle := range m.Triangles {
-		hitRecord, hit := triangle.Hit(ray, tMin, closestT)
-		if hit {
-			closestT = hitRecord.T
-			closestHit = hitRecord
+	for _, face := range faces {
+		for i := 1; i+1 < len(face); i++ {
+			triangle := geometry.NewTriangle(vertices[face[0]], vertices[face[i]], vertices[face[i+1]], material)
+			triangles = append(triangles, triangle)
 		}
 	}
 	
-	return closestHit, closestHit != nil
+	return NewMesh(triangles)
 }
 
-func parseVec3(data []interface{}) math.Vec3 {
-	return math.Vec3{
-		X: data[0].(float64),
-		Y: data[1].(float64),
-		Z: data[2].(float64),
+
+func parseVec3(data []interface{}) (math.Vec3, bool) {
+	if len(data) != 3 {
+		return math.Vec3{}, false
 	}
+	
+	x, okX := data[0].(float64)
+	y, okY := data[1].(float64)
+	z, okZ := data[2].(float64)
+	
+	return math.Vec3{X: x, Y: y, Z: z}, okX && okY && okZ
+}
+
+func getVec3(data map[string]interface{}, key string, defaultValue math.Vec3) math.Vec3 {
+	if array, ok := data[key].([]interface{}); ok {
+		if vec, ok := parseVec3(array); ok {
+			return vec
+		}
+	}
+	return defaultValue
 }
 
 func getFloat(data map[string]interface{}, key string, defaultValue float64) float64 {
-	if value, exists := data[key]; exists {
-		return value.(float64)
+	if value, ok := data[key].(float64); ok {
+		return value
 	}
 	return defaultValue
 }
\ No newline at end of file
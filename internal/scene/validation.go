@@ -0,0 +1,613 @@
+package scene
+
+import (
+	"fmt"
+	stdmath "math"
+	"raytraceGo/internal/atmosphere"
+	"raytraceGo/internal/output"
+	"raytraceGo/internal/sampling"
+	"raytraceGo/internal/tonemap"
+	"strings"
+)
+
+type ValidationError struct {
+	Path     string `json:"path"`
+	Expected string `json:"expected"`
+	Got      string `json:"got"`
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: expected %s, got %s", e.Path, e.Expected, e.Got)
+}
+
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("scene has %d problem(s):", len(e)))
+	for _, err := range e {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	objectTypes   = []string{"sphere", "cube", "triangularPrism", "plane", "triangle", "quad", "rectangle", "mesh"}
+	materialTypes = []string{"lambertian", "metal", "shiny", "perfectmirror", "glass", "dielectric", "diffuselight"}
+	lightTypes    = []string{"point", "directional", "spot", "area"}
+	projections   = []string{ProjectionPerspective, ProjectionOrthographic, ProjectionFisheye, ProjectionEquirectangular}
+)
+
+type validator struct {
+	errors ValidationErrors
+}
+
+func validateScene(root interface{}) ValidationErrors {
+	v := &validator{}
+	
+	fields, ok := v.object("$", root)
+	if !ok {
+		return v.errors
+	}
+	
+	if camera, ok := v.requiredObject(fields, "$", "camera"); ok {
+		v.validateCamera("$.camera", camera)
+	}
+	
+	if objects, ok := v.optionalArray(fields, "$", "objects"); ok {
+		for i, object := range objects {
+			v.validateObject(fmt.Sprintf("$.objects[%d]", i), object)
+		}
+	}
+	
+	if lights, ok := v.optionalArray(fields, "$", "lights"); ok {
+		for i, light := range lights {
+			v.validateLight(fmt.Sprintf("$.lights[%d]", i), light)
+		}
+	}
+	
+	if atmosphere, exists := fields["atmosphere"]; exists {
+		v.validateAtmosphere("$.atmosphere", atmosphere)
+	}
+	
+	if postProcess, ok := v.optionalArray(fields, "$", "postProcess"); ok {
+		for i, effect := range postProcess {
+			v.validatePostEffect(fmt.Sprintf("$.postProcess[%d]", i), effect)
+		}
+	}
+	
+	if renderer, exists := fields["renderer"]; exists {
+		if settings, ok := v.object("$.renderer", renderer); ok {
+			v.optionalEnum(settings, "$.renderer", "sampler", sampling.Names)
+			if aovs, ok := v.optionalArray(settings, "$.renderer", "aovs"); ok {
+				for i, aov := range aovs {
+					v.enumValue(fmt.Sprintf("$.renderer.aovs[%d]", i), aov, output.AOVNames)
+				}
+			}
+			if toneMapping, exists := settings["toneMapping"]; exists {
+				v.validateToneMapping("$.renderer.toneMapping", toneMapping)
+			}
+		}
+	}
+	
+	return v.errors
+}
+
+func (v *validator) validateCamera(path string, camera map[string]interface{}) {
+	v.requiredVec3(camera, path, "position")
+	v.requiredVec3(camera, path, "lookAt")
+	v.optionalVec3(camera, path, "up")
+	v.optionalBool(camera, path, "autoFocus")
+	
+	projection, _ := v.optionalEnum(camera, path, "projection", projections)
+	
+	if fov, ok := v.optionalNumber(camera, path, "fov"); ok {
+		if projection == ProjectionFisheye {
+			if fov <= 0 || fov > 360 {
+				v.addError(join(path, "fov"), "number in (0, 360]", camera["fov"], true)
+			}
+		} else if fov <= 0 || fov >= 180 {
+			v.addError(join(path, "fov"), "number in (0, 180)", camera["fov"], true)
+		}
+	}
+	
+	v.optionalPositive(camera, path, "aspectRatio")
+	if aperture, ok := v.optionalNonNegative(camera, path, "aperture"); ok && aperture > 0 && !projectionHasLens(projection) {
+		v.addError(join(path, "aperture"), "0 with the "+projection+" projection", camera["aperture"], true)
+	}
+	v.optionalPositive(camera, path, "focusDistance")
+	v.optionalPositive(camera, path, "viewWidth")
+}
+
+func (v *validator) validateObject(path string, value interface{}) {
+	object, ok := v.object(path, value)
+	if !ok {
+		return
+	}
+	
+	objectType, ok := v.requiredEnum(object, path, "type", objectTypes)
+	if !ok {
+		return
+	}
+	
+	switch objectType {
+	case "sphere":
+		v.requiredVec3(object, path, "position")
+		v.requiredPositive(object, path, "radius")
+		
+	case "cube":
+		v.requiredVec3(object, path, "position")
+		v.requiredVec3(object, path, "size")
+		
+	case "triangularPrism":
+		v.requiredVec3Array(object, path, "vertices", 6)
+		
+	case "plane":
+		v.requiredVec3(object, path, "position")
+		v.requiredNonZeroVec3(object, path, "normal")
+		
+	case "triangle":
+		v.requiredVec3Array(object, path, "vertices", 3)
+		v.optionalVec3Array(object, path, "normals", 3)
+		
+	case "quad":
+		v.requiredVec3Array(object, path, "vertices", 4)
+		
+	case "rectangle":
+		v.requiredVec3(object, path, "position")
+		v.requiredNonZeroVec3(object, path, "u")
+		v.requiredNonZeroVec3(object, path, "v")
+		
+	case "mesh":
+		v.requiredString(object, path, "file")
+		v.optionalVec3(object, path, "position")
+		v.optionalPositive(object, path, "scale")
+		
+		// Meshes fall back to the materials from their MTL libraries.
+		if _, exists := object["material"]; !exists {
+			return
+		}
+	}
+	
+	if material, ok := v.requiredObject(object, path, "material"); ok {
+		v.validateMaterial(join(path, "material"), material)
+	}
+}
+
+func (v *validator) validateMaterial(path string, material map[string]interface{}) {
+	materialType, ok := v.requiredEnum(material, path, "type", materialTypes)
+	if !ok {
+		return
+	}
+	
+	if materialType != "dielectric" {
+		v.requiredVec3(material, path, "color")
+	}
+	
+	v.optionalNonNegative(material, path, "roughness")
+	v.optionalNonNegative(material, path, "metallic")
+	v.optionalNonNegative(material, path, "specular")
+	v.optionalPositive(material, path, "refractionIndex")
+}
+
+func (v *validator) validateLight(path string, value interface{}) {
+	light, ok := v.object(path, value)
+	if !ok {
+		return
+	}
+	
+	lightType, ok := v.requiredEnum(light, path, "type", lightTypes)
+	if !ok {
+		return
+	}
+	
+	switch lightType {
+	case "point":
+		v.requiredVec3(light, path, "position")
+		v.validateAttenuation(light, path)
+		
+	case "directional":
+		v.requiredNonZeroVec3(light, path, "direction")
+		
+	case "spot":
+		v.requiredVec3(light, path, "position")
+		v.requiredNonZeroVec3(light, path, "direction")
+		v.validateAttenuation(light, path)
+		
+		inner, hasInner := v.number(light, path, "innerCutoff", "angle in (0, 90]", false, isHalfAngle)
+		outer, hasOuter := v.number(light, path, "outerCutoff", "angle in (0, 90]", false, isHalfAngle)
+		if !hasOuter {
+			outer = defaultSpotOuterCutoff
+		}
+		if hasInner && inner > outer {
+			v.addError(join(path, "innerCutoff"), fmt.Sprintf("angle no larger than outerCutoff (%g)", outer), light["innerCutoff"], true)
+		}
+	
+	case "area":
+		v.requiredVec3(light, path, "position")
+		v.requiredNonZeroVec3(light, path, "u")
+		v.requiredNonZeroVec3(light, path, "v")
+		v.number(light, path, "samples", "positive integer", false, isPositiveInteger)
+		v.validateAttenuation(light, path)
+	}
+	
+	v.requiredVec3(light, path, "color")
+	v.requiredNonNegative(light, path, "intensity")
+}
+
+func (v *validator) validateAttenuation(light map[string]interface{}, path string) {
+	if _, exists := light["attenuation"]; !exists {
+		return
+	}
+	
+	attenuation, ok := v.requiredObject(light, path, "attenuation")
+	if !ok {
+		return
+	}
+	
+	path = join(path, "attenuation")
+	errorCount := len(v.errors)
+	constant, _ := v.optionalNonNegative(attenuation, path, "constant")
+	linear, _ := v.optionalNonNegative(attenuation, path, "linear")
+	quadratic, _ := v.optionalNonNegative(attenuation, path, "quadratic")
+	if len(v.errors) == errorCount && constant+linear+quadratic == 0 {
+		v.addError(path, "at least one positive coefficient", attenuation, true)
+	}
+}
+
+func (v *validator) validateAtmosphere(path string, value interface{}) {
+	if preset, ok := value.(string); ok {
+		v.enumValue(path, preset, atmosphere.PresetNames)
+		return
+	}
+	
+	settings, ok := v.object(path, value)
+	if !ok {
+		return
+	}
+	
+	v.optionalEnum(settings, path, "preset", atmosphere.PresetNames)
+	for _, key := range []string{"skyColorTop", "skyColorBottom", "sunColor", "rayleighScattering", "mieScattering", "fogColor"} {
+		v.optionalVec3(settings, path, key)
+	}
+	if _, exists := settings["sunDirection"]; exists {
+		v.requiredNonZeroVec3(settings, path, "sunDirection")
+	}
+	for _, key := range []string{"sunIntensity", "atmosphericDepth", "fogDensity", "hazeIntensity"} {
+		v.optionalNonNegative(settings, path, key)
+	}
+	v.number(settings, path, "sunSize", "number in (0, 1]", false, func(x float64) bool { return x > 0 && x <= 1 })
+	v.number(settings, path, "timeOfDay", "number in [0, 1]", false, func(x float64) bool { return x >= 0 && x <= 1 })
+}
+
+func (v *validator) validatePostEffect(path string, value interface{}) {
+	effect, ok := v.object(path, value)
+	if !ok {
+		return
+	}
+	
+	effectType, ok := v.requiredEnum(effect, path, "type", postEffectTypes)
+	if !ok {
+		return
+	}
+	
+	switch effectType {
+	case "bloom":
+		v.optionalNonNegative(effect, path, "threshold")
+		v.optionalNonNegative(effect, path, "intensity")
+		v.optionalPositive(effect, path, "radius")
+		
+	case "vignette":
+		v.number(effect, path, "intensity", "number in [0, 1]", false, isUnitInterval)
+		v.optionalPositive(effect, path, "radius")
+		v.optionalPositive(effect, path, "softness")
+		
+	case "chromaticAberration":
+		v.optionalNumber(effect, path, "redOffset")
+		v.optionalNumber(effect, path, "greenOffset")
+		v.optionalNumber(effect, path, "blueOffset")
+		
+	case "lensFlare":
+		v.optionalNonNegative(effect, path, "threshold")
+		v.optionalNonNegative(effect, path, "intensity")
+		v.optionalVec3(effect, path, "color")
+		v.optionalPositive(effect, path, "size")
+	}
+}
+
+func (v *validator) validateToneMapping(path string, value interface{}) {
+	settings, ok := v.object(path, value)
+	if !ok {
+		return
+	}
+	
+	v.optionalEnum(settings, path, "operator", tonemap.Operators)
+	v.optionalNumber(settings, path, "exposure")
+	v.number(settings, path, "whiteBalance", "temperature between 1000 and 40000 kelvin", false, isColorTemperature)
+	v.optionalPositive(settings, path, "whitePoint")
+}
+
+func (v *validator) addError(path, expected string, got interface{}, present bool) {
+	description := "nothing"
+	if present {
+		description = describeValue(got)
+	}
+	
+	v.errors = append(v.errors, ValidationError{
+		Path:     path,
+		Expected: expected,
+		Got:      description,
+	})
+}
+
+func (v *validator) object(path string, value interface{}) (map[string]interface{}, bool) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		v.addError(path, "object", value, true)
+	}
+	return object, ok
+}
+
+func (v *validator) requiredObject(fields map[string]interface{}, path, key string) (map[string]interface{}, bool) {
+	value, exists := fields[key]
+	if !exists {
+		v.addError(join(path, key), "object", nil, false)
+		return nil, false
+	}
+	return v.object(join(path, key), value)
+}
+
+func (v *validator) optionalArray(fields map[string]interface{}, path, key string) ([]interface{}, bool) {
+	value, exists := fields[key]
+	if !exists {
+		return nil, false
+	}
+	
+	array, ok := value.([]interface{})
+	if !ok {
+		v.addError(join(path, key), "array", value, true)
+	}
+	return array, ok
+}
+
+func (v *validator) field(fields map[string]interface{}, path, key, expected string, required bool) (interface{}, bool) {
+	value, exists := fields[key]
+	if !exists {
+		if required {
+			v.addError(join(path, key), expected, nil, false)
+		}
+		return nil, false
+	}
+	return value, true
+}
+
+func (v *validator) number(fields map[string]interface{}, path, key, expected string, required bool, accept func(float64) bool) (float64, bool) {
+	value, exists := v.field(fields, path, key, expected, required)
+	if !exists {
+		return 0, false
+	}
+	
+	number, ok := value.(float64)
+	if !ok || !accept(number) {
+		v.addError(join(path, key), expected, value, true)
+		return 0, false
+	}
+	return number, true
+}
+
+func (v *validator) optionalNumber(fields map[string]interface{}, path, key string) (float64, bool) {
+	return v.number(fields, path, key, "number", false, func(float64) bool { return true })
+}
+
+func (v *validator) requiredPositive(fields map[string]interface{}, path, key string) (float64, bool) {
+	return v.number(fields, path, key, "positive number", true, isPositive)
+}
+
+func (v *validator) optionalPositive(fields map[string]interface{}, path, key string) (float64, bool) {
+	return v.number(fields, path, key, "positive number", false, isPositive)
+}
+
+func (v *validator) requiredNonNegative(fields map[string]interface{}, path, key string) (float64, bool) {
+	return v.number(fields, path, key, "non-negative number", true, isNonNegative)
+}
+
+func (v *validator) optionalNonNegative(fields map[string]interface{}, path, key string) (float64, bool) {
+	return v.number(fields, path, key, "non-negative number", false, isNonNegative)
+}
+
+func (v *validator) optionalBool(fields map[string]interface{}, path, key string) (bool, bool) {
+	value, exists := v.field(fields, path, key, "boolean", false)
+	if !exists {
+		return false, false
+	}
+	
+	b, ok := value.(bool)
+	if !ok {
+		v.addError(join(path, key), "boolean", value, true)
+	}
+	return b, ok
+}
+
+func (v *validator) requiredString(fields map[string]interface{}, path, key string) (string, bool) {
+	value, exists := v.field(fields, path, key, "non-empty string", true)
+	if !exists {
+		return "", false
+	}
+	
+	s, ok := value.(string)
+	if !ok || s == "" {
+		v.addError(join(path, key), "non-empty string", value, true)
+		return "", false
+	}
+	return s, true
+}
+
+func (v *validator) enum(fields map[string]interface{}, path, key string, options []string, required bool) (string, bool) {
+	value, exists := v.field(fields, path, key, "one of "+quoteAll(options), required)
+	if !exists {
+		return "", false
+	}
+	
+	return v.enumValue(join(path, key), value, options)
+}
+
+func (v *validator) enumValue(path string, value interface{}, options []string) (string, bool) {
+	s, ok := value.(string)
+	if ok {
+		for _, option := range options {
+			if s == option {
+				return s, true
+			}
+		}
+	}
+	
+	v.addError(path, "one of "+quoteAll(options), value, true)
+	return "", false
+}
+
+func (v *validator) requiredEnum(fields map[string]interface{}, path, key string, options []string) (string, bool) {
+	return v.enum(fields, path, key, options, true)
+}
+
+func (v *validator) optionalEnum(fields map[string]interface{}, path, key string, options []string) (string, bool) {
+	return v.enum(fields, path, key, options, false)
+}
+
+func (v *validator) vec3(fields map[string]interface{}, path, key string, required bool) bool {
+	const expected = "array of 3 numbers"
+	
+	value, exists := v.field(fields, path, key, expected, required)
+	if !exists {
+		return false
+	}
+	
+	return v.vec3Value(join(path, key), value)
+}
+
+func (v *validator) vec3Value(path string, value interface{}) bool {
+	const expected = "array of 3 numbers"
+	
+	array, ok := value.([]interface{})
+	if !ok || len(array) != 3 {
+		v.addError(path, expected, value, true)
+		return false
+	}
+	
+	valid := true
+	for i, component := range array {
+		if _, ok := component.(float64); !ok {
+			v.addError(fmt.Sprintf("%s[%d]", path, i), "number", component, true)
+			valid = false
+		}
+	}
+	return valid
+}
+
+func (v *validator) requiredVec3(fields map[string]interface{}, path, key string) bool {
+	return v.vec3(fields, path, key, true)
+}
+
+func (v *validator) optionalVec3(fields map[string]interface{}, path, key string) bool {
+	return v.vec3(fields, path, key, false)
+}
+
+func (v *validator) requiredNonZeroVec3(fields map[string]interface{}, path, key string) bool {
+	if !v.requiredVec3(fields, path, key) {
+		return false
+	}
+	
+	for _, component := range fields[key].([]interface{}) {
+		if component.(float64) != 0 {
+			return true
+		}
+	}
+	
+	v.addError(join(path, key), "non-zero vector", fields[key], true)
+	return false
+}
+
+func (v *validator) vec3Array(fields map[string]interface{}, path, key string, count int, required bool) bool {
+	expected := fmt.Sprintf("array of %d points", count)
+	
+	value, exists := v.field(fields, path, key, expected, required)
+	if !exists {
+		return false
+	}
+	
+	array, ok := value.([]interface{})
+	if !ok || len(array) != count {
+		v.addError(join(path, key), expected, value, true)
+		return false
+	}
+	
+	valid := true
+	for i, element := range array {
+		if !v.vec3Value(fmt.Sprintf("%s[%d]", join(path, key), i), element) {
+			valid = false
+		}
+	}
+	return valid
+}
+
+func (v *validator) requiredVec3Array(fields map[string]interface{}, path, key string, count int) bool {
+	return v.vec3Array(fields, path, key, count, true)
+}
+
+func (v *validator) optionalVec3Array(fields map[string]interface{}, path, key string, count int) bool {
+	return v.vec3Array(fields, path, key, count, false)
+}
+
+func isPositive(x float64) bool {
+	return x > 0
+}
+
+func isNonNegative(x float64) bool {
+	return x >= 0
+}
+
+func isHalfAngle(x float64) bool {
+	return x > 0 && x <= 90
+}
+
+func isUnitInterval(x float64) bool {
+	return x >= 0 && x <= 1
+}
+
+func isColorTemperature(x float64) bool {
+	return x >= 1000 && x <= 40000
+}
+
+func isPositiveInteger(x float64) bool {
+	return x > 0 && x == stdmath.Trunc(x)
+}
+
+func join(path, key string) string {
+	return path + "." + key
+}
+
+func quoteAll(options []string) string {
+	quoted := make([]string, len(options))
+	for i, option := range options {
+		quoted[i] = fmt.Sprintf("%q", option)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprintf("boolean %t", v)
+	case float64:
+		return fmt.Sprintf("number %g", v)
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case []interface{}:
+		return fmt.Sprintf("array of %d element(s)", len(v))
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
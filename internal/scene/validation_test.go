@@ -0,0 +1,148 @@
+package scene
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseValidScene(t *testing.T) {
+	data := []byte(`{
+		"camera": {"position": [0, 0, -5], "lookAt": [0, 0, 0], "fov": 60},
+		"objects": [
+			{"type": "sphere", "position": [0, 0, 0], "radius": 1, "material": {"type": "lambertian", "color": [1, 0, 0]}}
+		],
+		"lights": [{"type": "point", "position": [5, 5, 5], "color": [1, 1, 1], "intensity": 1}]
+	}`)
+	
+	scene, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(scene.Objects) != 1 || scene.Objects[0].Radius != 1 {
+		t.Errorf("Parse failed: got objects %+v", scene.Objects)
+	}
+}
+
+func TestParseCollectsAllErrors(t *testing.T) {
+	data := []byte(`{
+		"camera": {"position": [0, 0], "lookAt": [0, 0, "1"]},
+		"objects": [
+			{"type": "sphere", "position": [0, 0, 0], "radius": "1", "material": {"type": "metal"}},
+			{"type": "torus", "material": {}},
+			{"type": "cube", "position": [0, 0, 0], "size": [1, 1, 1], "material": {"type": "wood", "color": [1, 1, 1]}}
+		],
+		"lights": [{"type": "point", "position": [1, 1, 1], "color": [1, 1, 1]}]
+	}`)
+	
+	_, err := Parse(data)
+	
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("Parse error: got %v, want ValidationErrors", err)
+	}
+	
+	expected := []ValidationError{
+		{Path: "$.camera.position", Expected: "array of 3 numbers", Got: "array of 2 element(s)"},
+		{Path: "$.camera.lookAt[2]", Expected: "number", Got: `string "1"`},
+		{Path: "$.objects[0].radius", Expected: "positive number", Got: `string "1"`},
+		{Path: "$.objects[0].material.color", Expected: "array of 3 numbers", Got: "nothing"},
+		{Path: "$.objects[1].type", Expected: `one of "sphere", "cube", "triangularPrism", "plane", "triangle", "quad", "rectangle", "mesh"`, Got: `string "torus"`},
+		{Path: "$.objects[2].material.type", Expected: `one of "lambertian", "metal", "shiny", "perfectmirror", "glass", "dielectric", "diffuselight"`, Got: `string "wood"`},
+		{Path: "$.lights[0].intensity", Expected: "non-negative number", Got: "nothing"},
+	}
+	
+	if len(validationErrors) != len(expected) {
+		t.Fatalf("Parse errors: got %d errors, want %d:\n%v", len(validationErrors), len(expected), err)
+	}
+	for i := range expected {
+		if validationErrors[i] != expected[i] {
+			t.Errorf("Error %d: got %+v, want %+v", i, validationErrors[i], expected[i])
+		}
+	}
+}
+
+func TestParseRejectsNonObjectRoot(t *testing.T) {
+	_, err := Parse([]byte(`[1, 2, 3]`))
+	
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) || len(validationErrors) != 1 || validationErrors[0].Path != "$" {
+		t.Errorf("Parse error: got %v, want a single error at $", err)
+	}
+}
+
+func TestParseValidatesLightsByType(t *testing.T) {
+	data := []byte(`{
+		"camera": {"position": [0, 0, -5], "lookAt": [0, 0, 0]},
+		"lights": [
+			{"type": "directional", "direction": [0, 0, 0], "color": [1, 1, 1], "intensity": 1},
+			{"type": "spot", "position": [0, 5, 0], "direction": [0, -1, 0], "innerCutoff": 40, "outerCutoff": 30, "color": [1, 1, 1], "intensity": 1},
+			{"type": "area", "position": [0, 5, 0], "u": [1, 0, 0], "samples": 2.5, "color": [1, 1, 1], "intensity": 1},
+			{"type": "point", "position": [0, 5, 0], "attenuation": {}, "color": [1, 1, 1], "intensity": 1}
+		]
+	}`)
+	
+	_, err := Parse(data)
+	
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("Parse error: got %v, want ValidationErrors", err)
+	}
+	
+	expected := []string{
+		"$.lights[0].direction",
+		"$.lights[1].innerCutoff",
+		"$.lights[2].v",
+		"$.lights[2].samples",
+		"$.lights[3].attenuation",
+	}
+	
+	if len(validationErrors) != len(expected) {
+		t.Fatalf("Parse errors: got %d errors, want %d:\n%v", len(validationErrors), len(expected), err)
+	}
+	for i, path := range expected {
+		if validationErrors[i].Path != path {
+			t.Errorf("Error %d: got %+v, want path %s", i, validationErrors[i], path)
+		}
+	}
+}
+
+func TestParseRejectsApertureWithoutLens(t *testing.T) {
+	for _, projection := range []string{ProjectionFisheye, ProjectionEquirectangular} {
+		_, err := Parse([]byte(`{"camera": {"position": [0, 0, -5], "lookAt": [0, 0, 0], "projection": "` + projection + `", "aperture": 0.1}}`))
+		
+		var validationErrors ValidationErrors
+		if !errors.As(err, &validationErrors) || len(validationErrors) != 1 || validationErrors[0].Path != "$.camera.aperture" {
+			t.Errorf("Parse error with %s projection: got %v, want a single error at $.camera.aperture", projection, err)
+		}
+	}
+	
+	if _, err := Parse([]byte(`{"camera": {"position": [0, 0, -5], "lookAt": [0, 0, 0], "projection": "orthographic", "aperture": 0.1}}`)); err != nil {
+		t.Errorf("Parse failed: %v", err)
+	}
+}
+
+func TestParseAtmosphere(t *testing.T) {
+	camera := `"camera": {"position": [0, 0, -5], "lookAt": [0, 0, 0]}`
+	
+	scene, err := Parse([]byte(`{` + camera + `, "atmosphere": "sunset"}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if config := scene.GetAtmosphere(); config == nil || config.FogDensity != 0.1 {
+		t.Errorf("Atmosphere preset failed: got %+v", config)
+	}
+	
+	scene, err = Parse([]byte(`{` + camera + `, "atmosphere": {"preset": "night", "fogDensity": 0.5}}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if config := scene.GetAtmosphere(); config == nil || config.FogDensity != 0.5 || config.SunIntensity != 0.3 {
+		t.Errorf("Atmosphere override failed: got %+v", config)
+	}
+	
+	_, err = Parse([]byte(`{` + camera + `, "atmosphere": "foggy"}`))
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) || validationErrors[0].Path != "$.atmosphere" {
+		t.Errorf("Parse error: got %v, want an error at $.atmosphere", err)
+	}
+}
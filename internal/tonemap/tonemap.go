@@ -0,0 +1,232 @@
+package tonemap
+
+import (
+	"fmt"
+	"image/color"
+	stdmath "math"
+	"raytraceGo/internal/math"
+	"strings"
+)
+
+// Operators compress linear scene radiance into the displayable range.
+// Exponential is the renderer's original curve and the default.
+const (
+	Linear      = "linear"
+	Exponential = "exponential"
+	Reinhard    = "reinhard"
+	ACES        = "aces"
+	Hable       = "hable"
+)
+
+var Operators = []string{Linear, Exponential, Reinhard, ACES, Hable}
+
+// Temperature of the D65 white point, the neutral white balance.
+const neutralTemperature = 6500.0
+
+// Settings describe a display transform. The zero value is the default.
+type Settings struct {
+	Operator string `json:"operator,omitempty"`
+	// Exposure in stops (EV): every +1 doubles the scene brightness.
+	Exposure float64 `json:"exposure,omitempty"`
+	// WhiteBalance is the color temperature in kelvin that maps to neutral
+	// white, for example 3200 to neutralize tungsten light. 0 leaves colors
+	// unchanged.
+	WhiteBalance float64 `json:"whiteBalance,omitempty"`
+	// WhitePoint is the linear white of the Reinhard and Hable curves. 0
+	// selects the operator's default.
+	WhitePoint float64 `json:"whitePoint,omitempty"`
+}
+
+// Mapper turns linear radiance into display values: white balance, exposure,
+// the tone curve and finally the sRGB transfer function.
+type Mapper struct {
+	curve func(float64) float64
+	aces  bool
+	gains math.Vec3
+}
+
+func Default() *Mapper {
+	mapper, _ := New(Settings{})
+	return mapper
+}
+
+func New(settings Settings) (*Mapper, error) {
+	if settings.WhiteBalance < 0 {
+		return nil, fmt.Errorf("white balance must be a positive temperature, got %v", settings.WhiteBalance)
+	}
+	if settings.WhitePoint < 0 {
+		return nil, fmt.Errorf("white point must be positive, got %v", settings.WhitePoint)
+	}
+	
+	mapper := &Mapper{}
+	switch settings.Operator {
+	case Linear:
+		mapper.curve = func(x float64) float64 { return x }
+	case Exponential, "":
+		mapper.curve = func(x float64) float64 { return 1 - stdmath.Exp(-x) }
+	case Reinhard:
+		mapper.curve = reinhard(settings.WhitePoint)
+	case ACES:
+		mapper.aces = true
+	case Hable:
+		mapper.curve = hable(settings.WhitePoint)
+	default:
+		return nil, fmt.Errorf("unknown tone mapping operator %q, expected one of %s", settings.Operator, strings.Join(Operators, ", "))
+	}
+	
+	scale := stdmath.Exp2(settings.Exposure)
+	mapper.gains = math.Vec3{X: scale, Y: scale, Z: scale}
+	if settings.WhiteBalance > 0 {
+		mapper.gains = mapper.gains.Mul(whiteBalanceGains(settings.WhiteBalance))
+	}
+	return mapper, nil
+}
+
+// Map returns the tone-mapped color, linear and within [0, 1].
+func (m *Mapper) Map(c math.Vec3) math.Vec3 {
+	c = sanitize(c).Mul(m.gains)
+	if m.aces {
+		c = acesFitted(c)
+	} else {
+		c = math.Vec3{X: m.curve(c.X), Y: m.curve(c.Y), Z: m.curve(c.Z)}
+	}
+	return c.Clamp(0, 1)
+}
+
+// Display returns the tone-mapped color encoded for an sRGB display.
+func (m *Mapper) Display(c math.Vec3) math.Vec3 {
+	return EncodeSRGB(m.Map(c))
+}
+
+// RGBA quantizes the display color to 8 bits, rounding to nearest.
+func (m *Mapper) RGBA(c math.Vec3) color.RGBA {
+	return Quantize(m.Display(c))
+}
+
+// Quantize rounds a display color in [0, 1] to 8 bits per channel.
+func Quantize(c math.Vec3) color.RGBA {
+	c = c.Clamp(0, 1)
+	return color.RGBA{uint8(c.X*255 + 0.5), uint8(c.Y*255 + 0.5), uint8(c.Z*255 + 0.5), 255}
+}
+
+// EncodeSRGB applies the sRGB opto-electronic transfer function to linear
+// values in [0, 1].
+func EncodeSRGB(c math.Vec3) math.Vec3 {
+	return math.Vec3{X: srgb(c.X), Y: srgb(c.Y), Z: srgb(c.Z)}
+}
+
+func srgb(x float64) float64 {
+	x = math.FastClamp(x, 0, 1)
+	if x <= 0.0031308 {
+		return 12.92 * x
+	}
+	return 1.055*stdmath.Pow(x, 1/2.4) - 0.055
+}
+
+// EncodeGamma applies a plain power-law transfer function.
+func EncodeGamma(c math.Vec3, gamma float64) math.Vec3 {
+	encode := func(x float64) float64 { return stdmath.Pow(math.FastClamp(x, 0, 1), 1/gamma) }
+	return math.Vec3{X: encode(c.X), Y: encode(c.Y), Z: encode(c.Z)}
+}
+
+// sanitize replaces negative and non-finite components, which no operator
+// can map meaningfully, with zero.
+func sanitize(c math.Vec3) math.Vec3 {
+	clean := func(x float64) float64 {
+		if x <= 0 || stdmath.IsNaN(x) || stdmath.IsInf(x, 0) {
+			return 0
+		}
+		return x
+	}
+	return math.Vec3{X: clean(c.X), Y: clean(c.Y), Z: clean(c.Z)}
+}
+
+// reinhard is the extended Reinhard curve, which reaches 1 at the white
+// point, or x / (1 + x) without one.
+func reinhard(white float64) func(float64) float64 {
+	if white <= 0 {
+		return func(x float64) float64 { return x / (1 + x) }
+	}
+	return func(x float64) float64 { return x * (1 + x/(white*white)) / (1 + x) }
+}
+
+// hable is John Hable's filmic curve from Uncharted 2 with its exposure bias
+// of 2, normalized by the curve's value at the white point.
+func hable(white float64) func(float64) float64 {
+	const a, b, c, d, e, f = 0.15, 0.50, 0.10, 0.20, 0.02, 0.30
+	const exposureBias = 2.0
+	if white <= 0 {
+		white = 11.2
+	}
+	
+	partial := func(x float64) float64 {
+		return (x*(a*x+c*b)+d*e)/(x*(a*x+b)+d*f) - e/f
+	}
+	whiteScale := 1 / partial(white)
+	return func(x float64) float64 { return partial(x*exposureBias) * whiteScale }
+}
+
+// acesFitted approximates the ACES reference rendering and sRGB output
+// transforms (Stephen Hill's fit): convert to the ACES working space, apply
+// the combined curve, and convert back.
+func acesFitted(c math.Vec3) math.Vec3 {
+	c = math.Vec3{
+		X: 0.59719*c.X + 0.35458*c.Y + 0.04823*c.Z,
+		Y: 0.07600*c.X + 0.90834*c.Y + 0.01566*c.Z,
+		Z: 0.02840*c.X + 0.13383*c.Y + 0.83777*c.Z,
+	}
+	
+	curve := func(x float64) float64 {
+		return (x*(x+0.0245786) - 0.000090537) / (x*(0.983729*x+0.4329510) + 0.238081)
+	}
+	c = math.Vec3{X: curve(c.X), Y: curve(c.Y), Z: curve(c.Z)}
+	
+	return math.Vec3{
+		X: 1.60475*c.X - 0.53108*c.Y - 0.07367*c.Z,
+		Y: -0.10208*c.X + 1.10813*c.Y - 0.00605*c.Z,
+		Z: -0.00327*c.X - 0.07276*c.Y + 1.07602*c.Z,
+	}
+}
+
+// whiteBalanceGains scales each channel so that a black body at the given
+// temperature becomes as neutral as the D65 white point.
+func whiteBalanceGains(temperature float64) math.Vec3 {
+	source := blackBodyColor(temperature)
+	neutral := blackBodyColor(neutralTemperature)
+	gains := math.Vec3{X: neutral.X / source.X, Y: neutral.Y / source.Y, Z: neutral.Z / source.Z}
+	// Keep the luminance of white unchanged.
+	return gains.DivScalar(gains.Luminance())
+}
+
+// blackBodyColor approximates the linear sRGB color of a black body radiator
+// between 1000 K and 40000 K (Tanner Helland's fit of the Planckian locus).
+func blackBodyColor(temperature float64) math.Vec3 {
+	t := math.FastClamp(temperature, 1000, 40000) / 100
+	
+	var r, g, b float64
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*stdmath.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * stdmath.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * stdmath.Pow(t-60, -0.0755148492)
+	}
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*stdmath.Log(t-10) - 305.0447927307
+	}
+	
+	// The fit produces sRGB-encoded values; decode them to linear.
+	decode := func(x float64) float64 {
+		x = math.FastClamp(x/255, 1e-3, 1)
+		if x <= 0.04045 {
+			return x / 12.92
+		}
+		return stdmath.Pow((x+0.055)/1.055, 2.4)
+	}
+	return math.Vec3{X: decode(r), Y: decode(g), Z: decode(b)}
+}
@@ -0,0 +1,77 @@
+package tonemap
+
+import (
+	stdmath "math"
+	"raytraceGo/internal/math"
+	"testing"
+)
+
+func TestOperatorsAreMonotonicAndBounded(t *testing.T) {
+	for _, operator := range Operators {
+		mapper, err := New(Settings{Operator: operator})
+		if err != nil {
+			t.Fatalf("New(%s) failed: %v", operator, err)
+		}
+		
+		if black := mapper.Map(math.Vec3{}); !black.NearZero() {
+			t.Errorf("%s black failed: got %v, want 0", operator, black)
+		}
+		
+		previous := -1.0
+		for x := 0.0; x < 100; x = x*1.5 + 0.01 {
+			y := mapper.Map(math.Vec3{X: x, Y: x, Z: x}).Y
+			if y < previous-1e-9 || y > 1 {
+				t.Errorf("%s at %v failed: got %v after %v, want non-decreasing within [0, 1]", operator, x, y, previous)
+				break
+			}
+			previous = y
+		}
+	}
+	
+	if _, err := New(Settings{Operator: "filmic"}); err == nil {
+		t.Errorf("New failed: accepted an unknown operator")
+	}
+}
+
+func TestExposureDoublesPerStop(t *testing.T) {
+	plus, _ := New(Settings{Operator: Linear, Exposure: 1})
+	normal, _ := New(Settings{Operator: Linear})
+	
+	got := plus.Map(math.Vec3{X: 0.2, Y: 0.2, Z: 0.2})
+	want := normal.Map(math.Vec3{X: 0.4, Y: 0.4, Z: 0.4})
+	if got.Sub(want).Length() > 1e-12 {
+		t.Errorf("Exposure failed: got %v, want %v", got, want)
+	}
+}
+
+func TestEncodeSRGB(t *testing.T) {
+	cases := map[float64]float64{0: 0, 0.002: 0.02584, 0.18: 0.46135, 0.5: 0.73536, 1: 1}
+	for linear, want := range cases {
+		if got := EncodeSRGB(math.Vec3{X: linear}).X; stdmath.Abs(got-want) > 1e-4 {
+			t.Errorf("EncodeSRGB(%v) failed: got %v, want %v", linear, got, want)
+		}
+	}
+}
+
+func TestWhiteBalanceNeutralizesIlluminant(t *testing.T) {
+	reference := blackBodyColor(neutralTemperature)
+	for _, temperature := range []float64{2700, 3200, 5000, 9000} {
+		white := blackBodyColor(temperature).Mul(whiteBalanceGains(temperature))
+		ratio := math.Vec3{X: white.X / reference.X, Y: white.Y / reference.Y, Z: white.Z / reference.Z}
+		if stdmath.Abs(ratio.X-ratio.Y) > 1e-9*ratio.Y || stdmath.Abs(ratio.Z-ratio.Y) > 1e-9*ratio.Y {
+			t.Errorf("white balance at %vK failed: got %v, want a multiple of the %vK white %v", temperature, white, neutralTemperature, reference)
+		}
+	}
+	
+	neutral := whiteBalanceGains(neutralTemperature)
+	if neutral.Sub(math.Vec3{X: 1, Y: 1, Z: 1}).Length() > 1e-9 {
+		t.Errorf("white balance at %vK failed: got gains %v, want 1", neutralTemperature, neutral)
+	}
+}
+
+func TestReinhardWhitePoint(t *testing.T) {
+	mapper, _ := New(Settings{Operator: Reinhard, WhitePoint: 4})
+	if got := mapper.Map(math.Vec3{X: 4, Y: 4, Z: 4}).X; stdmath.Abs(got-1) > 1e-12 {
+		t.Errorf("Reinhard white point failed: got %v, want 1", got)
+	}
+}